@@ -0,0 +1,125 @@
+public class ArithTest {
+
+    public static void main(String[] args) {
+        // 整数除法与取余，向零截断，余数符号与被除数一致
+        System.out.println(idiv(-7, 2)); // -3
+        System.out.println(irem(-7, 2)); // -1
+        System.out.println(irem(7, -2)); // 1
+        System.out.println(idiv(Integer.MIN_VALUE, -1)); // -2147483648
+        System.out.println(irem(Integer.MIN_VALUE, -1)); // 0
+        System.out.println(ldiv(Long.MIN_VALUE, -1L)); // -9223372036854775808
+        System.out.println(lrem(-7L, 3L)); // -1
+        // 溢出回绕
+        System.out.println(iadd(Integer.MAX_VALUE, 1)); // -2147483648
+        System.out.println(lmul(Long.MAX_VALUE, 2L)); // -2
+        // 移位距离取低 5 位或者低 6 位
+        System.out.println(ishl(1, 33)); // 2
+        System.out.println(ishr(-16, 34)); // -4
+        System.out.println(iushr(-1, 28)); // 15
+        System.out.println(lshl(1L, 65)); // 2
+        System.out.println(lushr(-1L, 60)); // 15
+        // 浮点取余
+        System.out.println((int) (frem(5.5f, 2f) * 10)); // 15
+        System.out.println((int) (frem(-5.5f, 2f) * 10)); // -15
+        System.out.println((int) (drem(5.5, -2) * 10)); // 15
+        System.out.println(isNaN(drem(1, 0))); // 1
+        System.out.println(isNaN(drem(Double.POSITIVE_INFINITY, 2))); // 1
+        System.out.println((int) drem(3, Double.POSITIVE_INFINITY)); // 3
+        System.out.println(1 / drem(-0.0, 1) < 0 ? 1 : 0); // 1
+        // 浮点转整数
+        System.out.println(f2i(Float.NaN)); // 0
+        System.out.println(d2l(1e30)); // 9223372036854775807
+        System.out.println(d2i(-1e30)); // -2147483648
+        // float 每一步都按照 float 精度舍入
+        System.out.println(fadd(0.1f, 0.2f) == 0.3f ? 1 : 0); // 1
+        System.out.println(dadd(0.1, 0.2) == 0.3 ? 1 : 0); // 0
+        // 整数除零
+        try {
+            idiv(1, 0);
+        } catch (ArithmeticException e) {
+            System.out.println(e.getMessage()); // / by zero
+        }
+        try {
+            lrem(1L, 0L);
+        } catch (ArithmeticException e) {
+            System.out.println(e.getMessage()); // / by zero
+        }
+    }
+
+    private static int idiv(int a, int b) {
+        return a / b;
+    }
+
+    private static int irem(int a, int b) {
+        return a % b;
+    }
+
+    private static long ldiv(long a, long b) {
+        return a / b;
+    }
+
+    private static long lrem(long a, long b) {
+        return a % b;
+    }
+
+    private static int iadd(int a, int b) {
+        return a + b;
+    }
+
+    private static long lmul(long a, long b) {
+        return a * b;
+    }
+
+    private static int ishl(int a, int b) {
+        return a << b;
+    }
+
+    private static int ishr(int a, int b) {
+        return a >> b;
+    }
+
+    private static int iushr(int a, int b) {
+        return a >>> b;
+    }
+
+    private static long lshl(long a, int b) {
+        return a << b;
+    }
+
+    private static long lushr(long a, int b) {
+        return a >>> b;
+    }
+
+    private static float frem(float a, float b) {
+        return a % b;
+    }
+
+    private static double drem(double a, double b) {
+        return a % b;
+    }
+
+    private static float fadd(float a, float b) {
+        return a + b;
+    }
+
+    private static double dadd(double a, double b) {
+        return a + b;
+    }
+
+    private static int f2i(float a) {
+        return (int) a;
+    }
+
+    private static int d2i(double a) {
+        return (int) a;
+    }
+
+    private static long d2l(double a) {
+        return (long) a;
+    }
+
+    private static int isNaN(double a) {
+        return a != a ? 1 : 0;
+    }
+
+}
@@ -0,0 +1,37 @@
+public class CloneTest implements Cloneable {
+
+    private int value;
+    private int[] data = {1, 2, 3};
+
+    public static void main(String[] args) throws CloneNotSupportedException {
+        // 数组的 clone 是 public 的，复制元素得到新数组
+        int[] arr1 = {1, 2, 3};
+        int[] arr2 = arr1.clone();
+        System.out.println(arr1 == arr2); // false
+        System.out.println(arr2[2]); // 3
+        arr2[0] = 10;
+        System.out.println(arr1[0]); // 1
+        // 对象的 clone 是浅拷贝，引用类型的字段指向同一个对象
+        CloneTest test1 = new CloneTest();
+        test1.value = 42;
+        CloneTest test2 = (CloneTest) test1.clone();
+        System.out.println(test1 == test2); // false
+        System.out.println(test2.value); // 42
+        System.out.println(test1.data == test2.data); // true
+        // 没有实现 Cloneable 抛出异常
+        try {
+            new NotCloneable().copy();
+        } catch (CloneNotSupportedException e) {
+            System.out.println(e.getMessage()); // CloneTest$NotCloneable
+        }
+    }
+
+    static class NotCloneable {
+
+        Object copy() throws CloneNotSupportedException {
+            return clone();
+        }
+
+    }
+
+}
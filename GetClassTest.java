@@ -8,6 +8,13 @@ public class GetClassTest {
         System.out.println(Object[][].class.getName()); // [[Ljava.lang.Object;
         System.out.println(Runnable.class.getName()); // java.lang.Runnable
         System.out.println("abc".getClass().getName()); // java.lang.String
+        // 每个类只有一个 Class 对象
+        System.out.println(new Object().getClass() == Object.class); // true
+        System.out.println(int.class.getName()); // int
+        System.out.println(int[].class.getComponentType() == int.class); // true
+        System.out.println(String.class.getSuperclass().getName()); // java.lang.Object
+        System.out.println(Object.class.isAssignableFrom(String.class)); // true
+        System.out.println(Runnable.class.isInterface()); // true
     }
 
 }
\ No newline at end of file
@@ -9,6 +9,19 @@ public class StringTest {
         System.out.println(s1 == s3); // false
         s3 = s3.intern();
         System.out.println(s1 == s3); // true
+        String s7 = new String("abc1");
+        System.out.println(s1 == s7); // false
+        System.out.println(s1 == s7.intern()); // true
+        // 常量池中的字符串使用 Modified UTF-8 编码，String 内部使用 utf-16 编码单元
+        String s4 = "中文";
+        System.out.println(s4.length()); // 2
+        System.out.println(s4); // 中文
+        String s5 = "\uD83D\uDE00"; // 补充字符由两个代理项组成
+        System.out.println(s5.length()); // 2
+        System.out.println(s5.codePointAt(0)); // 128512
+        String s6 = "\u0000"; // 编码为 0xC0 0x80
+        System.out.println(s6.length()); // 1
+        System.out.println((int) s6.charAt(0)); // 0
     }
 
 }
\ No newline at end of file
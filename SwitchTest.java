@@ -0,0 +1,96 @@
+public class SwitchTest {
+
+    enum Color {
+        RED, GREEN, BLUE
+    }
+
+    public static void main(String[] args) {
+        // 连续的 case 编译为 tableswitch
+        for (int i = -1; i <= 4; i++) {
+            System.out.println(table(i)); // 0 10 11 12 13 0
+        }
+        // 稀疏的 case 编译为 lookupswitch
+        System.out.println(lookup(-100000)); // 1
+        System.out.println(lookup(7)); // 2
+        System.out.println(lookup(100000)); // 3
+        System.out.println(lookup(8)); // 0
+        System.out.println(charSwitch('a')); // 1
+        System.out.println(charSwitch('z')); // 26
+        System.out.println(charSwitch('?')); // -1
+        // 字符串先按照 hashCode 使用 lookupswitch，再用 equals 比较，"Aa" 与 "BB" 的 hashCode 相同
+        System.out.println(stringSwitch("Aa")); // 1
+        System.out.println(stringSwitch("BB")); // 2
+        System.out.println(stringSwitch("hello")); // 3
+        System.out.println(stringSwitch("other")); // 0
+        // 枚举使用合成类 SwitchTest$1 中的 $SwitchMap$SwitchTest$Color 将 ordinal 映射为 case 下标
+        System.out.println(enumSwitch(Color.RED)); // 1
+        System.out.println(enumSwitch(Color.GREEN)); // 2
+        System.out.println(enumSwitch(Color.BLUE)); // 3
+    }
+
+    private static int table(int i) {
+        switch (i) {
+            case 0:
+                return 10;
+            case 1:
+                return 11;
+            case 2:
+                return 12;
+            case 3:
+                return 13;
+            default:
+                return 0;
+        }
+    }
+
+    private static int lookup(int i) {
+        switch (i) {
+            case -100000:
+                return 1;
+            case 7:
+                return 2;
+            case 100000:
+                return 3;
+            default:
+                return 0;
+        }
+    }
+
+    private static int charSwitch(char c) {
+        switch (c) {
+            case 'a':
+                return 1;
+            case 'z':
+                return 26;
+            default:
+                return -1;
+        }
+    }
+
+    private static int stringSwitch(String s) {
+        switch (s) {
+            case "Aa":
+                return 1;
+            case "BB":
+                return 2;
+            case "hello":
+                return 3;
+            default:
+                return 0;
+        }
+    }
+
+    private static int enumSwitch(Color color) {
+        switch (color) {
+            case RED:
+                return 1;
+            case GREEN:
+                return 2;
+            case BLUE:
+                return 3;
+            default:
+                return 0;
+        }
+    }
+
+}
@@ -0,0 +1,147 @@
+/*
+@author: sk
+@date: 2026/10/18
+*/
+package main
+
+import "math"
+
+// java 算术运算语义 https://docs.oracle.com/javase/specs/jls/se8/html/jls-15.html#jls-15.17
+// 整数运算溢出直接回绕，除零由调用方抛出 ArithmeticException
+// 浮点运算每一步都显式转换，避免编译器合并为 FMA 等指令导致结果与 strictfp 不一致
+
+func IntDiv(val1 int32, val2 int32) int32 {
+	if val1 == math.MinInt32 && val2 == -1 { // 溢出结果还是自己
+		return val1
+	}
+	return val1 / val2
+}
+
+func IntRem(val1 int32, val2 int32) int32 {
+	if val2 == -1 { // MinInt32 % -1 为 0
+		return 0
+	}
+	return val1 % val2 // 符号与被除数一致
+}
+
+func LongDiv(val1 int64, val2 int64) int64 {
+	if val1 == math.MinInt64 && val2 == -1 {
+		return val1
+	}
+	return val1 / val2
+}
+
+func LongRem(val1 int64, val2 int64) int64 {
+	if val2 == -1 {
+		return 0
+	}
+	return val1 % val2
+}
+
+// 移位距离只取低 5 位(int)或者低 6 位(long)
+func IntShl(val int32, dist int32) int32 {
+	return val << (dist & 0x1f)
+}
+
+func IntShr(val int32, dist int32) int32 {
+	return val >> (dist & 0x1f)
+}
+
+func IntUShr(val int32, dist int32) int32 {
+	return int32(uint32(val) >> (dist & 0x1f))
+}
+
+func LongShl(val int64, dist int32) int64 {
+	return val << (dist & 0x3f)
+}
+
+func LongShr(val int64, dist int32) int64 {
+	return val >> (dist & 0x3f)
+}
+
+func LongUShr(val int64, dist int32) int64 {
+	return int64(uint64(val) >> (dist & 0x3f))
+}
+
+func FloatAdd(val1 float32, val2 float32) float32 {
+	return float32(val1 + val2)
+}
+
+func FloatSub(val1 float32, val2 float32) float32 {
+	return float32(val1 - val2)
+}
+
+func FloatMul(val1 float32, val2 float32) float32 {
+	return float32(val1 * val2)
+}
+
+func FloatDiv(val1 float32, val2 float32) float32 {
+	return float32(val1 / val2)
+}
+
+// 与 c 的 fmod 一致按截断取余，不是 IEEE 754 的 remainder，结果可以精确表示所以转换不会有误差
+func FloatRem(val1 float32, val2 float32) float32 {
+	return float32(math.Mod(float64(val1), float64(val2)))
+}
+
+func DoubleAdd(val1 float64, val2 float64) float64 {
+	return float64(val1 + val2)
+}
+
+func DoubleSub(val1 float64, val2 float64) float64 {
+	return float64(val1 - val2)
+}
+
+func DoubleMul(val1 float64, val2 float64) float64 {
+	return float64(val1 * val2)
+}
+
+func DoubleDiv(val1 float64, val2 float64) float64 {
+	return float64(val1 / val2)
+}
+
+// NaN 参与结果为 NaN，除数为 0 结果为 NaN，除数为无穷结果为被除数，结果符号与被除数一致
+func DoubleRem(val1 float64, val2 float64) float64 {
+	return math.Mod(val1, val2)
+}
+
+// 浮点数转整数 NaN 为 0，超出范围取最大最小值，go 中超出范围的转换结果是未定义的
+func FloatToInt(val float64) int32 {
+	switch {
+	case math.IsNaN(val):
+		return 0
+	case val >= math.MaxInt32:
+		return math.MaxInt32
+	case val <= math.MinInt32:
+		return math.MinInt32
+	default:
+		return int32(val)
+	}
+}
+
+func FloatToLong(val float64) int64 {
+	switch {
+	case math.IsNaN(val):
+		return 0
+	case val >= math.MaxInt64: // 会被转换为 2^63
+		return math.MaxInt64
+	case val <= math.MinInt64:
+		return math.MinInt64
+	default:
+		return int64(val)
+	}
+}
+
+// 有 NaN 时 fcmpl dcmpl 结果为 -1，fcmpg dcmpg 结果为 1
+func FloatCmp(val1 float64, val2 float64, nan int32) int32 {
+	switch {
+	case val1 > val2:
+		return 1
+	case val1 < val2:
+		return -1
+	case val1 == val2: // +0.0 与 -0.0 相等
+		return 0
+	default:
+		return nan
+	}
+}
@@ -0,0 +1,461 @@
+/*
+@author: sk
+@date: 2026/10/18
+*/
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 测试使用的 class 文件生成器，常量池项按需添加并去重，生成的文件与 javac 的结构一致，通过正常的加载流程使用
+type testClass struct {
+	name       string
+	super      string
+	access     uint16
+	interfaces []string
+	consts     [][]byte
+	constIndex map[string]uint16
+	count      uint16 // 常量池下一个可用的下标，long double 占用两个
+	fields     [][]byte
+	methods    [][]byte
+	bootstraps [][]byte // BootstrapMethods 属性中的项
+	source     string   // SourceFile 属性，为空时不生成
+}
+
+func newTestClass(name string, super string, interfaces ...string) *testClass {
+	return &testClass{name: name, super: super, access: AccessPublic | AccessSuper, interfaces: interfaces,
+		constIndex: make(map[string]uint16), count: 1}
+}
+
+func (c *testClass) addConst(key string, data []byte, wide bool) uint16 {
+	if index, ok := c.constIndex[key]; ok {
+		return index
+	}
+	index := c.count
+	c.consts = append(c.consts, data)
+	c.constIndex[key] = index
+	c.count++
+	if wide {
+		c.count++
+	}
+	return index
+}
+
+func (c *testClass) utf8(val string) uint16 {
+	data := binary.BigEndian.AppendUint16([]byte{ConstUtf8}, uint16(len(val)))
+	return c.addConst("u"+val, append(data, val...), false)
+}
+
+func (c *testClass) class(name string) uint16 {
+	return c.addConst("c"+name, binary.BigEndian.AppendUint16([]byte{ConstClass}, c.utf8(name)), false)
+}
+
+func (c *testClass) str(val string) uint16 {
+	return c.addConst("s"+val, binary.BigEndian.AppendUint16([]byte{ConstString}, c.utf8(val)), false)
+}
+
+func (c *testClass) integer(val int32) uint16 {
+	return c.addConst(fmt.Sprint("i", val), binary.BigEndian.AppendUint32([]byte{ConstInteger}, uint32(val)), false)
+}
+
+func (c *testClass) float(val float32) uint16 {
+	return c.addConst(fmt.Sprint("f", val), binary.BigEndian.AppendUint32([]byte{ConstFloat}, math.Float32bits(val)), false)
+}
+
+func (c *testClass) long(val int64) uint16 {
+	return c.addConst(fmt.Sprint("l", val), binary.BigEndian.AppendUint64([]byte{ConstLong}, uint64(val)), true)
+}
+
+func (c *testClass) double(val float64) uint16 {
+	return c.addConst(fmt.Sprint("d", val), binary.BigEndian.AppendUint64([]byte{ConstDouble}, math.Float64bits(val)), true)
+}
+
+func (c *testClass) nameType(name string, desc string) uint16 {
+	data := binary.BigEndian.AppendUint16([]byte{ConstNameType}, c.utf8(name))
+	return c.addConst("n"+name+":"+desc, binary.BigEndian.AppendUint16(data, c.utf8(desc)), false)
+}
+
+// tag 为 ConstField ConstMethod ConstInterfaceMethod
+func (c *testClass) ref(tag uint8, class string, name string, desc string) uint16 {
+	data := binary.BigEndian.AppendUint16([]byte{tag}, c.class(class))
+	data = binary.BigEndian.AppendUint16(data, c.nameType(name, desc))
+	return c.addConst(fmt.Sprint("r", tag, class, ".", name, ":", desc), data, false)
+}
+
+func (c *testClass) methodHandle(kind uint8, tag uint8, class string, name string, desc string) uint16 {
+	data := binary.BigEndian.AppendUint16([]byte{ConstMethodHandle, kind}, c.ref(tag, class, name, desc))
+	return c.addConst(fmt.Sprint("h", kind, class, ".", name, ":", desc), data, false)
+}
+
+func (c *testClass) methodType(desc string) uint16 {
+	return c.addConst("t"+desc, binary.BigEndian.AppendUint16([]byte{ConstMethodType}, c.utf8(desc)), false)
+}
+
+// 添加一个 BootstrapMethods 中的项，返回 invokedynamic 使用的常量池下标
+func (c *testClass) invokeDynamic(bootstrap uint16, args []uint16, name string, desc string) uint16 {
+	data := binary.BigEndian.AppendUint16(nil, bootstrap)
+	data = binary.BigEndian.AppendUint16(data, uint16(len(args)))
+	for _, item := range args {
+		data = binary.BigEndian.AppendUint16(data, item)
+	}
+	c.bootstraps = append(c.bootstraps, data)
+	data = binary.BigEndian.AppendUint16([]byte{ConstInvokeDynamic}, uint16(len(c.bootstraps)-1))
+	data = binary.BigEndian.AppendUint16(data, c.nameType(name, desc))
+	return c.addConst(fmt.Sprint("y", len(c.bootstraps)-1), data, false)
+}
+
+func (c *testClass) field(access uint16, name string, desc string) {
+	data := binary.BigEndian.AppendUint16(nil, access)
+	data = binary.BigEndian.AppendUint16(data, c.utf8(name))
+	data = binary.BigEndian.AppendUint16(data, c.utf8(desc))
+	c.fields = append(c.fields, binary.BigEndian.AppendUint16(data, 0))
+}
+
+// 带 ConstantValue 属性的字段，value 是常量池下标
+func (c *testClass) constField(access uint16, name string, desc string, value uint16) {
+	data := binary.BigEndian.AppendUint16(nil, access)
+	data = binary.BigEndian.AppendUint16(data, c.utf8(name))
+	data = binary.BigEndian.AppendUint16(data, c.utf8(desc))
+	data = binary.BigEndian.AppendUint16(data, 1)
+	data = binary.BigEndian.AppendUint16(data, c.utf8(AttributeConstantValue))
+	data = binary.BigEndian.AppendUint32(data, 2)
+	c.fields = append(c.fields, binary.BigEndian.AppendUint16(data, value))
+}
+
+// 本地方法与抽象方法 code 为 nil
+func (c *testClass) method(access uint16, name string, desc string, code *testCode) {
+	data := binary.BigEndian.AppendUint16(nil, access)
+	data = binary.BigEndian.AppendUint16(data, c.utf8(name))
+	data = binary.BigEndian.AppendUint16(data, c.utf8(desc))
+	if code == nil {
+		c.methods = append(c.methods, binary.BigEndian.AppendUint16(data, 0))
+		return
+	}
+	data = binary.BigEndian.AppendUint16(data, 1)
+	attr := code.assemble(c)
+	data = binary.BigEndian.AppendUint16(data, c.utf8(AttributeCode))
+	data = binary.BigEndian.AppendUint32(data, uint32(len(attr)))
+	c.methods = append(c.methods, append(data, attr...))
+}
+
+func (c *testClass) bytes() []byte {
+	thisIndex := c.class(c.name)
+	attrs, attrCount := make([]byte, 0), uint16(0)
+	if len(c.bootstraps) > 0 {
+		attrCount++
+		attr := binary.BigEndian.AppendUint16(nil, uint16(len(c.bootstraps)))
+		for _, item := range c.bootstraps {
+			attr = append(attr, item...)
+		}
+		attrs = binary.BigEndian.AppendUint16(attrs, c.utf8(AttributeBootstrapMethods))
+		attrs = append(binary.BigEndian.AppendUint32(attrs, uint32(len(attr))), attr...)
+	}
+	if c.source != "" {
+		attrs = binary.BigEndian.AppendUint16(attrs, c.utf8(AttributeSourceFile))
+		attrs = binary.BigEndian.AppendUint16(binary.BigEndian.AppendUint32(attrs, 2), c.utf8(c.source))
+		attrCount++
+	}
+	superIndex := uint16(0)
+	if c.super != "" {
+		superIndex = c.class(c.super)
+	}
+	interfaces := make([]uint16, 0)
+	for _, item := range c.interfaces {
+		interfaces = append(interfaces, c.class(item))
+	}
+	data := []byte{0xCA, 0xFE, 0xBA, 0xBE, 0, 0, 0, 52}
+	data = binary.BigEndian.AppendUint16(data, c.count)
+	for _, item := range c.consts {
+		data = append(data, item...)
+	}
+	data = binary.BigEndian.AppendUint16(data, c.access)
+	data = binary.BigEndian.AppendUint16(data, thisIndex)
+	data = binary.BigEndian.AppendUint16(data, superIndex)
+	data = binary.BigEndian.AppendUint16(data, uint16(len(interfaces)))
+	for _, item := range interfaces {
+		data = binary.BigEndian.AppendUint16(data, item)
+	}
+	for _, items := range [][][]byte{c.fields, c.methods} {
+		data = binary.BigEndian.AppendUint16(data, uint16(len(items)))
+		for _, item := range items {
+			data = append(data, item...)
+		}
+	}
+	return append(binary.BigEndian.AppendUint16(data, attrCount), attrs...)
+}
+
+func (c *testClass) write(tb testing.TB, dir string) {
+	path := filepath.Join(dir, c.name+".class")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		tb.Fatal(err)
+	}
+	if err := os.WriteFile(path, c.bytes(), 0644); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// 方法的字节码，跳转目标与异常处理范围使用标签，生成 Code 属性时回填
+type testCode struct {
+	maxStack  uint16
+	maxLocals uint16
+	code      []byte
+	labels    map[string]int
+	jumps     []testJump
+	handlers  []testHandler
+	lines     []LineNumber // LineNumberTable 属性，为空时不生成
+}
+
+type testJump struct {
+	pc    int // 跳转指令的地址，偏移量相对于它计算
+	at    int // 偏移量的位置
+	wide  bool
+	label string
+}
+
+type testHandler struct {
+	start, end, handler string
+	catchType           string // 为空表示 catch all
+}
+
+func newTestCode(maxStack int, maxLocals int) *testCode {
+	return &testCode{maxStack: uint16(maxStack), maxLocals: uint16(maxLocals), labels: make(map[string]int)}
+}
+
+func (c *testCode) op(ops ...byte) *testCode {
+	c.code = append(c.code, ops...)
+	return c
+}
+
+// 操作数为 2 byte 常量池下标的指令，例如 invokestatic getstatic new
+func (c *testCode) op16(op byte, index uint16) *testCode {
+	c.code = binary.BigEndian.AppendUint16(append(c.code, op), index)
+	return c
+}
+
+// 2 byte 偏移量的跳转指令
+func (c *testCode) jump(op byte, label string) *testCode {
+	c.jumps = append(c.jumps, testJump{pc: len(c.code), at: len(c.code) + 1, label: label})
+	c.code = append(c.code, op, 0, 0)
+	return c
+}
+
+// 4 byte 偏移量的跳转指令 goto_w jsr_w
+func (c *testCode) jumpW(op byte, label string) *testCode {
+	c.jumps = append(c.jumps, testJump{pc: len(c.code), at: len(c.code) + 1, wide: true, label: label})
+	c.code = append(c.code, op, 0, 0, 0, 0)
+	return c
+}
+
+// tableswitch lookupswitch，keys 为空时生成 tableswitch，low 开始依次对应 labels
+func (c *testCode) switchOp(low int32, keys []int32, defaultLabel string, labels ...string) *testCode {
+	pc := len(c.code)
+	offset := func(label string) {
+		c.jumps = append(c.jumps, testJump{pc: pc, at: len(c.code), wide: true, label: label})
+		c.code = append(c.code, 0, 0, 0, 0)
+	}
+	if keys == nil {
+		c.code = append(c.code, 0xAA)
+	} else {
+		c.code = append(c.code, 0xAB)
+	}
+	for len(c.code)%4 != 0 { // 对齐到 4 byte
+		c.code = append(c.code, 0)
+	}
+	offset(defaultLabel)
+	if keys == nil {
+		c.code = binary.BigEndian.AppendUint32(c.code, uint32(low))
+		c.code = binary.BigEndian.AppendUint32(c.code, uint32(low+int32(len(labels))-1))
+		for _, item := range labels {
+			offset(item)
+		}
+		return c
+	}
+	c.code = binary.BigEndian.AppendUint32(c.code, uint32(len(keys)))
+	for i, item := range keys {
+		c.code = binary.BigEndian.AppendUint32(c.code, uint32(item))
+		offset(labels[i])
+	}
+	return c
+}
+
+func (c *testCode) label(name string) *testCode {
+	c.labels[name] = len(c.code)
+	return c
+}
+
+// 之后的指令属于源码第 line 行
+func (c *testCode) line(line int) *testCode {
+	c.lines = append(c.lines, LineNumber{Start: uint16(len(c.code)), Line: uint16(line)})
+	return c
+}
+
+func (c *testCode) catch(start string, end string, handler string, catchType string) *testCode {
+	c.handlers = append(c.handlers, testHandler{start: start, end: end, handler: handler, catchType: catchType})
+	return c
+}
+
+func (c *testCode) assemble(class *testClass) []byte {
+	for _, item := range c.jumps {
+		if item.wide {
+			binary.BigEndian.PutUint32(c.code[item.at:], uint32(c.labels[item.label]-item.pc))
+		} else {
+			binary.BigEndian.PutUint16(c.code[item.at:], uint16(c.labels[item.label]-item.pc))
+		}
+	}
+	data := binary.BigEndian.AppendUint16(nil, c.maxStack)
+	data = binary.BigEndian.AppendUint16(data, c.maxLocals)
+	data = binary.BigEndian.AppendUint32(data, uint32(len(c.code)))
+	data = append(data, c.code...)
+	data = binary.BigEndian.AppendUint16(data, uint16(len(c.handlers)))
+	for _, item := range c.handlers {
+		catchType := uint16(0)
+		if item.catchType != "" {
+			catchType = class.class(item.catchType)
+		}
+		for _, label := range []string{item.start, item.end, item.handler} {
+			data = binary.BigEndian.AppendUint16(data, uint16(c.labels[label]))
+		}
+		data = binary.BigEndian.AppendUint16(data, catchType)
+	}
+	if len(c.lines) == 0 {
+		return binary.BigEndian.AppendUint16(data, 0)
+	}
+	data = binary.BigEndian.AppendUint16(data, 1)
+	data = binary.BigEndian.AppendUint16(data, class.utf8(AttributeLineNumberTable))
+	data = binary.BigEndian.AppendUint32(data, uint32(2+len(c.lines)*4))
+	data = binary.BigEndian.AppendUint16(data, uint16(len(c.lines)))
+	for _, item := range c.lines {
+		data = binary.BigEndian.AppendUint16(data, item.Start)
+		data = binary.BigEndian.AppendUint16(data, item.Line)
+	}
+	return data
+}
+
+// 只调用父类无参构造方法的 <init>
+func defaultInit(class *testClass) {
+	class.method(AccessPublic, "<init>", "()V", newTestCode(1, 1).op(0x2A).
+		op16(0xB7, class.ref(ConstMethod, class.super, "<init>", "()V")).op(0xB1))
+}
+
+// 测试使用的最小运行时，只包含虚拟机启动与抛出异常需要的类
+var testThrowables = [][2]string{
+	{"java/lang/Throwable", "java/lang/Object"},
+	{"java/lang/Exception", "java/lang/Throwable"},
+	{"java/lang/Error", "java/lang/Throwable"},
+	{"java/lang/RuntimeException", "java/lang/Exception"},
+	{"java/lang/CloneNotSupportedException", "java/lang/Exception"},
+	{"java/lang/ClassNotFoundException", "java/lang/Exception"},
+	{"java/lang/ArithmeticException", "java/lang/RuntimeException"},
+	{"java/lang/NullPointerException", "java/lang/RuntimeException"},
+	{"java/lang/ClassCastException", "java/lang/RuntimeException"},
+	{"java/lang/ArrayStoreException", "java/lang/RuntimeException"},
+	{"java/lang/NegativeArraySizeException", "java/lang/RuntimeException"},
+	{"java/lang/IllegalArgumentException", "java/lang/RuntimeException"},
+	{"java/lang/IllegalMonitorStateException", "java/lang/RuntimeException"},
+	{"java/lang/IndexOutOfBoundsException", "java/lang/RuntimeException"},
+	{"java/lang/ArrayIndexOutOfBoundsException", "java/lang/IndexOutOfBoundsException"},
+	{"java/lang/LinkageError", "java/lang/Error"},
+	{"java/lang/NoClassDefFoundError", "java/lang/LinkageError"},
+	{"java/lang/ClassFormatError", "java/lang/LinkageError"},
+	{"java/lang/BootstrapMethodError", "java/lang/LinkageError"},
+	{"java/lang/UnsatisfiedLinkError", "java/lang/LinkageError"},
+	{"java/lang/IncompatibleClassChangeError", "java/lang/LinkageError"},
+	{"java/lang/AbstractMethodError", "java/lang/IncompatibleClassChangeError"},
+	{"java/lang/InstantiationError", "java/lang/IncompatibleClassChangeError"},
+	{"java/lang/IllegalAccessError", "java/lang/IncompatibleClassChangeError"},
+	{"java/lang/NoSuchFieldError", "java/lang/IncompatibleClassChangeError"},
+	{"java/lang/NoSuchMethodError", "java/lang/IncompatibleClassChangeError"},
+	{"java/lang/VirtualMachineError", "java/lang/Error"},
+	{"java/lang/StackOverflowError", "java/lang/VirtualMachineError"},
+	{"java/lang/OutOfMemoryError", "java/lang/VirtualMachineError"},
+}
+
+func testRuntime() []*testClass {
+	object := newTestClass("java/lang/Object", "")
+	object.method(AccessPublic, "<init>", "()V", newTestCode(0, 1).op(0xB1))
+	object.method(AccessPublic|AccessNative, "hashCode", "()I", nil)
+	object.method(AccessProtected|AccessNative, "clone", "()Ljava/lang/Object;", nil)
+	object.method(AccessPublic|AccessFinal|AccessNative, "notify", "()V", nil)
+	object.method(AccessPublic|AccessFinal|AccessNative, "notifyAll", "()V", nil)
+	object.method(AccessPublic|AccessFinal|AccessNative, "wait", "(J)V", nil)
+	class := newTestClass("java/lang/Class", "java/lang/Object")
+	class.access |= AccessFinal
+	str := newTestClass("java/lang/String", "java/lang/Object")
+	str.access |= AccessFinal
+	str.field(AccessPrivate|AccessFinal, "value", "[C")
+	res := []*testClass{object, class, str}
+	for _, name := range []string{"java/lang/Cloneable", "java/io/Serializable"} {
+		item := newTestClass(name, "java/lang/Object")
+		item.access = AccessPublic | AccessInterface | AccessAbstract
+		res = append(res, item)
+	}
+	for _, item := range testThrowables {
+		throwable := newTestClass(item[0], item[1])
+		if item[0] == "java/lang/Throwable" {
+			throwable.field(AccessPrivate, "detailMessage", "Ljava/lang/String;")
+			throwable.field(AccessPrivate, "cause", "Ljava/lang/Throwable;")
+			defaultInit(throwable)
+			throwable.method(AccessPublic, "<init>", "(Ljava/lang/String;)V", newTestCode(2, 2).
+				op(0x2A).op16(0xB7, throwable.ref(ConstMethod, "java/lang/Object", "<init>", "()V")).
+				op(0x2A, 0x2B).op16(0xB5, throwable.ref(ConstField, item[0], "detailMessage", "Ljava/lang/String;")).op(0xB1))
+			throwable.method(AccessPublic, "getMessage", "()Ljava/lang/String;", newTestCode(1, 1).
+				op(0x2A).op16(0xB4, throwable.ref(ConstField, item[0], "detailMessage", "Ljava/lang/String;")).op(0xB0))
+		} else {
+			defaultInit(throwable)
+			throwable.method(AccessPublic, "<init>", "(Ljava/lang/String;)V", newTestCode(2, 2).
+				op(0x2A, 0x2B).op16(0xB7, throwable.ref(ConstMethod, item[1], "<init>", "(Ljava/lang/String;)V")).op(0xB1))
+		}
+		res = append(res, throwable)
+	}
+	return res
+}
+
+// 测试运行时与 classes 写入临时目录作为启动类路径
+func newTestVM(tb testing.TB, classes ...*testClass) *VM {
+	tb.Helper()
+	dir := tb.TempDir()
+	for _, item := range append(testRuntime(), classes...) {
+		item.write(tb, dir)
+	}
+	InitInstruction()
+	InitNativeFunc()
+	vm := NewVM([]string{dir}, nil)
+	vm.HashCode = HashSequential
+	return vm
+}
+
+// 在新线程中执行静态方法，与本地方法调用 java 方法一样通过临时栈帧传递参数与返回值，未捕获的 java 异常返回给调用方
+func runStatic(vm *VM, className string, name string, desc string, args ...Value) (res Value, exception *JavaException) {
+	class := vm.Loader.LoadClass(className)
+	method := class.GetMethod(name, desc)
+	thread := NewThread(vm)
+	frame := &Frame{Method: method, Stack: NewStack[Value](len(args) + 2)}
+	thread.Stack.Push(frame)
+	defer func() {
+		if err := recover(); err != nil {
+			var ok bool
+			if exception, ok = err.(*JavaException); !ok {
+				panic(err)
+			}
+		}
+	}()
+	InitClass(thread, class)
+	for _, arg := range args {
+		frame.Push(arg)
+	}
+	CallMethod(thread, method)
+	switch desc[len(desc)-1] {
+	case 'V':
+	case 'J', 'D':
+		res = frame.Pop2()
+	default:
+		res = frame.Pop()
+	}
+	return res, nil
+}
@@ -5,5 +5,9 @@
 package main
 
 const (
-	MaxStackDepth = 1024
+	DefaultStackDepth = 1024      // -Xss 的默认值
+	StackDepthLimit   = 1 << 20   // -Xss 的上限，线程创建时按照最大深度分配栈
+	StackReserve      = 64        // 超过最大调用深度后创建 StackOverflowError 可以使用的栈帧数
+	HeapReserve       = 64 * 1024 // 只在创建 OutOfMemoryError 时使用的堆空间，-Xmx 不能小于它
+	MaxStackTrace     = 1024      // 异常最多记录的栈帧数，与 hotspot 的 MaxJavaStackTraceDepth 一致
 )
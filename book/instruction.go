@@ -6,7 +6,8 @@ package main
 
 import (
 	"fmt"
-	"math"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -30,12 +31,36 @@ func InstructionDConst0(thread *Thread, class *Class, code *Code, pc int) int {
 	return pc
 }
 
+func InstructionDConst1(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	frame.Push2(NewDouble(1))
+	return pc
+}
+
 func InstructionFConst0(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	frame.Push(NewFloat(0))
 	return pc
 }
 
+func InstructionFConst1(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	frame.Push(NewFloat(1))
+	return pc
+}
+
+func InstructionFConst2(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	frame.Push(NewFloat(2))
+	return pc
+}
+
+func InstructionIConstM1(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	frame.Push(NewInteger(-1))
+	return pc
+}
+
 func InstructionIConst0(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	frame.Push(NewInteger(0))
@@ -86,13 +111,13 @@ func InstructionLConst1(thread *Thread, class *Class, code *Code, pc int) int {
 
 func InstructionBIPush(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
-	frame.Push(NewInteger(int32(ParseU8(code.Code, pc))))
+	frame.Push(NewInteger(int32(int8(ParseU8(code.Code, pc))))) // 有符号扩展
 	return pc + 1
 }
 
 func InstructionSIPush(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
-	frame.Push(NewInteger(int32(ParseU16(code.Code, pc))))
+	frame.Push(NewInteger(int32(ParseI16(code.Code, pc))))
 	return pc + 2
 }
 
@@ -112,12 +137,9 @@ func toJavaName(name string) string {
 	return strings.ReplaceAll(name, "/", ".")
 }
 
-func makeClassObject(thread *Thread, name string) *Object {
-	class := thread.Loader.LoadClass("java/lang/Class")
-	field := class.GetField("name", "Ljava/lang/String;")
-	fields := make([]*Value, class.InstSlotCount)
-	fields[field.SlotID] = NewString(thread, name)
-	return &Object{Class: class, Fields: fields}
+// Class 对象对应的类
+func mirrorClass(mirror *Object) *Class {
+	return mirror.Extra.(*Class)
 }
 
 func ldc(thread *Thread, class *Class, index int) {
@@ -134,12 +156,9 @@ func ldc(thread *Thread, class *Class, index int) {
 		frame.Push2(NewDouble(temp.Double))
 	case ConstString:
 		value := class.GetString(temp.Index)
-		frame.Push(NewString(thread, value))
+		frame.Push(thread.VM.Strings.InternString(value))
 	case ConstClass:
-		// 获取类名称
-		name := toJavaName(class.GetString(temp.Index))
-		// 构造类对象
-		frame.Push(NewObject(makeClassObject(thread, name)))
+		frame.Push(NewObject(ResolveClass(thread, class.GetString(temp.Index)).Mirror))
 	default:
 		panic(fmt.Sprintf("unknown type: %v", temp.Type))
 	}
@@ -209,19 +228,87 @@ func Instruction2Load3(thread *Thread, class *Class, code *Code, pc int) int {
 	return pc
 }
 
-func InstructionALoad(thread *Thread, class *Class, code *Code, pc int) int {
+// 校验数组访问，数组为 null 或下标越界时抛出 java 异常
+func checkArrayIndex(thread *Thread, arr *Object, index int32) {
+	if arr == nil {
+		ThrowNew(thread, "java/lang/NullPointerException", "")
+	}
+	if index < 0 || int(index) >= arr.ArrayLength() {
+		ThrowNew(thread, "java/lang/ArrayIndexOutOfBoundsException", strconv.Itoa(int(index)))
+	}
+}
+
+func InstructionIALoad(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	index := frame.Pop().Integer()
+	arr := frame.Pop().Object
+	checkArrayIndex(thread, arr, index)
+	frame.Push(NewInteger(arr.ArrayData.([]int32)[index]))
+	return pc
+}
+
+func InstructionLALoad(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	index := frame.Pop().Integer()
+	arr := frame.Pop().Object
+	checkArrayIndex(thread, arr, index)
+	frame.Push2(NewLong(arr.ArrayData.([]int64)[index]))
+	return pc
+}
+
+func InstructionFALoad(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	index := frame.Pop().Integer()
+	arr := frame.Pop().Object
+	checkArrayIndex(thread, arr, index)
+	frame.Push(NewFloat(arr.ArrayData.([]float32)[index]))
+	return pc
+}
+
+func InstructionDALoad(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	index := frame.Pop().Integer()
+	arr := frame.Pop().Object
+	checkArrayIndex(thread, arr, index)
+	frame.Push2(NewDouble(arr.ArrayData.([]float64)[index]))
+	return pc
+}
+
+func InstructionAALoad(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	index := frame.Pop().Integer()
+	arr := frame.Pop().Object
+	checkArrayIndex(thread, arr, index)
+	frame.Push(NewObject(arr.ArrayData.([]*Object)[index]))
+	return pc
+}
+
+// boolean 与 byte 数组共用，都是有符号扩展
+func InstructionBALoad(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
-	index := frame.Pop().Integer
+	index := frame.Pop().Integer()
 	arr := frame.Pop().Object
-	frame.Push(arr.ArrayData[index])
+	checkArrayIndex(thread, arr, index)
+	frame.Push(NewInteger(int32(arr.ArrayData.([]int8)[index])))
 	return pc
 }
 
-func Instruction2ALoad(thread *Thread, class *Class, code *Code, pc int) int {
+// char 是无符号的
+func InstructionCALoad(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
-	index := frame.Pop().Integer
+	index := frame.Pop().Integer()
 	arr := frame.Pop().Object
-	frame.Push2(arr.ArrayData[index])
+	checkArrayIndex(thread, arr, index)
+	frame.Push(NewInteger(int32(arr.ArrayData.([]uint16)[index])))
+	return pc
+}
+
+func InstructionSALoad(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	index := frame.Pop().Integer()
+	arr := frame.Pop().Object
+	checkArrayIndex(thread, arr, index)
+	frame.Push(NewInteger(int32(arr.ArrayData.([]int16)[index])))
 	return pc
 }
 
@@ -260,7 +347,7 @@ func InstructionStore3(thread *Thread, class *Class, code *Code, pc int) int {
 
 func instruction2Store(thread *Thread, index int) {
 	frame := thread.Peek()
-	frame.Set(frame.Pop2(), index)
+	frame.Set2(frame.Pop2(), index)
 }
 
 func Instruction2Store(thread *Thread, class *Class, code *Code, pc int) int {
@@ -289,21 +376,93 @@ func Instruction2Store3(thread *Thread, class *Class, code *Code, pc int) int {
 	return pc
 }
 
-func InstructionAStore(thread *Thread, class *Class, code *Code, pc int) int {
+func InstructionIAStore(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
-	val := frame.Pop()
-	index := frame.Pop().Integer
+	val := frame.Pop().Integer()
+	index := frame.Pop().Integer()
 	arr := frame.Pop().Object
-	arr.ArrayData[index] = val
+	checkArrayIndex(thread, arr, index)
+	arr.ArrayData.([]int32)[index] = val
 	return pc
 }
 
-func Instruction2AStore(thread *Thread, class *Class, code *Code, pc int) int {
+func InstructionLAStore(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
-	val := frame.Pop2()
-	index := frame.Pop().Integer
+	val := frame.Pop2().Long()
+	index := frame.Pop().Integer()
+	arr := frame.Pop().Object
+	checkArrayIndex(thread, arr, index)
+	arr.ArrayData.([]int64)[index] = val
+	return pc
+}
+
+func InstructionFAStore(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	val := frame.Pop().Float()
+	index := frame.Pop().Integer()
 	arr := frame.Pop().Object
-	arr.ArrayData[index] = val
+	checkArrayIndex(thread, arr, index)
+	arr.ArrayData.([]float32)[index] = val
+	return pc
+}
+
+func InstructionDAStore(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	val := frame.Pop2().Double()
+	index := frame.Pop().Integer()
+	arr := frame.Pop().Object
+	checkArrayIndex(thread, arr, index)
+	arr.ArrayData.([]float64)[index] = val
+	return pc
+}
+
+func InstructionAAStore(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	val := frame.Pop().Object
+	index := frame.Pop().Integer()
+	arr := frame.Pop().Object
+	checkArrayIndex(thread, arr, index)
+	if val != nil { // 元素类型需要在运行时检查
+		component := thread.Loader.LoadClass(ComponentClassName(arr.Class.GetName()))
+		if !instanceOf(thread, val.Class, component) {
+			ThrowNew(thread, "java/lang/ArrayStoreException", toJavaName(val.Class.GetName()))
+		}
+	}
+	arr.ArrayData.([]*Object)[index] = val
+	return pc
+}
+
+// boolean 数组只保留最低位，byte 数组截断为 8 位
+func InstructionBAStore(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	val := frame.Pop().Integer()
+	index := frame.Pop().Integer()
+	arr := frame.Pop().Object
+	checkArrayIndex(thread, arr, index)
+	if arr.Class.GetName() == "[Z" {
+		val &= 1
+	}
+	arr.ArrayData.([]int8)[index] = int8(val)
+	return pc
+}
+
+func InstructionCAStore(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	val := frame.Pop().Integer()
+	index := frame.Pop().Integer()
+	arr := frame.Pop().Object
+	checkArrayIndex(thread, arr, index)
+	arr.ArrayData.([]uint16)[index] = uint16(val)
+	return pc
+}
+
+func InstructionSAStore(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	val := frame.Pop().Integer()
+	index := frame.Pop().Integer()
+	arr := frame.Pop().Object
+	checkArrayIndex(thread, arr, index)
+	arr.ArrayData.([]int16)[index] = int16(val)
 	return pc
 }
 
@@ -318,6 +477,7 @@ func InstructionPop(thread *Thread, class *Class, code *Code, pc int) int {
 func InstructionPop2(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	frame.Pop()
+	frame.Pop()
 	return pc
 }
 
@@ -405,7 +565,7 @@ func InstructionDAdd(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val1 := frame.Pop2()
 	val2 := frame.Pop2()
-	frame.Push2(NewDouble(val1.Double + val2.Double))
+	frame.Push2(NewDouble(DoubleAdd(val2.Double(), val1.Double())))
 	return pc
 }
 
@@ -413,7 +573,7 @@ func InstructionFAdd(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val1 := frame.Pop()
 	val2 := frame.Pop()
-	frame.Push(NewFloat(val1.Float + val2.Float))
+	frame.Push(NewFloat(FloatAdd(val2.Float(), val1.Float())))
 	return pc
 }
 
@@ -421,7 +581,7 @@ func InstructionLAdd(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val1 := frame.Pop2()
 	val2 := frame.Pop2()
-	frame.Push2(NewLong(val1.Long + val2.Long))
+	frame.Push2(NewLong(val1.Long() + val2.Long()))
 	return pc
 }
 
@@ -429,7 +589,7 @@ func InstructionIAdd(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val1 := frame.Pop()
 	val2 := frame.Pop()
-	frame.Push(NewInteger(val1.Integer + val2.Integer))
+	frame.Push(NewInteger(val1.Integer() + val2.Integer()))
 	return pc
 }
 
@@ -437,7 +597,7 @@ func InstructionLAnd(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val1 := frame.Pop2()
 	val2 := frame.Pop2()
-	frame.Push2(NewLong(val1.Long & val2.Long))
+	frame.Push2(NewLong(val1.Long() & val2.Long()))
 	return pc
 }
 
@@ -445,7 +605,7 @@ func InstructionIAnd(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val1 := frame.Pop()
 	val2 := frame.Pop()
-	frame.Push(NewInteger(val1.Integer & val2.Integer))
+	frame.Push(NewInteger(val1.Integer() & val2.Integer()))
 	return pc
 }
 
@@ -453,7 +613,7 @@ func InstructionLOr(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val1 := frame.Pop2()
 	val2 := frame.Pop2()
-	frame.Push2(NewLong(val1.Long | val2.Long))
+	frame.Push2(NewLong(val1.Long() | val2.Long()))
 	return pc
 }
 
@@ -461,7 +621,7 @@ func InstructionIOr(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val1 := frame.Pop()
 	val2 := frame.Pop()
-	frame.Push(NewInteger(val1.Integer | val2.Integer))
+	frame.Push(NewInteger(val1.Integer() | val2.Integer()))
 	return pc
 }
 
@@ -469,7 +629,7 @@ func InstructionDDiv(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val1 := frame.Pop2()
 	val2 := frame.Pop2()
-	frame.Push2(NewDouble(val2.Double / val1.Double))
+	frame.Push2(NewDouble(DoubleDiv(val2.Double(), val1.Double())))
 	return pc
 }
 
@@ -477,7 +637,7 @@ func InstructionFDiv(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val1 := frame.Pop()
 	val2 := frame.Pop()
-	frame.Push(NewFloat(val2.Float / val1.Float))
+	frame.Push(NewFloat(FloatDiv(val2.Float(), val1.Float())))
 	return pc
 }
 
@@ -485,7 +645,10 @@ func InstructionLDiv(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val1 := frame.Pop2()
 	val2 := frame.Pop2()
-	frame.Push2(NewLong(val2.Long / val1.Long))
+	if val1.Long() == 0 {
+		ThrowNew(thread, "java/lang/ArithmeticException", "/ by zero")
+	}
+	frame.Push2(NewLong(LongDiv(val2.Long(), val1.Long())))
 	return pc
 }
 
@@ -493,15 +656,22 @@ func InstructionIDiv(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val1 := frame.Pop()
 	val2 := frame.Pop()
-	frame.Push(NewInteger(val2.Integer / val1.Integer))
+	if val1.Integer() == 0 {
+		ThrowNew(thread, "java/lang/ArithmeticException", "/ by zero")
+	}
+	frame.Push(NewInteger(IntDiv(val2.Integer(), val1.Integer())))
 	return pc
 }
 
-func InstructionIInc(thread *Thread, class *Class, code *Code, pc int) int {
-	index := int(ParseU8(code.Code, pc))
-	change := ParseU8(code.Code, pc+1)
+func instructionIInc(thread *Thread, index int, change int32) {
 	frame := thread.Peek()
-	frame.Set(NewInteger(frame.Get(index).Integer+int32(change)), index)
+	frame.Set(NewInteger(frame.Get(index).Integer()+change), index)
+}
+
+func InstructionIInc(thread *Thread, class *Class, code *Code, pc int) int {
+	index := ParseU8(code.Code, pc)
+	change := int8(ParseU8(code.Code, pc+1)) // 有符号
+	instructionIInc(thread, int(index), int32(change))
 	return pc + 2
 }
 
@@ -509,7 +679,7 @@ func InstructionDMul(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val1 := frame.Pop2()
 	val2 := frame.Pop2()
-	frame.Push2(NewDouble(val2.Double * val1.Double))
+	frame.Push2(NewDouble(DoubleMul(val2.Double(), val1.Double())))
 	return pc
 }
 
@@ -517,7 +687,7 @@ func InstructionFMul(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val1 := frame.Pop()
 	val2 := frame.Pop()
-	frame.Push(NewFloat(val2.Float * val1.Float))
+	frame.Push(NewFloat(FloatMul(val2.Float(), val1.Float())))
 	return pc
 }
 
@@ -525,7 +695,7 @@ func InstructionLMul(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val1 := frame.Pop2()
 	val2 := frame.Pop2()
-	frame.Push2(NewLong(val2.Long * val1.Long))
+	frame.Push2(NewLong(val2.Long() * val1.Long()))
 	return pc
 }
 
@@ -533,35 +703,35 @@ func InstructionIMul(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val1 := frame.Pop()
 	val2 := frame.Pop()
-	frame.Push(NewInteger(val2.Integer * val1.Integer))
+	frame.Push(NewInteger(val2.Integer() * val1.Integer()))
 	return pc
 }
 
 func InstructionDNeg(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val := frame.Pop2()
-	frame.Push2(NewDouble(-val.Double))
+	frame.Push2(NewDouble(-val.Double()))
 	return pc
 }
 
 func InstructionFNeg(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val := frame.Pop()
-	frame.Push(NewFloat(-val.Float))
+	frame.Push(NewFloat(-val.Float()))
 	return pc
 }
 
 func InstructionLNeg(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val := frame.Pop2()
-	frame.Push2(NewLong(-val.Long))
+	frame.Push2(NewLong(-val.Long()))
 	return pc
 }
 
 func InstructionINeg(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val := frame.Pop()
-	frame.Push(NewInteger(-val.Integer))
+	frame.Push(NewInteger(-val.Integer()))
 	return pc
 }
 
@@ -569,7 +739,7 @@ func InstructionDMod(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val1 := frame.Pop2()
 	val2 := frame.Pop2()
-	frame.Push2(NewDouble(math.Mod(val2.Double, val1.Double)))
+	frame.Push2(NewDouble(DoubleRem(val2.Double(), val1.Double())))
 	return pc
 }
 
@@ -577,7 +747,7 @@ func InstructionFMod(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val1 := frame.Pop()
 	val2 := frame.Pop()
-	frame.Push(NewFloat(float32(math.Mod(float64(val2.Float), float64(val1.Float)))))
+	frame.Push(NewFloat(FloatRem(val2.Float(), val1.Float())))
 	return pc
 }
 
@@ -585,7 +755,10 @@ func InstructionLMod(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val1 := frame.Pop2()
 	val2 := frame.Pop2()
-	frame.Push2(NewLong(val2.Long % val1.Long))
+	if val1.Long() == 0 {
+		ThrowNew(thread, "java/lang/ArithmeticException", "/ by zero")
+	}
+	frame.Push2(NewLong(LongRem(val2.Long(), val1.Long())))
 	return pc
 }
 
@@ -593,7 +766,10 @@ func InstructionIMod(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val1 := frame.Pop()
 	val2 := frame.Pop()
-	frame.Push(NewInteger(val2.Integer % val1.Integer))
+	if val1.Integer() == 0 {
+		ThrowNew(thread, "java/lang/ArithmeticException", "/ by zero")
+	}
+	frame.Push(NewInteger(IntRem(val2.Integer(), val1.Integer())))
 	return pc
 }
 
@@ -601,7 +777,7 @@ func InstructionDSub(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val1 := frame.Pop2()
 	val2 := frame.Pop2()
-	frame.Push2(NewDouble(val2.Double - val1.Double))
+	frame.Push2(NewDouble(DoubleSub(val2.Double(), val1.Double())))
 	return pc
 }
 
@@ -609,7 +785,7 @@ func InstructionFSub(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val1 := frame.Pop()
 	val2 := frame.Pop()
-	frame.Push(NewFloat(val2.Float - val1.Float))
+	frame.Push(NewFloat(FloatSub(val2.Float(), val1.Float())))
 	return pc
 }
 
@@ -617,7 +793,7 @@ func InstructionLSub(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val1 := frame.Pop2()
 	val2 := frame.Pop2()
-	frame.Push2(NewLong(val2.Long - val1.Long))
+	frame.Push2(NewLong(val2.Long() - val1.Long()))
 	return pc
 }
 
@@ -625,7 +801,7 @@ func InstructionISub(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val1 := frame.Pop()
 	val2 := frame.Pop()
-	frame.Push(NewInteger(val2.Integer - val1.Integer))
+	frame.Push(NewInteger(val2.Integer() - val1.Integer()))
 	return pc
 }
 
@@ -633,7 +809,7 @@ func InstructionLXor(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val1 := frame.Pop2()
 	val2 := frame.Pop2()
-	frame.Push2(NewLong(val2.Long ^ val1.Long))
+	frame.Push2(NewLong(val2.Long() ^ val1.Long()))
 	return pc
 }
 
@@ -641,17 +817,147 @@ func InstructionIXor(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val1 := frame.Pop()
 	val2 := frame.Pop()
-	frame.Push(NewInteger(val2.Integer ^ val1.Integer))
+	frame.Push(NewInteger(val2.Integer() ^ val1.Integer()))
+	return pc
+}
+
+func InstructionIShl(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	val1 := frame.Pop()
+	val2 := frame.Pop()
+	frame.Push(NewInteger(IntShl(val2.Integer(), val1.Integer())))
+	return pc
+}
+
+func InstructionLShl(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	val1 := frame.Pop()
+	val2 := frame.Pop2()
+	frame.Push2(NewLong(LongShl(val2.Long(), val1.Integer())))
+	return pc
+}
+
+func InstructionIShr(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	val1 := frame.Pop()
+	val2 := frame.Pop()
+	frame.Push(NewInteger(IntShr(val2.Integer(), val1.Integer())))
+	return pc
+}
+
+func InstructionLShr(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	val1 := frame.Pop()
+	val2 := frame.Pop2()
+	frame.Push2(NewLong(LongShr(val2.Long(), val1.Integer())))
+	return pc
+}
+
+func InstructionIUShr(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	val1 := frame.Pop()
+	val2 := frame.Pop()
+	frame.Push(NewInteger(IntUShr(val2.Integer(), val1.Integer())))
+	return pc
+}
+
+func InstructionLUShr(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	val1 := frame.Pop()
+	val2 := frame.Pop2()
+	frame.Push2(NewLong(LongUShr(val2.Long(), val1.Integer())))
 	return pc
 }
 
 //======================conversions=======================
 
+func InstructionI2L(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	frame.Push2(NewLong(int64(frame.Pop().Integer())))
+	return pc
+}
+
+func InstructionI2F(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	frame.Push(NewFloat(float32(frame.Pop().Integer())))
+	return pc
+}
+
+func InstructionI2D(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	frame.Push2(NewDouble(float64(frame.Pop().Integer())))
+	return pc
+}
+
+func InstructionL2I(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	frame.Push(NewInteger(int32(frame.Pop2().Long())))
+	return pc
+}
+
+func InstructionL2F(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	frame.Push(NewFloat(float32(frame.Pop2().Long())))
+	return pc
+}
+
+func InstructionL2D(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	frame.Push2(NewDouble(float64(frame.Pop2().Long())))
+	return pc
+}
+
+func InstructionF2I(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	frame.Push(NewInteger(FloatToInt(float64(frame.Pop().Float()))))
+	return pc
+}
+
+func InstructionF2L(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	frame.Push2(NewLong(FloatToLong(float64(frame.Pop().Float()))))
+	return pc
+}
+
+func InstructionF2D(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	frame.Push2(NewDouble(float64(frame.Pop().Float())))
+	return pc
+}
+
+func InstructionD2I(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	frame.Push(NewInteger(FloatToInt(frame.Pop2().Double())))
+	return pc
+}
+
+func InstructionD2L(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	frame.Push2(NewLong(FloatToLong(frame.Pop2().Double())))
+	return pc
+}
+
+func InstructionD2F(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	frame.Push(NewFloat(float32(frame.Pop2().Double())))
+	return pc
+}
+
 func InstructionI2B(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
-	val := frame.Pop()
-	val.Integer &= 1
-	frame.Push(val)
+	frame.Push(NewInteger(int32(int8(frame.Pop().Integer()))))
+	return pc
+}
+
+func InstructionI2C(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	frame.Push(NewInteger(int32(uint16(frame.Pop().Integer()))))
+	return pc
+}
+
+func InstructionI2S(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	frame.Push(NewInteger(int32(int16(frame.Pop().Integer()))))
 	return pc
 }
 
@@ -660,7 +966,7 @@ func InstructionI2B(thread *Thread, class *Class, code *Code, pc int) int {
 func InstructionIfEq(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	offset := ParseI16(code.Code, pc)
-	if frame.Pop().Integer == 0 {
+	if frame.Pop().Integer() == 0 {
 		return pc + int(offset) - 1
 	}
 	return pc + 2
@@ -669,7 +975,7 @@ func InstructionIfEq(thread *Thread, class *Class, code *Code, pc int) int {
 func InstructionIfNe(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	offset := ParseI16(code.Code, pc)
-	if frame.Pop().Integer != 0 {
+	if frame.Pop().Integer() != 0 {
 		return pc + int(offset) - 1
 	}
 	return pc + 2
@@ -678,7 +984,34 @@ func InstructionIfNe(thread *Thread, class *Class, code *Code, pc int) int {
 func InstructionIfGt(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	offset := ParseI16(code.Code, pc)
-	if frame.Pop().Integer > 0 {
+	if frame.Pop().Integer() > 0 {
+		return pc + int(offset) - 1
+	}
+	return pc + 2
+}
+
+func InstructionIfLt(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	offset := ParseI16(code.Code, pc)
+	if frame.Pop().Integer() < 0 {
+		return pc + int(offset) - 1
+	}
+	return pc + 2
+}
+
+func InstructionIfGe(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	offset := ParseI16(code.Code, pc)
+	if frame.Pop().Integer() >= 0 {
+		return pc + int(offset) - 1
+	}
+	return pc + 2
+}
+
+func InstructionIfLe(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	offset := ParseI16(code.Code, pc)
+	if frame.Pop().Integer() <= 0 {
 		return pc + int(offset) - 1
 	}
 	return pc + 2
@@ -688,9 +1021,9 @@ func InstructionLCmp(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val1 := frame.Pop2()
 	val2 := frame.Pop2()
-	if val2.Long > val1.Long {
+	if val2.Long() > val1.Long() {
 		frame.Push(NewInteger(1))
-	} else if val2.Long < val1.Long {
+	} else if val2.Long() < val1.Long() {
 		frame.Push(NewInteger(-1))
 	} else {
 		frame.Push(NewInteger(0))
@@ -698,12 +1031,66 @@ func InstructionLCmp(thread *Thread, class *Class, code *Code, pc int) int {
 	return pc
 }
 
+func InstructionFCmpL(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	val1 := frame.Pop()
+	val2 := frame.Pop()
+	frame.Push(NewInteger(FloatCmp(float64(val2.Float()), float64(val1.Float()), -1)))
+	return pc
+}
+
+func InstructionFCmpG(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	val1 := frame.Pop()
+	val2 := frame.Pop()
+	frame.Push(NewInteger(FloatCmp(float64(val2.Float()), float64(val1.Float()), 1)))
+	return pc
+}
+
+func InstructionDCmpL(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	val1 := frame.Pop2()
+	val2 := frame.Pop2()
+	frame.Push(NewInteger(FloatCmp(val2.Double(), val1.Double(), -1)))
+	return pc
+}
+
+func InstructionDCmpG(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	val1 := frame.Pop2()
+	val2 := frame.Pop2()
+	frame.Push(NewInteger(FloatCmp(val2.Double(), val1.Double(), 1)))
+	return pc
+}
+
+func InstructionIfICmpEq(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	val1 := frame.Pop()
+	val2 := frame.Pop()
+	offset := ParseI16(code.Code, pc)
+	if val2.Integer() == val1.Integer() {
+		return pc + int(offset) - 1
+	}
+	return pc + 2
+}
+
+func InstructionIfICmpLt(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	val1 := frame.Pop()
+	val2 := frame.Pop()
+	offset := ParseI16(code.Code, pc)
+	if val2.Integer() < val1.Integer() {
+		return pc + int(offset) - 1
+	}
+	return pc + 2
+}
+
 func InstructionIfICmpNe(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val1 := frame.Pop()
 	val2 := frame.Pop()
 	offset := ParseI16(code.Code, pc)
-	if val2.Integer != val1.Integer {
+	if val2.Integer() != val1.Integer() {
 		return pc + int(offset) - 1
 	}
 	return pc + 2
@@ -714,7 +1101,7 @@ func InstructionIfICmpGt(thread *Thread, class *Class, code *Code, pc int) int {
 	val1 := frame.Pop()
 	val2 := frame.Pop()
 	offset := ParseI16(code.Code, pc)
-	if val2.Integer > val1.Integer {
+	if val2.Integer() > val1.Integer() {
 		return pc + int(offset) - 1
 	}
 	return pc + 2
@@ -725,7 +1112,7 @@ func InstructionIfICmpGe(thread *Thread, class *Class, code *Code, pc int) int {
 	val1 := frame.Pop()
 	val2 := frame.Pop()
 	offset := ParseI16(code.Code, pc)
-	if val2.Integer >= val1.Integer {
+	if val2.Integer() >= val1.Integer() {
 		return pc + int(offset) - 1
 	}
 	return pc + 2
@@ -736,7 +1123,7 @@ func InstructionIfICmpLe(thread *Thread, class *Class, code *Code, pc int) int {
 	val1 := frame.Pop()
 	val2 := frame.Pop()
 	offset := ParseI16(code.Code, pc)
-	if val2.Integer <= val1.Integer {
+	if val2.Integer() <= val1.Integer() {
 		return pc + int(offset) - 1
 	}
 	return pc + 2
@@ -747,6 +1134,17 @@ func InstructionGoTo(thread *Thread, class *Class, code *Code, pc int) int {
 	return pc - 1 + int(offset)
 }
 
+func InstructionIfACmpEq(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	val1 := frame.Pop()
+	val2 := frame.Pop()
+	offset := ParseI16(code.Code, pc)
+	if val2.Object == val1.Object {
+		return pc + int(offset) - 1
+	}
+	return pc + 2
+}
+
 func InstructionIfACmpNe(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val1 := frame.Pop()
@@ -760,23 +1158,78 @@ func InstructionIfACmpNe(thread *Thread, class *Class, code *Code, pc int) int {
 
 //====================control======================
 
+// jsr ret 是老版本编译器用于实现 finally 的，返回地址作为 returnAddress 类型压栈
+func InstructionJsr(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	frame.Push(NewAddress(pc + 2))
+	return pc - 1 + int(ParseI16(code.Code, pc))
+}
+
+func InstructionJsrW(thread *Thread, class *Class, code *Code, pc int) int {
+	frame := thread.Peek()
+	frame.Push(NewAddress(pc + 4))
+	return pc - 1 + int(ParseI32(code.Code, pc))
+}
+
+func InstructionRet(thread *Thread, class *Class, code *Code, pc int) int {
+	index := ParseU8(code.Code, pc)
+	return int(thread.Peek().Get(int(index)).Integer())
+}
+
+func InstructionGoToW(thread *Thread, class *Class, code *Code, pc int) int {
+	offset := ParseI32(code.Code, pc)
+	return pc - 1 + int(offset)
+}
+
+// 操作数从方法开始 4 字节对齐，跳转偏移都是相对于指令本身的
+func InstructionTableSwitch(thread *Thread, class *Class, code *Code, pc int) int {
+	base := pc - 1
+	pc = (pc + 3) &^ 3 // 跳过填充
+	defaultOffset := ParseI32(code.Code, pc)
+	low := ParseI32(code.Code, pc+4)
+	high := ParseI32(code.Code, pc+8)
+	key := thread.Peek().Pop().Integer()
+	if key < low || key > high {
+		return base + int(defaultOffset)
+	}
+	return base + int(ParseI32(code.Code, pc+12+int(key-low)*4))
+}
+
+func InstructionLookupSwitch(thread *Thread, class *Class, code *Code, pc int) int {
+	base := pc - 1
+	pc = (pc + 3) &^ 3 // 跳过填充
+	defaultOffset := ParseI32(code.Code, pc)
+	count := int(ParseI32(code.Code, pc+4))
+	key := thread.Peek().Pop().Integer()
+	// match-offset 对按照 match 升序排列，可以二分查找
+	pairs := pc + 8
+	index := sort.Search(count, func(i int) bool {
+		return ParseI32(code.Code, pairs+i*8) >= key
+	})
+	if index < count && ParseI32(code.Code, pairs+index*8) == key {
+		return base + int(ParseI32(code.Code, pairs+index*8+4))
+	}
+	return base + int(defaultOffset)
+}
+
+// 弹出栈帧后解释器继续执行调用方，返回的 pc 不再使用
 func InstructionReturn(thread *Thread, class *Class, code *Code, pc int) int {
 	thread.Pop()
-	return 0xFFFFFFFF // return 强制退出方法
+	return pc
 }
 
 func InstructionReturn1(thread *Thread, class *Class, code *Code, pc int) int {
 	oldFrame := thread.Pop()
 	frame := thread.Peek()
 	frame.Push(oldFrame.Pop())
-	return 0xFFFFFFFF // return 强制退出方法
+	return pc
 }
 
 func InstructionReturn2(thread *Thread, class *Class, code *Code, pc int) int {
 	oldFrame := thread.Pop()
 	frame := thread.Peek()
 	frame.Push2(oldFrame.Pop2())
-	return 0xFFFFFFFF // return 强制退出方法
+	return pc
 }
 
 //=====================references======================
@@ -784,35 +1237,53 @@ func InstructionReturn2(thread *Thread, class *Class, code *Code, pc int) int {
 func InstructionNew(thread *Thread, class *Class, code *Code, pc int) int {
 	index := ParseU16(code.Code, pc)
 	className := class.GetString(index)
-	newClass := thread.Loader.LoadClass(className)
+	newClass := ResolveClass(thread, className)
 	if IsInterface(newClass.Access) || IsAbstract(newClass.Access) {
-		panic(fmt.Sprintf("interface or abstract class %s", className))
+		ThrowNew(thread, "java/lang/InstantiationError", toJavaName(className))
 	}
+	InitClass(thread, newClass)
 	frame := thread.Peek()
-	frame.Push(NewObject(&Object{Class: newClass, Fields: make([]*Value, newClass.InstSlotCount)}))
+	frame.Push(NewObject(HeapAlloc(thread, newClass, 0)))
 	return pc + 2
 }
 
-func loadClassAndField(thread *Thread, class *Class, index int) (*Class, *Field) {
+// 解析字段引用，字段可以声明在父类或者父接口中
+func resolveField(thread *Thread, class *Class, index int) *Field {
 	// ConstField
-	fieldIndex := class.Consts[index]
-	// 静态变量的目标 class
-	className := class.GetString(fieldIndex.ClassIndex)
-	resClass := thread.Loader.LoadClass(className)
-	// 静态变量的目标 field
-	nameType := class.Consts[fieldIndex.NameTypeIndex]
+	fieldRef := class.Consts[index]
+	// 变量的目标 class
+	className := class.GetString(fieldRef.ClassIndex)
+	resClass := ResolveClass(thread, className)
+	// 变量的目标 field
+	nameType := class.Consts[fieldRef.NameTypeIndex]
 	name := class.GetString(nameType.NameIndex)
 	desc := class.GetString(nameType.DescIndex)
-	resField := resClass.GetField(name, desc)
-	return resClass, resField
+	resField := resClass.LookupField(name, desc)
+	if resField == nil {
+		ThrowNew(thread, "java/lang/NoSuchFieldError", name)
+	}
+	return resField
+}
+
+// 校验字段是否为静态字段，不匹配时抛出 IncompatibleClassChangeError
+func checkStaticField(thread *Thread, field *Field, isStatic bool) {
+	if IsStatic(field.Access) == isStatic {
+		return
+	}
+	kind := "non-static"
+	if isStatic {
+		kind = "static"
+	}
+	ThrowNew(thread, "java/lang/IncompatibleClassChangeError", fmt.Sprintf("Expected %s field %s.%s",
+		kind, toJavaName(field.Class.GetName()), field.GetName()))
 }
 
 func InstructionPutStatic(thread *Thread, class *Class, code *Code, pc int) int {
 	index := ParseU16(code.Code, pc)
-	targetClass, targetField := loadClassAndField(thread, class, int(index))
-	if !IsStatic(targetField.Access) {
-		panic(fmt.Sprintf("%s is not static", targetClass.GetString(targetField.NameIndex)))
-	}
+	targetField := resolveField(thread, class, int(index))
+	checkStaticField(thread, targetField, true)
+	targetClass := targetField.Class
+	InitClass(thread, targetClass) // 初始化声明该字段的类
 	// 设置值
 	frame := thread.Peek()
 	slotID := targetField.SlotID
@@ -826,10 +1297,10 @@ func InstructionPutStatic(thread *Thread, class *Class, code *Code, pc int) int
 
 func InstructionGetStatic(thread *Thread, class *Class, code *Code, pc int) int {
 	index := ParseU16(code.Code, pc)
-	targetClass, targetField := loadClassAndField(thread, class, int(index))
-	if !IsStatic(targetField.Access) {
-		panic(fmt.Sprintf("%s is not static", targetClass.GetString(targetField.NameIndex)))
-	}
+	targetField := resolveField(thread, class, int(index))
+	checkStaticField(thread, targetField, true)
+	targetClass := targetField.Class
+	InitClass(thread, targetClass) // 初始化声明该字段的类
 	// 获取值
 	frame := thread.Peek()
 	slotID := targetField.SlotID
@@ -843,54 +1314,58 @@ func InstructionGetStatic(thread *Thread, class *Class, code *Code, pc int) int
 
 func InstructionPutField(thread *Thread, class *Class, code *Code, pc int) int {
 	index := ParseU16(code.Code, pc)
-	targetClass, targetField := loadClassAndField(thread, class, int(index))
-	if IsStatic(targetField.Access) {
-		panic(fmt.Sprintf("%s is static", targetClass.GetString(targetField.NameIndex)))
-	}
+	targetField := resolveField(thread, class, int(index))
+	checkStaticField(thread, targetField, false)
 	// 设置值
 	frame := thread.Peek()
 	slotID := targetField.SlotID
 	if targetField.IsTwoSlot() {
 		val := frame.Pop2()
-		inst := frame.Pop()
-		inst.Object.Fields[slotID] = val
+		inst := checkNotNull(thread, frame.Pop())
+		inst.Fields[slotID] = val
 	} else {
 		val := frame.Pop()
-		inst := frame.Pop()
-		inst.Object.Fields[slotID] = val
+		inst := checkNotNull(thread, frame.Pop())
+		inst.Fields[slotID] = val
 	}
 	return pc + 2
 }
 
 func InstructionGetField(thread *Thread, class *Class, code *Code, pc int) int {
 	index := ParseU16(code.Code, pc)
-	targetClass, targetField := loadClassAndField(thread, class, int(index))
-	if IsStatic(targetField.Access) {
-		panic(fmt.Sprintf("%s is static", targetClass.GetString(targetField.NameIndex)))
-	}
+	targetField := resolveField(thread, class, int(index))
+	checkStaticField(thread, targetField, false)
 	// 设置值
 	frame := thread.Peek()
 	slotID := targetField.SlotID
 	if targetField.IsTwoSlot() {
-		inst := frame.Pop()
-		frame.Push2(inst.Object.Fields[slotID])
+		inst := checkNotNull(thread, frame.Pop())
+		frame.Push2(inst.Fields[slotID])
 	} else {
-		inst := frame.Pop()
-		frame.Push(inst.Object.Fields[slotID])
+		inst := checkNotNull(thread, frame.Pop())
+		frame.Push(inst.Fields[slotID])
 	}
 	return pc + 2
 }
 
+// 对 null 进行字段访问或者方法调用时抛出 NullPointerException
+func checkNotNull(thread *Thread, val Value) *Object {
+	if val.Object == nil {
+		ThrowNew(thread, "java/lang/NullPointerException", "")
+	}
+	return val.Object
+}
+
 func InstructionInstanceOf(thread *Thread, class *Class, code *Code, pc int) int {
 	// 获取目标Class
 	index := ParseU16(code.Code, pc)
 	className := class.GetString(index)
-	targetClass := thread.Loader.LoadClass(className)
+	targetClass := ResolveClass(thread, className)
 	// 目标实例
 	frame := thread.Peek()
 	inst := frame.Pop().Object
-	// 判断是否符合
-	if instanceOf(thread, inst.Class, targetClass) {
+	// 判断是否符合 null 不是任何类型的实例
+	if inst != nil && instanceOf(thread, inst.Class, targetClass) {
 		frame.Push(NewInteger(1))
 	} else {
 		frame.Push(NewInteger(0))
@@ -902,46 +1377,118 @@ func InstructionCheckCast(thread *Thread, class *Class, code *Code, pc int) int
 	// 获取目标Class
 	index := ParseU16(code.Code, pc)
 	className := class.GetString(index)
-	targetClass := thread.Loader.LoadClass(className)
+	targetClass := ResolveClass(thread, className)
 	// 目标实例
 	frame := thread.Peek()
 	inst := frame.Peek().Object // 不要弹出对象，仅检查
-	// 判断是否符合
-	if !instanceOf(thread, inst.Class, targetClass) {
-		panic(fmt.Sprintf("inst not a %s", className))
+	// 判断是否符合 null 可以转换为任意类型
+	if inst != nil {
+		checkCast(thread, inst, targetClass)
 	}
 	return pc + 2
 }
 
+func checkCast(thread *Thread, inst *Object, targetClass *Class) {
+	if !instanceOf(thread, inst.Class, targetClass) {
+		instName := toJavaName(inst.Class.GetString(inst.Class.ThisIndex))
+		ThrowNew(thread, "java/lang/ClassCastException", instName+" cannot be cast to "+toJavaName(targetClass.GetName()))
+	}
+}
+
+// https://docs.oracle.com/javase/specs/jvms/se8/html/jvms-6.html#jvms-6.5.checkcast
 func instanceOf(thread *Thread, subClass *Class, class *Class) bool {
-	for subClass != class { // 先只看继承
-		if subClass.SupperIndex == 0 {
-			return false // 到头了
+	if subClass == class {
+		return true
+	}
+	subName, name := subClass.GetName(), class.GetName()
+	if subName[0] == '[' && name[0] == '[' { // 都是数组比较元素类型
+		subName, name = ComponentClassName(subName), ComponentClassName(name)
+		if IsPrimitiveDesc(subName) || IsPrimitiveDesc(name) {
+			return subName == name
 		}
-		supperName := subClass.GetString(subClass.SupperIndex)
-		subClass = thread.Loader.LoadClass(supperName)
+		return instanceOf(thread, thread.Loader.LoadClass(subName), thread.Loader.LoadClass(name))
+	}
+	if IsInterface(class.Access) { // 数组实现了 Cloneable 与 Serializable
+		return subClass.IsImplements(class)
 	}
-	return true
+	return subClass.IsSubClassOf(class)
 }
 
-func loadClassAndMethod(thread *Thread, class *Class, index int) (*Class, *Field) {
-	// ConstMethod
-	methodIndex := class.Consts[index]
-	// 变量的目标 class
-	className := class.GetString(methodIndex.ClassIndex)
-	resClass := thread.Loader.LoadClass(className)
-	// 变量的目标 field
-	nameType := class.Consts[methodIndex.NameTypeIndex]
+// 解析方法引用，ConstInterfaceMethod 按照接口方法解析，其他按照类方法解析
+func resolveMethod(thread *Thread, class *Class, index int) *Field {
+	// ConstMethod ConstInterfaceMethod
+	methodRef := class.Consts[index]
+	if methodRef.Method != nil { // 已经解析过了
+		return methodRef.Method
+	}
+	// 方法的目标 class
+	className := class.GetString(methodRef.ClassIndex)
+	resClass := ResolveClass(thread, className)
+	isInterface := methodRef.Type == ConstInterfaceMethod
+	if isInterface && !IsInterface(resClass.Access) {
+		ThrowNew(thread, "java/lang/IncompatibleClassChangeError", fmt.Sprintf("Found class %s, but interface was expected", toJavaName(className)))
+	}
+	if !isInterface && IsInterface(resClass.Access) {
+		ThrowNew(thread, "java/lang/IncompatibleClassChangeError", fmt.Sprintf("Found interface %s, but class was expected", toJavaName(className)))
+	}
+	// 方法的目标 method
+	nameType := class.Consts[methodRef.NameTypeIndex]
 	name := class.GetString(nameType.NameIndex)
 	desc := class.GetString(nameType.DescIndex)
-	resMethod := resClass.GetMethod(name, desc)
-	return resClass, resMethod
+	var resMethod *Field
+	if isInterface {
+		resMethod = resClass.LookupInterfaceMethod(name, desc)
+	} else {
+		resMethod = resClass.LookupMethod(name, desc)
+	}
+	if resMethod == nil {
+		ThrowNew(thread, "java/lang/NoSuchMethodError", toJavaName(className)+"."+name+desc)
+	}
+	// 缓存解析结果与虚表下标，之后调用直接按下标取
+	methodRef.MethodIndex = -1
+	if !isInterface {
+		methodRef.MethodIndex = resClass.GetVTableIndex(name, desc)
+	} else if IsInterface(resMethod.Class.Access) {
+		methodRef.MethodIndex = resMethod.ITableIndex
+	} else { // 接口引用解析到 Object 的方法时按虚表调用
+		methodRef.MethodIndex = resMethod.Class.GetVTableIndex(name, desc)
+	}
+	methodRef.Method = resMethod
+	return resMethod
+}
+
+// 校验方法是否为静态方法，不匹配时抛出 IncompatibleClassChangeError
+func checkStaticMethod(thread *Thread, method *Field, isStatic bool) {
+	if IsStatic(method.Access) == isStatic {
+		return
+	}
+	kind := "non-static"
+	if isStatic {
+		kind = "static"
+	}
+	ThrowNew(thread, "java/lang/IncompatibleClassChangeError", fmt.Sprintf("Expected %s method %s", kind, methodName(method)))
 }
 
-func parseArgCount(class *Class, method *Field) int {
+// 从接收者的运行时类选择实际调用的方法
+func selectMethod(thread *Thread, receiver *Class, resolved *Field) *Field {
+	method, conflict := receiver.SelectMethod(resolved)
+	if conflict {
+		ThrowNew(thread, "java/lang/IncompatibleClassChangeError", "Conflicting default methods: "+methodName(resolved))
+	}
+	if method == nil || IsAbstract(method.Access) {
+		ThrowNew(thread, "java/lang/AbstractMethodError", fmt.Sprintf("Receiver class %s does not define or inherit an implementation of the resolved method %s",
+			toJavaName(receiver.GetName()), methodName(resolved)))
+	}
+	return method
+}
+
+func methodName(method *Field) string {
+	return toJavaName(method.Class.GetName()) + "." + method.GetName() + method.GetDesc()
+}
+
+func parseArgCount(method *Field) int {
 	count := 0
-	desc := class.GetString(method.DescIndex)
-	methodDesc := NewMethodDescParser(desc).Parse()
+	methodDesc := NewMethodDescParser(method.GetDesc()).Parse()
 	for _, argType := range methodDesc.ArgTypes {
 		count++
 		if argType == "D" || argType == "J" {
@@ -954,140 +1501,186 @@ func parseArgCount(class *Class, method *Field) int {
 	return count
 }
 
-func invokeMethod(thread *Thread, targetClass *Class, targetMethod *Field) {
-	if IsNative(targetMethod.Access) { // 本地方法调用
-		class := targetClass.GetString(targetClass.ThisIndex)
-		name := targetClass.GetString(targetMethod.NameIndex)
-		desc := targetClass.GetString(targetMethod.DescIndex)
-		nativeFunc := GetNativeFunc(class, name, desc)
+// 获取实例方法的接收者，为 null 时抛出 NullPointerException
+func peekReceiver(thread *Thread, method *Field) *Object {
+	frame := thread.Peek()
+	return checkNotNull(thread, frame.PeekAt(method.ArgSlotCount-1))
+}
+
+func invokeMethod(thread *Thread, method *Field) {
+	if IsNative(method.Access) { // 本地方法调用
+		nativeFunc := thread.VM.GetNativeFunc(method.Class.GetName(), method.GetName(), method.GetDesc())
+		if nativeFunc == nil {
+			ThrowNew(thread, "java/lang/UnsatisfiedLinkError", methodName(method))
+		}
 		nativeFunc(thread)
-	} else { // 正常方法调用
+	} else { // 正常方法调用，只压入栈帧由解释器执行，go 代码中需要等待执行完成使用 CallMethod
 		frame := thread.Peek()
-		argCount := parseArgCount(targetClass, targetMethod)
-		args := make([]*Value, argCount)
-		for i := argCount - 1; i >= 0; i-- {
+		args := make([]Value, method.ArgSlotCount)
+		for i := method.ArgSlotCount - 1; i >= 0; i-- {
 			args[i] = frame.Pop()
 		}
-		RunMethod(thread, targetMethod, args)
+		thread.Push(NewFrame(method, args))
 	}
 }
 
 // 静态方法
 func InstructionInvokeStatic(thread *Thread, class *Class, code *Code, pc int) int {
 	index := ParseU16(code.Code, pc)
-	targetClass, targetMethod := loadClassAndMethod(thread, class, int(index))
+	targetMethod := resolveMethod(thread, class, int(index))
 	// 校验方法
-	if !IsStatic(targetMethod.Access) {
-		panic(fmt.Sprintf("%s not is static", targetClass.GetString(targetMethod.NameIndex)))
-	}
-	invokeMethod(thread, targetClass, targetMethod)
+	checkStaticMethod(thread, targetMethod, true)
+	InitClass(thread, targetMethod.Class)
+	invokeMethod(thread, targetMethod)
 	return pc + 2
 }
 
-// 调用私有方法，静态方法等不需要动态绑定的方法没有搜索过程加快速度
+// 调用构造方法，私有方法，父类方法等不需要动态绑定的方法
 func InstructionInvokeSpecial(thread *Thread, class *Class, code *Code, pc int) int {
 	index := ParseU16(code.Code, pc)
-	targetClass, targetMethod := loadClassAndMethod(thread, class, int(index))
-	if IsStatic(targetMethod.Access) {
-		panic(fmt.Sprintf("%s is static", targetClass.GetString(targetMethod.NameIndex)))
+	targetMethod := resolveMethod(thread, class, int(index))
+	checkStaticMethod(thread, targetMethod, false)
+	peekReceiver(thread, targetMethod)
+	// super.xxx() 调用需要从当前类的父类重新选择方法
+	targetClass := targetMethod.Class
+	if targetMethod.GetName() != "<init>" && !IsInterface(targetClass.Access) &&
+		class.IsSubClassOf(targetClass) && class.Access&AccessSuper > 0 {
+		targetMethod = selectMethod(thread, class.SupperClass, targetMethod)
+	} else if IsAbstract(targetMethod.Access) {
+		ThrowNew(thread, "java/lang/AbstractMethodError", methodName(targetMethod))
 	}
-	// TODO 捞取 inst 校验方法调用的合法性
-	invokeMethod(thread, targetClass, targetMethod)
+	invokeMethod(thread, targetMethod)
 	return pc + 2
 }
 
 // 需要动态绑定的方法
 func InstructionInvokeVirtual(thread *Thread, class *Class, code *Code, pc int) int {
 	index := ParseU16(code.Code, pc)
-	targetClass, targetMethod := loadClassAndMethod(thread, class, int(index))
-	if IsStatic(targetMethod.Access) {
-		panic(fmt.Sprintf("%s is static", targetClass.GetString(targetMethod.NameIndex)))
+	targetMethod := resolveMethod(thread, class, int(index))
+	checkStaticMethod(thread, targetMethod, false)
+	inst := peekReceiver(thread, targetMethod)
+	checkProtectedAccess(thread, class, targetMethod, inst)
+	if !IsPrivate(targetMethod.Access) { // 私有方法不参与动态绑定
+		targetMethod = virtualMethod(thread, inst.Class, targetMethod, class.Consts[index].MethodIndex)
 	}
+	invokeMethod(thread, targetMethod)
+	return pc + 2
+}
 
-	// MOCK
-	name := targetClass.GetString(targetMethod.NameIndex)
-	if name == "println" {
-		frame := thread.Peek()
-		desc := targetClass.GetString(targetMethod.DescIndex)
-		switch desc {
-		case "(Z)V", "(C)V", "(I)V", "(B)V", "(S)V", "(F)V":
-			fmt.Println(frame.Pop())
-		case "(J)V", "(D)V":
-			fmt.Println(frame.Pop2())
-		case "(Ljava/lang/String;)V":
-			obj := frame.Pop().Object
-			field := obj.Class.GetField("value", "[C")
-			values := obj.Fields[field.SlotID]
-			bs := make([]byte, 0)
-			for _, item := range values.Object.ArrayData {
-				bs = append(bs, byte(item.Integer))
-			}
-			fmt.Println(string(bs))
-		default:
-			panic(fmt.Sprintf("unknown %s", desc))
+// 通过其他包中父类的受保护方法访问时，接收者必须是当前类或者子类 https://docs.oracle.com/javase/specs/jvms/se8/html/jvms-4.html#jvms-4.10.1.8
+// 数组的 clone 视为 public 方法，javac 对 array.clone() 生成的就是 invokevirtual [I.clone
+func checkProtectedAccess(thread *Thread, class *Class, method *Field, receiver *Object) {
+	if !IsProtected(method.Access) || samePackage(class, method.Class) || !class.IsSubClassOf(method.Class) {
+		return
+	}
+	if receiver.Class.GetName()[0] == '[' && method.GetName() == "clone" {
+		return
+	}
+	if !instanceOf(thread, receiver.Class, class) {
+		ThrowNew(thread, "java/lang/IllegalAccessError", fmt.Sprintf("tried to access method %s from class %s",
+			methodName(method), toJavaName(class.GetName())))
+	}
+}
+
+// 同一个类加载器加载的同名包
+func samePackage(class1 *Class, class2 *Class) bool {
+	name1, name2 := class1.GetName(), class2.GetName()
+	return name1[:strings.LastIndexByte(name1, '/')+1] == name2[:strings.LastIndexByte(name2, '/')+1]
+}
+
+// 按虚表下标取实现，取不到或者是抽象方法时走完整的选择流程以抛出对应异常
+func virtualMethod(thread *Thread, receiver *Class, resolved *Field, index int) *Field {
+	if index >= 0 && index < len(receiver.VTable) {
+		method := receiver.VTable[index]
+		if method != nil && !IsAbstract(method.Access) {
+			return method
 		}
-		frame.Pop()
-		return pc + 2
 	}
-	invokeMethod(thread, targetClass, targetMethod)
-	return pc + 2
+	return selectMethod(thread, receiver, resolved)
 }
 
-// 接口方法 使用 InvokeVirtual 也行，不过为了减小搜索范围
+// 按接口表下标取实现，规则同 virtualMethod
+func interfaceMethod(thread *Thread, receiver *Class, resolved *Field, index int) *Field {
+	itable := receiver.ITables[resolved.Class]
+	if index >= 0 && index < len(itable) {
+		method := itable[index]
+		if method != nil && !IsAbstract(method.Access) {
+			return method
+		}
+	}
+	return selectMethod(thread, receiver, resolved)
+}
+
+// 接口方法 需要校验接收者实现了接口，再按照运行时类选择实现
 func InstructionInvokeInterface(thread *Thread, class *Class, code *Code, pc int) int {
 	index := ParseU16(code.Code, pc)
-	targetClass, targetMethod := loadClassAndMethod(thread, class, int(index)) // 接口中的定义
-	if !IsInterface(targetClass.Access) {
-		panic(fmt.Sprintf("%s not is interface", targetClass.GetString(targetClass.ThisIndex)))
+	targetMethod := resolveMethod(thread, class, int(index)) // 接口中的定义
+	checkStaticMethod(thread, targetMethod, false)
+
+	inst := peekReceiver(thread, targetMethod)
+	iface := targetMethod.Class
+	if IsInterface(iface.Access) && !inst.Class.IsImplements(iface) {
+		ThrowNew(thread, "java/lang/IncompatibleClassChangeError", fmt.Sprintf("Class %s does not implement the requested interface %s",
+			toJavaName(inst.Class.GetName()), toJavaName(iface.GetName())))
 	}
-	if IsStatic(targetMethod.Access) {
-		panic(fmt.Sprintf("%s is static", targetClass.GetString(targetMethod.NameIndex)))
+	// 转换为具体实现，实现方法必须是 public 的
+	if !IsPrivate(targetMethod.Access) {
+		methodIndex := class.Consts[index].MethodIndex
+		if IsInterface(iface.Access) {
+			targetMethod = interfaceMethod(thread, inst.Class, targetMethod, methodIndex)
+		} else { // 解析到 Object 中的方法
+			targetMethod = virtualMethod(thread, inst.Class, targetMethod, methodIndex)
+		}
+		if !IsPublic(targetMethod.Access) {
+			ThrowNew(thread, "java/lang/IllegalAccessError", methodName(targetMethod))
+		}
 	}
-
-	frame := thread.Peek()
-	argCount := parseArgCount(targetClass, targetMethod)
-	inst := frame.PeekAt(argCount - 1)
-	// TODO 捞取 inst 校验是否实现了对应接口
-	// 转换为具体实现
-	name := targetClass.GetString(targetMethod.NameIndex)
-	desc := targetClass.GetString(targetMethod.DescIndex)
-	targetClass = inst.Object.Class
-	targetMethod = targetClass.GetMethod(name, desc)
-	invokeMethod(thread, targetClass, targetMethod)
+	invokeMethod(thread, targetMethod)
 	return pc + 4 // 还有 2 byte 历史遗留不用管
 }
 
 var (
 	arrayTypes = map[uint8]string{
-		ArrayInt:  "[I",
-		ArrayChar: "[C",
+		ArrayBoolean: "[Z",
+		ArrayChar:    "[C",
+		ArrayFloat:   "[F",
+		ArrayDouble:  "[D",
+		ArrayByte:    "[B",
+		ArrayShort:   "[S",
+		ArrayInt:     "[I",
+		ArrayLong:    "[J",
 	}
 )
 
 func InstructionNewArray(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
-	count := frame.Pop().Integer
+	count := frame.Pop().Integer()
 	if count < 0 {
-		panic(fmt.Sprintf("invalid array size: %d", count))
+		ThrowNew(thread, "java/lang/NegativeArraySizeException", strconv.Itoa(int(count)))
 	}
 
 	arrayType := ParseU8(code.Code, pc)
-	newClass := thread.Loader.LoadClass(arrayTypes[arrayType])
-	frame.Push(NewObject(&Object{Class: newClass, ArrayType: arrayType, ArrayData: make([]*Value, count)}))
+	className, ok := arrayTypes[arrayType]
+	if !ok {
+		panic(fmt.Sprintf("unknown array type %d", arrayType))
+	}
+	newClass := thread.Loader.LoadClass(className)
+	frame.Push(NewObject(HeapAlloc(thread, newClass, int(count))))
 	return pc + 1
 }
 
 func InstructionObjArray(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
-	count := frame.Pop().Integer
+	count := frame.Pop().Integer()
 	if count < 0 {
-		panic(fmt.Sprintf("invalid array size: %d", count))
+		ThrowNew(thread, "java/lang/NegativeArraySizeException", strconv.Itoa(int(count)))
 	}
 
 	index := ParseU16(code.Code, pc)
 	className := class.GetString(index) // 是基本元素的类型
-	newClass := thread.Loader.LoadClass("[" + className)
-	frame.Push(NewObject(&Object{Class: newClass, ArrayData: make([]*Value, count)}))
+	ResolveClass(thread, className)     // 元素类型有格式错误时不能创建数组
+	newClass := thread.Loader.LoadClass(ArrayClassName(className))
+	frame.Push(NewObject(HeapAlloc(thread, newClass, int(count))))
 	return pc + 2
 }
 
@@ -1095,9 +1688,9 @@ func InstructionArrayLen(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	obj := frame.Pop().Object
 	if obj == nil {
-		panic(fmt.Sprintf("obj is null"))
+		ThrowNew(thread, "java/lang/NullPointerException", "")
 	}
-	frame.Push(NewInteger(int32(len(obj.ArrayData))))
+	frame.Push(NewInteger(int32(obj.ArrayLength())))
 	return pc
 }
 
@@ -1105,54 +1698,84 @@ func InstructionMultiArray(thread *Thread, class *Class, code *Code, pc int) int
 	index := ParseU16(code.Code, pc)
 	className := class.GetString(index)
 	dimension := ParseU8(code.Code, pc+2)
+	ResolveClass(thread, className)
 	counts := make([]int32, dimension)
 	frame := thread.Peek()
 	for i := len(counts) - 1; i >= 0; i-- {
-		counts[i] = frame.Pop().Integer
+		counts[i] = frame.Pop().Integer()
+		if counts[i] < 0 {
+			ThrowNew(thread, "java/lang/NegativeArraySizeException", strconv.Itoa(int(counts[i])))
+		}
 	}
 
-	frame.Push(makeMultiArray(thread, className, counts))
+	frame.Push(NewObject(makeMultiArray(thread, className, counts)))
 	return pc + 3
 }
 
-func makeMultiArray(thread *Thread, className string, counts []int32) *Value {
-	data := make([]*Value, counts[0])
+func makeMultiArray(thread *Thread, className string, counts []int32) *Object {
+	arr := HeapAlloc(thread, thread.Loader.LoadClass(className), int(counts[0]))
 	if len(counts) > 1 {
+		data := arr.ArrayData.([]*Object)
 		for j := 0; j < len(data); j++ { // 逐渐加载
 			data[j] = makeMultiArray(thread, className[1:], counts[1:])
 		}
 	}
-	class := thread.Loader.LoadClass(className)
-	return NewObject(&Object{Class: class, ArrayData: data})
+	return arr
 }
 
 func InstructionAThrow(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	obj := frame.Pop().Object // 获取异常对象
 	if obj == nil {
-		panic(fmt.Sprintf("obj is null"))
-	}
-	for !thread.IsEmpty() { // 寻找异常处理函数
-		frame = thread.Peek()
-		code = frame.Method.GetCodeAttribute()
-		exception := code.FindException(class, uint16(pc-1), obj)
-		if exception != nil {
-			frame.Clear() // 压入异常对象，跳转到异常处理函数
-			frame.Push(NewObject(obj))
-			return int(exception.Handler)
-		} // 当前实际不支持上层捕获下层的异常 因为外层 for 循环没有弹出方法栈 暂时只支持同层捕获异常
-		thread.Pop() // 没有找到，继续查找调用栈
+		ThrowNew(thread, "java/lang/NullPointerException", "")
+	} // 由 runFrame 逐层寻找异常处理代码
+	panic(NewJavaException(thread, obj))
+}
+
+// 只有一个线程不会有竞争，只记录持有者与重入次数
+func InstructionMonitorEnter(thread *Thread, class *Class, code *Code, pc int) int {
+	checkNotNull(thread, thread.Peek().Pop()).Enter(thread)
+	return pc
+}
+
+func InstructionMonitorExit(thread *Thread, class *Class, code *Code, pc int) int {
+	if !checkNotNull(thread, thread.Peek().Pop()).Exit(thread) {
+		ThrowNew(thread, "java/lang/IllegalMonitorStateException", "")
 	}
-	panic(fmt.Sprintf("un handle exception %s", obj.String()))
+	return pc
 }
 
 //===================extended===================
 
+// wide 扩展下一条指令的局部变量下标为 2 字节，iinc 的增量也扩展为 2 字节
+func InstructionWide(thread *Thread, class *Class, code *Code, pc int) int {
+	opCode := code.Code[pc]
+	index := int(ParseU16(code.Code, pc+1))
+	switch opCode {
+	case 0x15, 0x17, 0x19: // iload fload aload
+		instructionLoad(thread, index)
+	case 0x16, 0x18: // lload dload
+		instruction2Load(thread, index)
+	case 0x36, 0x38, 0x3A: // istore fstore astore
+		instructionStore(thread, index)
+	case 0x37, 0x39: // lstore dstore
+		instruction2Store(thread, index)
+	case 0xA9: // ret
+		return int(thread.Peek().Get(index).Integer())
+	case 0x84: // iinc
+		instructionIInc(thread, index, int32(ParseI16(code.Code, pc+3)))
+		return pc + 5
+	default:
+		panic(fmt.Sprintf("wide opcode %x not support", opCode))
+	}
+	return pc + 3
+}
+
 func InstructionIfNonNull(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val := frame.Pop()
 	offset := ParseI16(code.Code, pc)
-	if val != nil && val.Object != nil {
+	if val.Object != nil {
 		return pc + int(offset) - 1
 	}
 	return pc + 2
@@ -1162,7 +1785,7 @@ func InstructionIfNull(thread *Thread, class *Class, code *Code, pc int) int {
 	frame := thread.Peek()
 	val := frame.Pop()
 	offset := ParseI16(code.Code, pc)
-	if val == nil || val.Object == nil {
+	if val.Object == nil {
 		return pc + int(offset) - 1
 	}
 	return pc + 2
@@ -0,0 +1,633 @@
+/*
+@author: sk
+@date: 2026/10/18
+*/
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+// int fib(int n) { return n < 2 ? n : fib(n - 1) + fib(n - 2); } 每次递归都是 invokevirtual
+// static int run(int n) { return new Fib().fib(n); }
+func fibClass() *testClass {
+	class := newTestClass("Fib", "java/lang/Object")
+	defaultInit(class)
+	fib := class.ref(ConstMethod, "Fib", "fib", "(I)I")
+	class.method(AccessPublic, "fib", "(I)I", newTestCode(4, 2).
+		op(0x1B, 0x05).jump(0xA2, "recurse"). // iload_1 iconst_2 if_icmpge
+		op(0x1B, 0xAC).                       // iload_1 ireturn
+		label("recurse").
+		op(0x2A, 0x1B, 0x04, 0x64).op16(0xB6, fib). // aload_0 iload_1 iconst_1 isub invokevirtual
+		op(0x2A, 0x1B, 0x05, 0x64).op16(0xB6, fib). // aload_0 iload_1 iconst_2 isub invokevirtual
+		op(0x60, 0xAC))                             // iadd ireturn
+	// new dup invokespecial iload_0 invokevirtual ireturn
+	class.method(AccessPublic|AccessStatic, "run", "(I)I", newTestCode(2, 1).
+		op16(0xBB, class.class("Fib")).op(0x59).op16(0xB7, class.ref(ConstMethod, "Fib", "<init>", "()V")).
+		op(0x1A).op16(0xB6, fib).op(0xAC))
+	return class
+}
+
+// vtable 为正常的虚方法表分派，selectMethod 清空 Fib 的虚方法表，每次调用都按照继承关系查找方法，用于对比虚方法表的收益
+func BenchmarkFib(b *testing.B) {
+	for _, name := range []string{"vtable", "selectMethod"} {
+		b.Run(name, func(b *testing.B) {
+			vm := newTestVM(b, fibClass())
+			if name == "selectMethod" {
+				vm.Loader.LoadClass("Fib").VTable = nil
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				res, exception := runStatic(vm, "Fib", "run", "(I)I", NewInteger(20))
+				if exception != nil || res.Integer() != 6765 {
+					b.Fatalf("fib(20) = %d, exception %v", res.Integer(), exception)
+				}
+			}
+		})
+	}
+}
+
+// 一条指令的用例，build 生成被测试的静态方法，需要常量池时通过 class 添加
+type opcodeCase struct {
+	op        byte   // 被测试的指令，同一条指令可以有多个用例
+	also      []byte // 同时被测试的其他指令，例如数组的读写成对测试
+	name      string
+	desc      string
+	build     func(class *testClass, code *testCode)
+	args      []Value // 按照槽位传递，long double 的第二个槽位为零值
+	want      Value
+	exception string // 期望抛出的异常
+}
+
+func ops(bytes ...byte) func(class *testClass, code *testCode) {
+	return func(class *testClass, code *testCode) {
+		code.op(bytes...)
+	}
+}
+
+func long2(val int64) []Value {
+	return []Value{NewLong(val), {}}
+}
+
+func double2(val float64) []Value {
+	return []Value{NewDouble(val), {}}
+}
+
+// 栈上的 n 个 0-9 的 int 按照从栈底到栈顶的顺序组合为十进制数，用于检查栈操作后的顺序
+func foldInts(code *testCode, n int) {
+	for i := 0; i < n-1; i++ {
+		code.op(0x36, byte(i)) // istore
+	}
+	for i := n - 2; i >= 0; i-- {
+		code.op(0x10, 10, 0x68, 0x15, byte(i), 0x60) // bipush 10 imul iload iadd
+	}
+	code.op(0xAC)
+}
+
+// 调用方与被调用方，Opcodes 实现 OpIface，OpAbstract 用于测试 new 抽象类
+func opcodeIface() *testClass {
+	return interfaceClass("OpIface", "value", "()I")
+}
+
+func opcodeAbstract() *testClass {
+	class := newTestClass("OpAbstract", "java/lang/Object")
+	class.access |= AccessAbstract
+	defaultInit(class)
+	return class
+}
+
+func opcodeCases() []opcodeCase {
+	nan32, nan64 := float32(math.NaN()), math.NaN()
+	cases := []opcodeCase{
+		{op: 0x00, also: []byte{0xAC}, name: "nop", desc: "()I", build: ops(0x00, 0x04, 0xAC), want: NewInteger(1)},
+		{op: 0x01, also: []byte{0xB0}, name: "aconst_null", desc: "()Ljava/lang/Object;", build: ops(0x01, 0xB0), want: NewNull()},
+		{op: 0x10, name: "bipush", desc: "()I", build: ops(0x10, 0x80, 0xAC), want: NewInteger(-128)},
+		{op: 0x11, name: "sipush", desc: "()I", build: ops(0x11, 0x80, 0x00, 0xAC), want: NewInteger(-32768)},
+		{op: 0x12, name: "ldc int", desc: "()I", build: func(class *testClass, code *testCode) {
+			code.op(0x12, byte(class.integer(123456)), 0xAC)
+		}, want: NewInteger(123456)},
+		{op: 0x12, name: "ldc float", desc: "()F", build: func(class *testClass, code *testCode) {
+			code.op(0x12, byte(class.float(1.5)), 0xAE)
+		}, want: NewFloat(1.5)},
+		{op: 0x12, name: "ldc string", desc: "()I", build: func(class *testClass, code *testCode) {
+			code.op(0x12, byte(class.str("abc"))).op16(0xC1, class.class("java/lang/String")).op(0xAC)
+		}, want: NewInteger(1)},
+		{op: 0x12, name: "ldc class", desc: "()I", build: func(class *testClass, code *testCode) {
+			code.op(0x12, byte(class.class("java/lang/Object"))).op16(0xC1, class.class("java/lang/Class")).op(0xAC)
+		}, want: NewInteger(1)},
+		{op: 0x13, name: "ldc_w", desc: "()I", build: func(class *testClass, code *testCode) {
+			code.op16(0x13, class.integer(-7)).op(0xAC)
+		}, want: NewInteger(-7)},
+		{op: 0x14, name: "ldc2_w long", desc: "()J", build: func(class *testClass, code *testCode) {
+			code.op16(0x14, class.long(1<<40)).op(0xAD)
+		}, want: NewLong(1 << 40)},
+		{op: 0x14, name: "ldc2_w double", desc: "()D", build: func(class *testClass, code *testCode) {
+			code.op16(0x14, class.double(2.5)).op(0xAF)
+		}, want: NewDouble(2.5)},
+		// 局部变量，引用类型与数组一起测试
+		{op: 0x15, name: "iload", desc: "(I)I", build: ops(0x15, 0, 0xAC), args: []Value{NewInteger(7)}, want: NewInteger(7)},
+		{op: 0x16, also: []byte{0xAD}, name: "lload", desc: "(J)J", build: ops(0x16, 0, 0xAD), args: long2(1 << 40), want: NewLong(1 << 40)},
+		{op: 0x17, also: []byte{0xAE}, name: "fload", desc: "(F)F", build: ops(0x17, 0, 0xAE), args: []Value{NewFloat(1.5)}, want: NewFloat(1.5)},
+		{op: 0x18, also: []byte{0xAF}, name: "dload", desc: "(D)D", build: ops(0x18, 0, 0xAF), args: double2(2.5), want: NewDouble(2.5)},
+		{op: 0x19, also: []byte{0x3A}, name: "aload astore", desc: "()I", build: ops(0x10, 7, 0xBC, ArrayInt, 0x3A, 5, 0x19, 5, 0xBE, 0xAC), want: NewInteger(7)},
+		{op: 0x36, name: "istore", desc: "()I", build: ops(0x10, 9, 0x36, 5, 0x15, 5, 0xAC), want: NewInteger(9)},
+		{op: 0x37, name: "lstore", desc: "()J", build: ops(0x0A, 0x37, 4, 0x16, 4, 0xAD), want: NewLong(1)},
+		{op: 0x38, name: "fstore", desc: "()F", build: ops(0x0D, 0x38, 5, 0x17, 5, 0xAE), want: NewFloat(2)},
+		{op: 0x39, name: "dstore", desc: "()D", build: ops(0x0F, 0x39, 4, 0x18, 4, 0xAF), want: NewDouble(1)},
+		// 数组
+		{op: 0x2E, also: []byte{0x4F}, name: "iaload iastore", desc: "()I", build: arrayOps(ArrayInt, 0x4F, 0x2E, 0xAC, 0x02), want: NewInteger(-1)},
+		{op: 0x2F, also: []byte{0x50}, name: "laload lastore", desc: "()J", build: arrayOps(ArrayLong, 0x50, 0x2F, 0xAD, 0x0A), want: NewLong(1)},
+		{op: 0x30, also: []byte{0x51}, name: "faload fastore", desc: "()F", build: arrayOps(ArrayFloat, 0x51, 0x30, 0xAE, 0x0D), want: NewFloat(2)},
+		{op: 0x31, also: []byte{0x52}, name: "daload dastore", desc: "()D", build: arrayOps(ArrayDouble, 0x52, 0x31, 0xAF, 0x0F), want: NewDouble(1)},
+		{op: 0x33, also: []byte{0x54}, name: "baload bastore byte", desc: "()I", build: arrayOps(ArrayByte, 0x54, 0x33, 0xAC, 0x11, 0x01, 0xFF), want: NewInteger(-1)},
+		{op: 0x33, name: "baload bastore boolean", desc: "()I", build: arrayOps(ArrayBoolean, 0x54, 0x33, 0xAC, 0x04), want: NewInteger(1)},
+		{op: 0x34, also: []byte{0x55}, name: "caload castore", desc: "()I", build: arrayOps(ArrayChar, 0x55, 0x34, 0xAC, 0x02), want: NewInteger(0xFFFF)},
+		{op: 0x35, also: []byte{0x56}, name: "saload sastore", desc: "()I", build: arrayOps(ArrayShort, 0x56, 0x35, 0xAC, 0x11, 0x80, 0x00), want: NewInteger(-32768)},
+		{op: 0x32, also: []byte{0x53}, name: "aaload aastore", desc: "()I", build: func(class *testClass, code *testCode) {
+			// new Object[2][1] = new int[1]; instanceof int[]
+			code.op(0x05).op16(0xBD, class.class("java/lang/Object")).op(0x59, 0x04, 0x04, 0xBC, ArrayInt, 0x53, 0x04, 0x32)
+			code.op16(0xC1, class.class("[I")).op(0xAC)
+		}, want: NewInteger(1)},
+		{op: 0x53, name: "aastore wrong type", desc: "()V", build: func(class *testClass, code *testCode) {
+			code.op(0x04).op16(0xBD, class.class("java/lang/String")).op(0x03, 0x04, 0xBC, ArrayInt, 0x53, 0xB1)
+		}, exception: "java/lang/ArrayStoreException"},
+		{op: 0x2E, name: "iaload out of bounds", desc: "()I", build: ops(0x05, 0xBC, ArrayInt, 0x05, 0x2E, 0xAC), exception: "java/lang/ArrayIndexOutOfBoundsException"},
+		{op: 0x4F, name: "iastore negative index", desc: "()V", build: ops(0x05, 0xBC, ArrayInt, 0x02, 0x03, 0x4F, 0xB1), exception: "java/lang/ArrayIndexOutOfBoundsException"},
+		{op: 0x2E, name: "iaload null", desc: "()I", build: ops(0x01, 0x03, 0x2E, 0xAC), exception: "java/lang/NullPointerException"},
+		// 操作数栈
+		{op: 0x57, name: "pop", desc: "()I", build: func(class *testClass, code *testCode) { code.op(0x04, 0x05, 0x57); foldInts(code, 1) }, want: NewInteger(1)},
+		{op: 0x58, name: "pop2", desc: "()I", build: func(class *testClass, code *testCode) { code.op(0x04, 0x05, 0x06, 0x58); foldInts(code, 1) }, want: NewInteger(1)},
+		{op: 0x58, name: "pop2 long", desc: "()I", build: ops(0x04, 0x0A, 0x58, 0xAC), want: NewInteger(1)},
+		{op: 0x59, name: "dup", desc: "()I", build: func(class *testClass, code *testCode) { code.op(0x04, 0x59); foldInts(code, 2) }, want: NewInteger(11)},
+		{op: 0x5A, name: "dup_x1", desc: "()I", build: func(class *testClass, code *testCode) { code.op(0x04, 0x05, 0x5A); foldInts(code, 3) }, want: NewInteger(212)},
+		{op: 0x5B, name: "dup_x2", desc: "()I", build: func(class *testClass, code *testCode) { code.op(0x04, 0x05, 0x06, 0x5B); foldInts(code, 4) }, want: NewInteger(3123)},
+		{op: 0x5C, name: "dup2", desc: "()I", build: func(class *testClass, code *testCode) { code.op(0x04, 0x05, 0x5C); foldInts(code, 4) }, want: NewInteger(1212)},
+		{op: 0x5C, name: "dup2 long", desc: "()J", build: ops(0x0A, 0x5C, 0x61, 0xAD), want: NewLong(2)},
+		{op: 0x5D, name: "dup2_x1", desc: "()I", build: func(class *testClass, code *testCode) { code.op(0x04, 0x05, 0x06, 0x5D); foldInts(code, 5) }, want: NewInteger(23123)},
+		{op: 0x5E, name: "dup2_x2", desc: "()I", build: func(class *testClass, code *testCode) { code.op(0x04, 0x05, 0x06, 0x07, 0x5E); foldInts(code, 6) }, want: NewInteger(341234)},
+		{op: 0x5F, name: "swap", desc: "()I", build: func(class *testClass, code *testCode) { code.op(0x04, 0x05, 0x5F); foldInts(code, 2) }, want: NewInteger(21)},
+		// 算术，溢出按照补码回绕
+		intCase(0x60, "iadd overflow", math.MaxInt32, 1, math.MinInt32),
+		longCase(0x61, "ladd overflow", math.MaxInt64, 1, math.MinInt64),
+		{op: 0x62, name: "fadd", desc: "(FF)F", build: ops(0x22, 0x23, 0x62, 0xAE), args: []Value{NewFloat(1.5), NewFloat(0.25)}, want: NewFloat(1.75)},
+		doubleCase(0x63, "dadd", 0.1, 0.25, 0.35),
+		intCase(0x64, "isub overflow", math.MinInt32, 1, math.MaxInt32),
+		longCase(0x65, "lsub", 5, 7, -2),
+		{op: 0x66, name: "fsub", desc: "(FF)F", build: ops(0x22, 0x23, 0x66, 0xAE), args: []Value{NewFloat(1), NewFloat(0.25)}, want: NewFloat(0.75)},
+		doubleCase(0x67, "dsub", 1, 0.25, 0.75),
+		intCase(0x68, "imul overflow", 0x10000, 0x10000, 0),
+		longCase(0x69, "lmul overflow", math.MaxInt64, 2, -2),
+		{op: 0x6A, name: "fmul", desc: "(FF)F", build: ops(0x22, 0x23, 0x6A, 0xAE), args: []Value{NewFloat(1.5), NewFloat(-2)}, want: NewFloat(-3)},
+		doubleCase(0x6B, "dmul", 1.5, -2, -3),
+		intCase(0x6C, "idiv truncate", -7, 2, -3),
+		intCase(0x6C, "idiv overflow", math.MinInt32, -1, math.MinInt32),
+		{op: 0x6C, name: "idiv by zero", desc: "(II)I", build: ops(0x1A, 0x1B, 0x6C, 0xAC), args: []Value{NewInteger(1), NewInteger(0)}, exception: "java/lang/ArithmeticException"},
+		longCase(0x6D, "ldiv overflow", math.MinInt64, -1, math.MinInt64),
+		{op: 0x6D, name: "ldiv by zero", desc: "(JJ)J", build: ops(0x1E, 0x20, 0x6D, 0xAD), args: append(long2(1), long2(0)...), exception: "java/lang/ArithmeticException"},
+		{op: 0x6E, name: "fdiv by zero", desc: "(FF)F", build: ops(0x22, 0x23, 0x6E, 0xAE), args: []Value{NewFloat(1), NewFloat(0)}, want: NewFloat(float32(math.Inf(1)))},
+		doubleCase(0x6F, "ddiv by zero", -1, 0, math.Inf(-1)),
+		intCase(0x70, "irem negative dividend", -7, 2, -1),
+		intCase(0x70, "irem negative divisor", 7, -2, 1),
+		{op: 0x70, name: "irem by zero", desc: "(II)I", build: ops(0x1A, 0x1B, 0x70, 0xAC), args: []Value{NewInteger(1), NewInteger(0)}, exception: "java/lang/ArithmeticException"},
+		longCase(0x71, "lrem", -7, 3, -1),
+		{op: 0x71, name: "lrem by zero", desc: "(JJ)J", build: ops(0x1E, 0x20, 0x71, 0xAD), args: append(long2(1), long2(0)...), exception: "java/lang/ArithmeticException"},
+		{op: 0x72, name: "frem", desc: "(FF)F", build: ops(0x22, 0x23, 0x72, 0xAE), args: []Value{NewFloat(5.5), NewFloat(2)}, want: NewFloat(1.5)},
+		doubleCase(0x73, "drem", -5.5, 2, -1.5),
+		{op: 0x74, name: "ineg overflow", desc: "(I)I", build: ops(0x1A, 0x74, 0xAC), args: []Value{NewInteger(math.MinInt32)}, want: NewInteger(math.MinInt32)},
+		{op: 0x75, name: "lneg", desc: "(J)J", build: ops(0x1E, 0x75, 0xAD), args: long2(5), want: NewLong(-5)},
+		{op: 0x76, name: "fneg zero", desc: "(F)F", build: ops(0x22, 0x76, 0xAE), args: []Value{NewFloat(0)}, want: NewFloat(float32(math.Copysign(0, -1)))},
+		{op: 0x77, name: "dneg", desc: "(D)D", build: ops(0x26, 0x77, 0xAF), args: double2(1.5), want: NewDouble(-1.5)},
+		// 移位只使用低 5 位(int)或低 6 位(long)
+		intCase(0x78, "ishl", 1, 33, 2),
+		shiftCase(0x79, "lshl", 1, 65, 2),
+		intCase(0x7A, "ishr", -16, 34, -4),
+		shiftCase(0x7B, "lshr", -16, 66, -4),
+		intCase(0x7C, "iushr", -1, 28, 15),
+		shiftCase(0x7D, "lushr", -1, 60, 15),
+		intCase(0x7E, "iand", 0b1100, 0b1010, 0b1000),
+		longCase(0x7F, "land", 0b1100, 0b1010, 0b1000),
+		intCase(0x80, "ior", 0b1100, 0b1010, 0b1110),
+		longCase(0x81, "lor", 0b1100, 0b1010, 0b1110),
+		intCase(0x82, "ixor", 0b1100, 0b1010, 0b0110),
+		longCase(0x83, "lxor", 0b1100, 0b1010, 0b0110),
+		{op: 0x84, name: "iinc", desc: "(I)I", build: ops(0x84, 0, 0xFF, 0x1A, 0xAC), args: []Value{NewInteger(5)}, want: NewInteger(4)},
+		// 类型转换，浮点数转整数时 NaN 为 0，超出范围取最大最小值
+		{op: 0x85, name: "i2l", desc: "(I)J", build: ops(0x1A, 0x85, 0xAD), args: []Value{NewInteger(-1)}, want: NewLong(-1)},
+		{op: 0x86, name: "i2f", desc: "(I)F", build: ops(0x1A, 0x86, 0xAE), args: []Value{NewInteger(16777217)}, want: NewFloat(16777216)},
+		{op: 0x87, name: "i2d", desc: "(I)D", build: ops(0x1A, 0x87, 0xAF), args: []Value{NewInteger(-3)}, want: NewDouble(-3)},
+		{op: 0x88, name: "l2i", desc: "(J)I", build: ops(0x1E, 0x88, 0xAC), args: long2(0x100000001), want: NewInteger(1)},
+		{op: 0x89, name: "l2f", desc: "(J)F", build: ops(0x1E, 0x89, 0xAE), args: long2(1 << 40), want: NewFloat(1 << 40)},
+		{op: 0x8A, name: "l2d", desc: "(J)D", build: ops(0x1E, 0x8A, 0xAF), args: long2(-1), want: NewDouble(-1)},
+		{op: 0x8B, name: "f2i NaN", desc: "(F)I", build: ops(0x22, 0x8B, 0xAC), args: []Value{NewFloat(nan32)}, want: NewInteger(0)},
+		{op: 0x8B, name: "f2i saturate", desc: "(F)I", build: ops(0x22, 0x8B, 0xAC), args: []Value{NewFloat(-1e20)}, want: NewInteger(math.MinInt32)},
+		{op: 0x8C, name: "f2l saturate", desc: "(F)J", build: ops(0x22, 0x8C, 0xAD), args: []Value{NewFloat(1e20)}, want: NewLong(math.MaxInt64)},
+		{op: 0x8D, name: "f2d", desc: "(F)D", build: ops(0x22, 0x8D, 0xAF), args: []Value{NewFloat(1.5)}, want: NewDouble(1.5)},
+		{op: 0x8E, name: "d2i saturate", desc: "(D)I", build: ops(0x26, 0x8E, 0xAC), args: double2(1e30), want: NewInteger(math.MaxInt32)},
+		{op: 0x8E, name: "d2i truncate", desc: "(D)I", build: ops(0x26, 0x8E, 0xAC), args: double2(-2.9), want: NewInteger(-2)},
+		{op: 0x8F, name: "d2l NaN", desc: "(D)J", build: ops(0x26, 0x8F, 0xAD), args: double2(nan64), want: NewLong(0)},
+		{op: 0x90, name: "d2f", desc: "(D)F", build: ops(0x26, 0x90, 0xAE), args: double2(0.1), want: NewFloat(float32(0.1))},
+		{op: 0x91, name: "i2b", desc: "(I)I", build: ops(0x1A, 0x91, 0xAC), args: []Value{NewInteger(0x1FF)}, want: NewInteger(-1)},
+		{op: 0x92, name: "i2c", desc: "(I)I", build: ops(0x1A, 0x92, 0xAC), args: []Value{NewInteger(-1)}, want: NewInteger(0xFFFF)},
+		{op: 0x93, name: "i2s", desc: "(I)I", build: ops(0x1A, 0x93, 0xAC), args: []Value{NewInteger(0x18000)}, want: NewInteger(-32768)},
+		// 比较，NaN 时 l 返回 -1 g 返回 1
+		{op: 0x94, name: "lcmp", desc: "(JJ)I", build: ops(0x1E, 0x20, 0x94, 0xAC), args: append(long2(1), long2(2)...), want: NewInteger(-1)},
+		{op: 0x95, name: "fcmpl NaN", desc: "(FF)I", build: ops(0x22, 0x23, 0x95, 0xAC), args: []Value{NewFloat(nan32), NewFloat(1)}, want: NewInteger(-1)},
+		{op: 0x95, name: "fcmpl greater", desc: "(FF)I", build: ops(0x22, 0x23, 0x95, 0xAC), args: []Value{NewFloat(2), NewFloat(1)}, want: NewInteger(1)},
+		{op: 0x96, name: "fcmpg NaN", desc: "(FF)I", build: ops(0x22, 0x23, 0x96, 0xAC), args: []Value{NewFloat(nan32), NewFloat(1)}, want: NewInteger(1)},
+		{op: 0x97, name: "dcmpl NaN", desc: "(DD)I", build: ops(0x26, 0x28, 0x97, 0xAC), args: append(double2(1), double2(nan64)...), want: NewInteger(-1)},
+		{op: 0x98, name: "dcmpg NaN", desc: "(DD)I", build: ops(0x26, 0x28, 0x98, 0xAC), args: append(double2(nan64), double2(1)...), want: NewInteger(1)},
+		{op: 0x98, name: "dcmpg equal", desc: "(DD)I", build: ops(0x26, 0x28, 0x98, 0xAC), args: append(double2(1), double2(1)...), want: NewInteger(0)},
+		// 跳转
+		{op: 0xA7, name: "goto", desc: "()I", build: func(class *testClass, code *testCode) {
+			code.jump(0xA7, "t").op(0x03, 0xAC).label("t").op(0x04, 0xAC)
+		}, want: NewInteger(1)},
+		{op: 0xC8, name: "goto_w", desc: "()I", build: func(class *testClass, code *testCode) {
+			code.jumpW(0xC8, "t").op(0x03, 0xAC).label("t").op(0x04, 0xAC)
+		}, want: NewInteger(1)},
+		{op: 0xA8, also: []byte{0x4D, 0xA9}, name: "jsr ret", desc: "()I", build: func(class *testClass, code *testCode) {
+			// 子程序把局部变量 1 加 7 后返回
+			code.op(0x03, 0x3C).jump(0xA8, "sub").op(0x1B, 0xAC).label("sub").op(0x4D, 0x84, 1, 7, 0xA9, 2)
+		}, want: NewInteger(7)},
+		{op: 0xC9, name: "jsr_w", desc: "()I", build: func(class *testClass, code *testCode) {
+			code.op(0x03, 0x3C).jumpW(0xC9, "sub").op(0x1B, 0xAC).label("sub").op(0x4D, 0x84, 1, 7, 0xA9, 2)
+		}, want: NewInteger(7)},
+		{op: 0xA5, name: "if_acmpeq same", desc: "()I", build: branchOps(0xA5, 0x04, 0xBC, ArrayInt, 0x59), want: NewInteger(1)},
+		{op: 0xA5, name: "if_acmpeq different", desc: "()I", build: branchOps(0xA5, 0x04, 0xBC, ArrayInt, 0x04, 0xBC, ArrayInt), want: NewInteger(0)},
+		{op: 0xA6, name: "if_acmpne same", desc: "()I", build: branchOps(0xA6, 0x04, 0xBC, ArrayInt, 0x59), want: NewInteger(0)},
+		{op: 0xA6, name: "if_acmpne different", desc: "()I", build: branchOps(0xA6, 0x04, 0xBC, ArrayInt, 0x04, 0xBC, ArrayInt), want: NewInteger(1)},
+		{op: 0xC6, name: "ifnull null", desc: "()I", build: branchOps(0xC6, 0x01), want: NewInteger(1)},
+		{op: 0xC6, name: "ifnull object", desc: "()I", build: branchOps(0xC6, 0x04, 0xBC, ArrayInt), want: NewInteger(0)},
+		{op: 0xC7, name: "ifnonnull null", desc: "()I", build: branchOps(0xC7, 0x01), want: NewInteger(0)},
+		{op: 0xC7, name: "ifnonnull object", desc: "()I", build: branchOps(0xC7, 0x04, 0xBC, ArrayInt), want: NewInteger(1)},
+		{op: 0xB1, name: "return", desc: "()V", build: ops(0xB1)},
+		// 字段与方法调用
+		{op: 0xB3, also: []byte{0xB2}, name: "putstatic getstatic", desc: "()I", build: func(class *testClass, code *testCode) {
+			field := class.ref(ConstField, "Opcodes", "count", "I")
+			code.op(0x10, 42).op16(0xB3, field).op16(0xB2, field).op(0xAC)
+		}, want: NewInteger(42)},
+		{op: 0xB5, also: []byte{0xB4}, name: "putfield getfield", desc: "()J", build: func(class *testClass, code *testCode) {
+			field := class.ref(ConstField, "Opcodes", "total", "J")
+			newOpcodes(class, code)
+			code.op(0x59).op16(0x14, class.long(-5)).op16(0xB5, field).op16(0xB4, field).op(0xAD)
+		}, want: NewLong(-5)},
+		{op: 0xB4, name: "getfield null", desc: "()J", build: func(class *testClass, code *testCode) {
+			code.op(0x01).op16(0xB4, class.ref(ConstField, "Opcodes", "total", "J")).op(0xAD)
+		}, exception: "java/lang/NullPointerException"},
+		{op: 0xB8, name: "invokestatic", desc: "()I", build: func(class *testClass, code *testCode) {
+			code.op(0x10, 21).op16(0xB8, class.ref(ConstMethod, "Opcodes", "twice", "(I)I")).op(0xAC)
+		}, want: NewInteger(42)},
+		{op: 0xB6, name: "invokevirtual", desc: "()I", build: func(class *testClass, code *testCode) {
+			newOpcodes(class, code)
+			code.op16(0xB6, class.ref(ConstMethod, "Opcodes", "value", "()I")).op(0xAC)
+		}, want: NewInteger(7)},
+		{op: 0xB6, name: "invokevirtual null", desc: "()I", build: func(class *testClass, code *testCode) {
+			code.op(0x01).op16(0xB6, class.ref(ConstMethod, "Opcodes", "value", "()I")).op(0xAC)
+		}, exception: "java/lang/NullPointerException"},
+		{op: 0xB7, name: "invokespecial private", desc: "()I", build: func(class *testClass, code *testCode) {
+			newOpcodes(class, code)
+			code.op16(0xB7, class.ref(ConstMethod, "Opcodes", "secret", "()I")).op(0xAC)
+		}, want: NewInteger(8)},
+		{op: 0xB9, name: "invokeinterface", desc: "()I", build: func(class *testClass, code *testCode) {
+			newOpcodes(class, code)
+			code.op16(0xB9, class.ref(ConstInterfaceMethod, "OpIface", "value", "()I")).op(1, 0, 0xAC)
+		}, want: NewInteger(7)},
+		// 对象与数组
+		{op: 0xBB, name: "new", desc: "()I", build: func(class *testClass, code *testCode) {
+			newOpcodes(class, code)
+			code.op16(0xC1, class.class("OpIface")).op(0xAC)
+		}, want: NewInteger(1)},
+		{op: 0xBB, name: "new interface", desc: "()V", build: func(class *testClass, code *testCode) {
+			code.op16(0xBB, class.class("OpIface")).op(0xB1)
+		}, exception: "java/lang/InstantiationError"},
+		{op: 0xBB, name: "new abstract class", desc: "()V", build: func(class *testClass, code *testCode) {
+			code.op16(0xBB, class.class("OpAbstract")).op(0xB1)
+		}, exception: "java/lang/InstantiationError"},
+		{op: 0xBC, name: "newarray negative", desc: "()V", build: ops(0x02, 0xBC, ArrayInt, 0xB1), exception: "java/lang/NegativeArraySizeException"},
+		{op: 0xBD, name: "anewarray", desc: "()I", build: func(class *testClass, code *testCode) {
+			code.op(0x06).op16(0xBD, class.class("java/lang/String")).op(0xBE, 0xAC)
+		}, want: NewInteger(3)},
+		{op: 0xBE, name: "arraylength null", desc: "()I", build: ops(0x01, 0xBE, 0xAC), exception: "java/lang/NullPointerException"},
+		{op: 0xBF, name: "athrow", desc: "()V", build: func(class *testClass, code *testCode) {
+			code.op16(0xBB, class.class("java/lang/ArithmeticException")).op(0x59).
+				op16(0xB7, class.ref(ConstMethod, "java/lang/ArithmeticException", "<init>", "()V")).op(0xBF)
+		}, exception: "java/lang/ArithmeticException"},
+		{op: 0xBF, name: "athrow null", desc: "()V", build: ops(0x01, 0xBF), exception: "java/lang/NullPointerException"},
+		{op: 0xC0, name: "checkcast", desc: "()I", build: func(class *testClass, code *testCode) {
+			code.op(0x04, 0xBC, ArrayInt).op16(0xC0, class.class("[I")).op(0xBE, 0xAC)
+		}, want: NewInteger(1)},
+		{op: 0xC0, name: "checkcast null", desc: "()Ljava/lang/Object;", build: func(class *testClass, code *testCode) {
+			code.op(0x01).op16(0xC0, class.class("java/lang/String")).op(0xB0)
+		}, want: NewNull()},
+		{op: 0xC0, name: "checkcast fail", desc: "()V", build: func(class *testClass, code *testCode) {
+			code.op(0x04, 0xBC, ArrayInt).op16(0xC0, class.class("[J")).op(0xB1)
+		}, exception: "java/lang/ClassCastException"},
+		{op: 0xC1, name: "instanceof null", desc: "()I", build: func(class *testClass, code *testCode) {
+			code.op(0x01).op16(0xC1, class.class("java/lang/Object")).op(0xAC)
+		}, want: NewInteger(0)},
+		{op: 0xC1, name: "instanceof array", desc: "()I", build: func(class *testClass, code *testCode) {
+			code.op(0x04, 0xBC, ArrayInt).op16(0xC1, class.class("java/lang/Cloneable")).op(0xAC)
+		}, want: NewInteger(1)},
+		{op: 0xC2, name: "monitorenter monitorexit", desc: "()I", build: ops(0x04, 0xBC, ArrayInt, 0x59, 0x4B, 0xC2, 0x2A, 0xC3, 0x04, 0xAC), want: NewInteger(1)},
+		{op: 0xC3, name: "monitorexit not owner", desc: "()V", build: ops(0x04, 0xBC, ArrayInt, 0xC3, 0xB1), exception: "java/lang/IllegalMonitorStateException"},
+		{op: 0xC2, name: "monitorenter null", desc: "()V", build: ops(0x01, 0xC2, 0xB1), exception: "java/lang/NullPointerException"},
+		{op: 0xC4, name: "wide", desc: "()I", build: func(class *testClass, code *testCode) {
+			code.maxLocals = 301
+			// wide istore 300 wide iinc 300 1000 wide iload 300
+			code.op(0x10, 5, 0xC4, 0x36, 0x01, 0x2C, 0xC4, 0x84, 0x01, 0x2C, 0x03, 0xE8, 0xC4, 0x15, 0x01, 0x2C, 0xAC)
+		}, want: NewInteger(1005)},
+		{op: 0xC5, name: "multianewarray", desc: "()I", build: func(class *testClass, code *testCode) {
+			code.op(0x05, 0x06).op16(0xC5, class.class("[[I")).op(2, 0x04, 0x32, 0xBE, 0xAC)
+		}, want: NewInteger(3)},
+		// 解析找不到的类抛出 NoClassDefFoundError，java 代码可以捕获
+		{op: 0xBB, name: "new missing class", desc: "()V", build: func(class *testClass, code *testCode) {
+			code.op16(0xBB, class.class("Gone")).op(0xB1)
+		}, exception: "java/lang/NoClassDefFoundError"},
+		{op: 0xBB, name: "new missing class caught", desc: "()I", build: func(class *testClass, code *testCode) {
+			code.label("start").op16(0xBB, class.class("Gone")).op(0x57, 0x03).label("end").op(0xAC).
+				label("handler").op(0x57, 0x04, 0xAC).catch("start", "end", "handler", "java/lang/NoClassDefFoundError")
+		}, want: NewInteger(1)},
+		{op: 0xB2, name: "getstatic missing class", desc: "()I", build: func(class *testClass, code *testCode) {
+			code.op16(0xB2, class.ref(ConstField, "Gone", "count", "I")).op(0xAC)
+		}, exception: "java/lang/NoClassDefFoundError"},
+		{op: 0xB8, name: "invokestatic missing class", desc: "()V", build: func(class *testClass, code *testCode) {
+			code.op16(0xB8, class.ref(ConstMethod, "Gone", "run", "()V")).op(0xB1)
+		}, exception: "java/lang/NoClassDefFoundError"},
+		{op: 0xC0, name: "checkcast missing class", desc: "()V", build: func(class *testClass, code *testCode) {
+			code.op(0x04, 0xBC, ArrayInt).op16(0xC0, class.class("Gone")).op(0xB1)
+		}, exception: "java/lang/NoClassDefFoundError"},
+		{op: 0xBD, name: "anewarray missing class", desc: "()V", build: func(class *testClass, code *testCode) {
+			code.op(0x04).op16(0xBD, class.class("Gone")).op(0xB1)
+		}, exception: "java/lang/NoClassDefFoundError"},
+		{op: 0xC5, name: "multianewarray missing class", desc: "()V", build: func(class *testClass, code *testCode) {
+			code.op(0x04, 0x04).op16(0xC5, class.class("[[LGone;")).op(2, 0xB1)
+		}, exception: "java/lang/NoClassDefFoundError"},
+	}
+	// iconst lconst fconst dconst
+	for i := 0; i < 7; i++ {
+		cases = append(cases, opcodeCase{op: byte(0x02 + i), name: fmt.Sprintf("iconst %d", i-1), desc: "()I",
+			build: ops(byte(0x02+i), 0xAC), want: NewInteger(int32(i - 1))})
+	}
+	for i := 0; i < 2; i++ {
+		cases = append(cases, opcodeCase{op: byte(0x09 + i), name: fmt.Sprintf("lconst %d", i), desc: "()J",
+			build: ops(byte(0x09+i), 0xAD), want: NewLong(int64(i))},
+			opcodeCase{op: byte(0x0E + i), name: fmt.Sprintf("dconst %d", i), desc: "()D",
+				build: ops(byte(0x0E+i), 0xAF), want: NewDouble(float64(i))})
+	}
+	for i := 0; i < 3; i++ {
+		cases = append(cases, opcodeCase{op: byte(0x0B + i), name: fmt.Sprintf("fconst %d", i), desc: "()F",
+			build: ops(byte(0x0B+i), 0xAE), want: NewFloat(float32(i))})
+	}
+	// xload_n xstore_n，前面的参数占位
+	for n := 0; n < 4; n++ {
+		cases = append(cases,
+			opcodeCase{op: byte(0x1A + n), name: fmt.Sprintf("iload_%d", n), desc: "(IIII)I", build: ops(byte(0x1A+n), 0xAC),
+				args: []Value{NewInteger(10), NewInteger(11), NewInteger(12), NewInteger(13)}, want: NewInteger(int32(10 + n))},
+			opcodeCase{op: byte(0x1E + n), name: fmt.Sprintf("lload_%d", n), desc: "(" + strings.Repeat("I", n) + "J)J",
+				build: ops(byte(0x1E+n), 0xAD), args: append(make([]Value, n), long2(int64(100+n))...), want: NewLong(int64(100 + n))},
+			opcodeCase{op: byte(0x22 + n), name: fmt.Sprintf("fload_%d", n), desc: "(FFFF)F", build: ops(byte(0x22+n), 0xAE),
+				args: []Value{NewFloat(0.5), NewFloat(1.5), NewFloat(2.5), NewFloat(3.5)}, want: NewFloat(float32(n) + 0.5)},
+			opcodeCase{op: byte(0x26 + n), name: fmt.Sprintf("dload_%d", n), desc: "(" + strings.Repeat("I", n) + "D)D",
+				build: ops(byte(0x26+n), 0xAF), args: append(make([]Value, n), double2(float64(n)+0.5)...), want: NewDouble(float64(n) + 0.5)},
+			opcodeCase{op: byte(0x2A + n), also: []byte{byte(0x4B + n)}, name: fmt.Sprintf("aload_%d astore_%d", n, n), desc: "()I",
+				build: ops(0x10, byte(n+2), 0xBC, ArrayInt, byte(0x4B+n), byte(0x2A+n), 0xBE, 0xAC), want: NewInteger(int32(n + 2))},
+			opcodeCase{op: byte(0x3B + n), name: fmt.Sprintf("istore_%d", n), desc: "()I",
+				build: ops(0x10, byte(n+20), byte(0x3B+n), byte(0x1A+n), 0xAC), want: NewInteger(int32(n + 20))},
+			opcodeCase{op: byte(0x3F + n), name: fmt.Sprintf("lstore_%d", n), desc: "()J",
+				build: ops(0x0A, byte(0x3F+n), byte(0x1E+n), 0xAD), want: NewLong(1)},
+			opcodeCase{op: byte(0x43 + n), name: fmt.Sprintf("fstore_%d", n), desc: "()F",
+				build: ops(0x0D, byte(0x43+n), byte(0x22+n), 0xAE), want: NewFloat(2)},
+			opcodeCase{op: byte(0x47 + n), name: fmt.Sprintf("dstore_%d", n), desc: "()D",
+				build: ops(0x0F, byte(0x47+n), byte(0x26+n), 0xAF), want: NewDouble(1)})
+	}
+	// 条件跳转，跳转时返回 1
+	conds := []func(a int32, b int32) bool{
+		func(a, b int32) bool { return a == b }, func(a, b int32) bool { return a != b },
+		func(a, b int32) bool { return a < b }, func(a, b int32) bool { return a >= b },
+		func(a, b int32) bool { return a > b }, func(a, b int32) bool { return a <= b },
+	}
+	for i, cond := range conds {
+		for _, val := range []int32{-1, 0, 1} {
+			cases = append(cases, opcodeCase{op: byte(0x99 + i), name: fmt.Sprintf("if %x %d", 0x99+i, val), desc: "(I)I",
+				build: branchOps(byte(0x99+i), 0x1A), args: []Value{NewInteger(val)}, want: NewBoolean(cond(val, 0))},
+				opcodeCase{op: byte(0x9F + i), name: fmt.Sprintf("if_icmp %x %d", 0x9F+i, val), desc: "(II)I",
+					build: branchOps(byte(0x9F+i), 0x1A, 0x1B), args: []Value{NewInteger(val), NewInteger(0)}, want: NewBoolean(cond(val, 0))})
+		}
+	}
+	// switch，1 2 3 或者 -100 7 1000 分别返回 10 20 30，其他返回 -1
+	for _, item := range [][2]int32{{0, -1}, {1, 10}, {3, 30}, {4, -1}} {
+		cases = append(cases, opcodeCase{op: 0xAA, name: fmt.Sprintf("tableswitch %d", item[0]), desc: "(I)I",
+			build: switchOps(nil), args: []Value{NewInteger(item[0])}, want: NewInteger(item[1])})
+	}
+	for _, item := range [][2]int32{{-100, 10}, {7, 20}, {1000, 30}, {8, -1}} {
+		cases = append(cases, opcodeCase{op: 0xAB, name: fmt.Sprintf("lookupswitch %d", item[0]), desc: "(I)I",
+			build: switchOps([]int32{-100, 7, 1000}), args: []Value{NewInteger(item[0])}, want: NewInteger(item[1])})
+	}
+	return cases
+}
+
+func intCase(op byte, name string, a int32, b int32, want int32) opcodeCase {
+	return opcodeCase{op: op, name: name, desc: "(II)I", build: ops(0x1A, 0x1B, op, 0xAC),
+		args: []Value{NewInteger(a), NewInteger(b)}, want: NewInteger(want)}
+}
+
+func longCase(op byte, name string, a int64, b int64, want int64) opcodeCase {
+	return opcodeCase{op: op, name: name, desc: "(JJ)J", build: ops(0x1E, 0x20, op, 0xAD),
+		args: append(long2(a), long2(b)...), want: NewLong(want)}
+}
+
+func shiftCase(op byte, name string, a int64, b int32, want int64) opcodeCase {
+	return opcodeCase{op: op, name: name, desc: "(JI)J", build: ops(0x1E, 0x1C, op, 0xAD),
+		args: append(long2(a), NewInteger(b)), want: NewLong(want)}
+}
+
+func doubleCase(op byte, name string, a float64, b float64, want float64) opcodeCase {
+	return opcodeCase{op: op, name: name, desc: "(DD)D", build: ops(0x26, 0x28, op, 0xAF),
+		args: append(double2(a), double2(b)...), want: NewDouble(want)}
+}
+
+// 创建长度为 2 的数组，把 value 压入的值存入下标 1 再读出
+func arrayOps(arrayType byte, store byte, load byte, ret byte, value ...byte) func(class *testClass, code *testCode) {
+	return func(class *testClass, code *testCode) {
+		code.op(0x05, 0xBC, arrayType, 0x59, 0x04).op(value...).op(store, 0x04, load, ret)
+	}
+}
+
+// 执行 load 压入操作数后执行跳转指令，跳转时返回 1 否则返回 0
+func branchOps(op byte, load ...byte) func(class *testClass, code *testCode) {
+	return func(class *testClass, code *testCode) {
+		code.op(load...).jump(op, "t").op(0x03, 0xAC).label("t").op(0x04, 0xAC)
+	}
+}
+
+func switchOps(keys []int32) func(class *testClass, code *testCode) {
+	return func(class *testClass, code *testCode) {
+		code.op(0x1A).switchOp(1, keys, "d", "a", "b", "c")
+		code.label("a").op(0x10, 10, 0xAC).label("b").op(0x10, 20, 0xAC).label("c").op(0x10, 30, 0xAC)
+		code.label("d").op(0x02, 0xAC)
+	}
+}
+
+// new Opcodes()
+func newOpcodes(class *testClass, code *testCode) {
+	code.op16(0xBB, class.class("Opcodes")).op(0x59).op16(0xB7, class.ref(ConstMethod, "Opcodes", "<init>", "()V"))
+}
+
+// 每个用例生成 Opcodes 的一个静态方法，另外包含调用与字段访问需要的成员
+func opcodeClass(cases []opcodeCase) *testClass {
+	class := newTestClass("Opcodes", "java/lang/Object", "OpIface")
+	defaultInit(class)
+	class.field(AccessStatic, "count", "I")
+	class.field(0, "total", "J")
+	class.method(AccessStatic, "twice", "(I)I", newTestCode(2, 1).op(0x1A, 0x05, 0x68, 0xAC))
+	class.method(AccessPublic, "value", "()I", newTestCode(1, 1).op(0x10, 7, 0xAC))
+	class.method(AccessPrivate, "secret", "()I", newTestCode(1, 1).op(0x10, 8, 0xAC))
+	for i, item := range cases {
+		code := newTestCode(8, 8)
+		item.build(class, code)
+		class.method(AccessStatic, fmt.Sprintf("case%d", i), item.desc, code)
+	}
+	return class
+}
+
+func TestInstructions(t *testing.T) {
+	cases := opcodeCases()
+	vm := newTestVM(t, opcodeClass(cases), opcodeIface(), opcodeAbstract())
+	covered := make(map[byte]bool)
+	for i, test := range cases {
+		covered[test.op] = true
+		for _, op := range test.also {
+			covered[op] = true
+		}
+		t.Run(fmt.Sprintf("%02X %s", test.op, test.name), func(t *testing.T) {
+			res, exception := runStatic(vm, "Opcodes", fmt.Sprintf("case%d", i), test.desc, test.args...)
+			if test.exception != "" {
+				if exception == nil || exception.Object.Class.GetName() != test.exception {
+					t.Fatalf("exception = %v, want %s", exception, test.exception)
+				}
+				return
+			}
+			if exception != nil {
+				t.Fatalf("uncaught %s", exception.Object.Class.GetName())
+			}
+			if res != test.want {
+				t.Errorf("res = %#x %v, want %#x %v", res.Num, res.Object, test.want.Num, test.want.Object)
+			}
+		})
+	}
+	// invokedynamic 在 lambda_test.go 中测试
+	for op := range Instructions {
+		if !covered[op] && op != 0xBA {
+			t.Errorf("opcode %02X %s has no test case", op, InstructionNames[op])
+		}
+	}
+}
+
+// javac 编译 switch (s) { case "Aa": case "BB": case "hello": } 的方式，先按 hashCode 使用 lookupswitch 再用 equals 比较得到下标，
+// "Aa" 与 "BB" 的 hashCode 都是 2112，下标再经过 tableswitch 得到 1 2 3，没有匹配时为 0
+// 枚举 switch (c) { case BLUE: case RED: case GREEN: } 通过合成类 Switch$1 中的 $SwitchMap$Color 把 ordinal 映射为 case 顺序
+func switchClasses() []*testClass {
+	color := newTestClass("Color", "java/lang/Enum")
+	color.access |= AccessFinal
+	names := []string{"RED", "GREEN", "BLUE"}
+	for _, name := range names {
+		color.field(AccessPublic|AccessStatic|AccessFinal, name, "LColor;")
+	}
+	color.field(AccessPrivate|AccessStatic|AccessFinal, "$VALUES", "[LColor;")
+	values := color.ref(ConstField, "Color", "$VALUES", "[LColor;")
+	color.method(AccessPrivate, "<init>", "(Ljava/lang/String;I)V", newTestCode(3, 3).
+		op(0x2A, 0x2B, 0x1C).op16(0xB7, color.ref(ConstMethod, "java/lang/Enum", "<init>", "(Ljava/lang/String;I)V")).op(0xB1))
+	clinit := newTestCode(6, 0)
+	for i, name := range names { // new dup ldc_w iconst_i invokespecial putstatic
+		clinit.op16(0xBB, color.class("Color")).op(0x59).op16(0x13, color.str(name)).op(byte(0x03+i)).
+			op16(0xB7, color.ref(ConstMethod, "Color", "<init>", "(Ljava/lang/String;I)V")).
+			op16(0xB3, color.ref(ConstField, "Color", name, "LColor;"))
+	}
+	clinit.op(0x06).op16(0xBD, color.class("Color")) // iconst_3 anewarray
+	for i, name := range names {                     // dup iconst_i getstatic aastore
+		clinit.op(0x59, byte(0x03+i)).op16(0xB2, color.ref(ConstField, "Color", name, "LColor;")).op(0x53)
+	}
+	color.method(AccessStatic, "<clinit>", "()V", clinit.op16(0xB3, values).op(0xB1))
+	color.method(AccessPublic|AccessStatic, "values", "()[LColor;", newTestCode(1, 0).
+		op16(0xB2, values).op16(0xB6, color.ref(ConstMethod, "[LColor;", "clone", "()Ljava/lang/Object;")). // getstatic invokevirtual
+		op16(0xC0, color.class("[LColor;")).op(0xB0))                                                       // checkcast areturn
+	ordinal := func(class *testClass) uint16 {
+		return class.ref(ConstMethod, "Color", "ordinal", "()I")
+	}
+
+	switchMap := newTestClass("Switch$1", "java/lang/Object")
+	switchMap.field(AccessStatic|AccessFinal|AccessSynthetic, "$SwitchMap$Color", "[I")
+	mapField := switchMap.ref(ConstField, "Switch$1", "$SwitchMap$Color", "[I")
+	// $SwitchMap$Color = new int[Color.values().length]，每一项都捕获 NoSuchFieldError
+	clinit = newTestCode(3, 1).op16(0xB8, switchMap.ref(ConstMethod, "Color", "values", "()[LColor;")).
+		op(0xBE, 0xBC, ArrayInt).op16(0xB3, mapField) // arraylength newarray putstatic
+	for i, name := range []string{"BLUE", "RED", "GREEN"} {
+		start, end, handler, next := fmt.Sprint("s", i), fmt.Sprint("e", i), fmt.Sprint("h", i), fmt.Sprint("n", i)
+		clinit.label(start).op16(0xB2, mapField).op16(0xB2, switchMap.ref(ConstField, "Color", name, "LColor;")). // getstatic getstatic
+																op16(0xB6, ordinal(switchMap)).op(byte(0x04+i), 0x4F).label(end).jump(0xA7, next). // invokevirtual iconst_x iastore goto
+																label(handler).op(0x4B).label(next).                                               // astore_0
+																catch(start, end, handler, "java/lang/NoSuchFieldError")
+	}
+	switchMap.method(AccessStatic, "<clinit>", "()V", clinit.op(0xB1))
+
+	class := newTestClass("Switch", "java/lang/Object")
+	equals := class.ref(ConstMethod, "java/lang/String", "equals", "(Ljava/lang/Object;)Z")
+	// aload_0 astore_1 iconst_m1 istore_2 aload_1 invokevirtual lookupswitch
+	code := newTestCode(2, 3).op(0x2A, 0x4C, 0x02, 0x3D, 0x2B).
+		op16(0xB6, class.ref(ConstMethod, "java/lang/String", "hashCode", "()I")).
+		switchOp(0, []int32{2112, 99162322}, "index", "2112", "hello")
+	// aload_1 ldc_w invokevirtual ifeq iconst_x istore_2 goto
+	code.label("2112").op(0x2B).op16(0x13, class.str("Aa")).op16(0xB6, equals).jump(0x99, "BB").
+		op(0x03, 0x3D).jump(0xA7, "index")
+	code.label("BB").op(0x2B).op16(0x13, class.str("BB")).op16(0xB6, equals).jump(0x99, "index").
+		op(0x04, 0x3D).jump(0xA7, "index")
+	code.label("hello").op(0x2B).op16(0x13, class.str("hello")).op16(0xB6, equals).jump(0x99, "index").
+		op(0x05, 0x3D)
+	// iload_2 tableswitch
+	code.label("index").op(0x1C).switchOp(0, nil, "d", "a", "b", "c").
+		label("a").op(0x04, 0xAC).label("b").op(0x05, 0xAC).label("c").op(0x06, 0xAC).label("d").op(0x03, 0xAC)
+	class.method(AccessStatic, "stringSwitch", "(Ljava/lang/String;)I", code)
+	class.method(AccessStatic, "enumSwitch", "(LColor;)I", newTestCode(2, 1).
+		op16(0xB2, class.ref(ConstField, "Switch$1", "$SwitchMap$Color", "[I")).op(0x2A).op16(0xB6, ordinal(class)). // getstatic aload_0 invokevirtual
+		op(0x2E).switchOp(1, nil, "d", "blue", "red", "green").                                                      // iaload tableswitch
+		label("blue").op(0x10, 30, 0xAC).label("red").op(0x10, 10, 0xAC).label("green").op(0x10, 20, 0xAC).
+		label("d").op(0x03, 0xAC))
+	// enumSwitch(Color.values()[i])
+	class.method(AccessStatic, "enumCase", "(I)I", newTestCode(2, 1).
+		op16(0xB8, class.ref(ConstMethod, "Color", "values", "()[LColor;")).op(0x1A, 0x32). // invokestatic iload_0 aaload
+		op16(0xB8, class.ref(ConstMethod, "Switch", "enumSwitch", "(LColor;)I")).op(0xAC))
+	return []*testClass{color, switchMap, class}
+}
+
+func TestSwitchStatements(t *testing.T) {
+	vm := newTestVM(t, append(fixtureRuntime(), switchClasses()...)...)
+	tests := []struct {
+		name  string
+		desc  string
+		arg   Value
+		label string
+		want  int32
+	}{
+		{"stringSwitch", "(Ljava/lang/String;)I", NewString(vm.Loader, "Aa"), "Aa", 1},
+		{"stringSwitch", "(Ljava/lang/String;)I", NewString(vm.Loader, "BB"), "BB", 2},
+		{"stringSwitch", "(Ljava/lang/String;)I", NewString(vm.Loader, "hello"), "hello", 3},
+		{"stringSwitch", "(Ljava/lang/String;)I", NewString(vm.Loader, "other"), "other", 0},
+		{"stringSwitch", "(Ljava/lang/String;)I", NewString(vm.Loader, "Ab"), "Ab", 0},
+		{"enumCase", "(I)I", NewInteger(0), "RED", 10},
+		{"enumCase", "(I)I", NewInteger(1), "GREEN", 20},
+		{"enumCase", "(I)I", NewInteger(2), "BLUE", 30},
+	}
+	for _, test := range tests {
+		t.Run(test.name+" "+test.label, func(t *testing.T) {
+			res, exception := runStatic(vm, "Switch", test.name, test.desc, test.arg)
+			if exception != nil {
+				t.Fatalf("uncaught %s", exception.Object.Class.GetName())
+			}
+			if res.Integer() != test.want {
+				t.Errorf("res = %d, want %d", res.Integer(), test.want)
+			}
+		})
+	}
+}
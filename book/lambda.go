@@ -0,0 +1,329 @@
+/*
+@author: sk
+@date: 2026/10/18
+*/
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// altMetafactory 的 flags
+const (
+	LambdaSerializable = 1
+	LambdaMarkers      = 2
+	LambdaBridges      = 4
+)
+
+// invokedynamic 只支持 LambdaMetafactory，每个调用点生成一个实现函数式接口的类，捕获的参数作为实例字段
+func InstructionInvokeDynamic(thread *Thread, class *Class, code *Code, pc int) int {
+	index := ParseU16(code.Code, pc)
+	callSite := class.Consts[index]
+	if callSite.CallSite == nil {
+		callSite.CallSite = makeLambdaClass(thread, class, callSite)
+	}
+	lambdaClass := callSite.CallSite
+	frame := thread.Peek()
+	lambda := HeapAlloc(thread, lambdaClass, 0)
+	for i := len(lambda.Fields) - 1; i >= 0; i-- { // 按栈上的位置原样保存，调用时原样压回
+		lambda.Fields[i] = frame.Pop()
+	}
+	frame.Push(NewObject(lambda))
+	return pc + 4 // 还有 2 byte 固定为 0
+}
+
+func makeLambdaClass(thread *Thread, class *Class, callSite *Const) *Class {
+	bootstrap := class.GetBootstrapMethods()[callSite.ClassIndex]
+	bootstrapRef := class.Consts[class.Consts[bootstrap.MethodRef].RefIndex]
+	bootstrapClass := class.GetString(bootstrapRef.ClassIndex)
+	bootstrapName := class.GetString(class.Consts[bootstrapRef.NameTypeIndex].NameIndex)
+	if bootstrapClass != "java/lang/invoke/LambdaMetafactory" || (bootstrapName != "metafactory" && bootstrapName != "altMetafactory") {
+		ThrowNew(thread, "java/lang/BootstrapMethodError", fmt.Sprintf("bootstrap method %s.%s not support", toJavaName(bootstrapClass), bootstrapName))
+	}
+	// 调用点的名称为接口方法名称，描述符的参数为捕获的参数，返回值为函数式接口
+	nameType := class.Consts[callSite.NameTypeIndex]
+	name := class.GetString(nameType.NameIndex)
+	desc := class.GetString(nameType.DescIndex)
+	// 参数依次为 samMethodType implMethod instantiatedMethodType [flags ...]
+	args := bootstrap.Args
+	samDesc := class.GetString(args[0])
+	implHandle := class.Consts[args[1]]
+	implMethod := resolveMethod(thread, class, int(implHandle.RefIndex))
+	instantiatedDesc := class.GetString(args[2])
+	interfaces := []string{desc[strings.IndexByte(desc, ')')+2 : len(desc)-1]}
+	methodDescs := []string{samDesc}
+	if bootstrapName == "altMetafactory" {
+		flags := class.Consts[args[3]].Integer
+		args = args[4:]
+		if flags&LambdaSerializable > 0 {
+			interfaces = append(interfaces, "java/io/Serializable")
+		}
+		if flags&LambdaMarkers > 0 { // 额外实现的标记接口
+			count := int(class.Consts[args[0]].Integer)
+			for _, item := range args[1 : count+1] {
+				interfaces = append(interfaces, class.GetString(item))
+			}
+			args = args[count+1:]
+		}
+		if flags&LambdaBridges > 0 { // 桥接方法使用相同的实现
+			count := int(class.Consts[args[0]].Integer)
+			for _, item := range args[1 : count+1] {
+				methodDescs = append(methodDescs, class.GetString(item))
+			}
+		}
+	}
+
+	thread.VM.LambdaCount++
+	className := fmt.Sprintf("%s$$Lambda$%d", class.GetName(), thread.VM.LambdaCount)
+	lambdaClass := &Class{Consts: []*Const{{}}, Access: AccessPublic | AccessFinal | AccessSuper}
+	addUtf8 := func(val string) uint16 {
+		lambdaClass.Consts = append(lambdaClass.Consts, &Const{Type: ConstUtf8, String: val})
+		return uint16(len(lambdaClass.Consts) - 1)
+	}
+	addClass := func(val string) uint16 {
+		index := addUtf8(val)
+		lambdaClass.Consts = append(lambdaClass.Consts, &Const{Type: ConstClass, Index: index})
+		return uint16(len(lambdaClass.Consts) - 1)
+	}
+	lambdaClass.ThisIndex = addClass(className)
+	lambdaClass.SupperIndex = addClass("java/lang/Object")
+	for _, item := range interfaces {
+		lambdaClass.Interfaces = append(lambdaClass.Interfaces, addClass(item))
+	}
+	for i, item := range splitArgDescs(desc) { // 捕获的参数
+		lambdaClass.Fields = append(lambdaClass.Fields, &Field{Access: AccessPrivate | AccessFinal,
+			NameIndex: addUtf8(fmt.Sprintf("arg$%d", i+1)), DescIndex: addUtf8(item), Class: lambdaClass})
+	}
+	for _, item := range methodDescs { // 接口方法实现为本地方法，转发给 implMethod
+		lambdaClass.Methods = append(lambdaClass.Methods, &Field{Access: AccessPublic | AccessNative,
+			NameIndex: addUtf8(name), DescIndex: addUtf8(item), Class: lambdaClass})
+		target := newLambdaTarget(thread, implHandle.RefKind, implMethod, len(splitArgDescs(desc)), instantiatedDesc, item)
+		thread.VM.RegisterNativeFunc(className, name, item, lambdaNativeFunc(target))
+	}
+	thread.Loader.DefineClass(lambdaClass)
+	thread.Loader.LinkClass(lambdaClass)
+	return lambdaClass
+}
+
+// 拆分方法描述符中的参数，返回每个参数的字段描述符
+func splitArgDescs(desc string) []string {
+	res := make([]string, 0)
+	for i := 1; desc[i] != ')'; {
+		start := i
+		for desc[i] == '[' {
+			i++
+		}
+		if desc[i] == 'L' {
+			for desc[i] != ';' {
+				i++
+			}
+		}
+		i++
+		res = append(res, desc[start:i])
+	}
+	return res
+}
+
+// 方法描述符的返回值类型
+func returnDesc(desc string) string {
+	return desc[strings.IndexByte(desc, ')')+1:]
+}
+
+// lambda 类的接口方法转发给 implMethod 时参数与返回值的类型，不一致时按照 LambdaMetafactory 的规则转换
+// 例如 Function<String, Integer> f = Integer::parseInt 的返回值需要把 int 装箱为 Integer
+type lambdaTarget struct {
+	refKind    uint8
+	implMethod *Field
+	argFrom    []string // 接口方法的参数，使用 instantiatedMethodType 中的类型
+	argTo      []string // 对应的 implMethod 参数，实例方法包含接收者，不包括捕获的参数
+	retFrom    string   // implMethod 的返回值，Xxx::new 为创建的类
+	retTo      string   // 接口方法的返回值
+}
+
+// 类型无法转换时与 LambdaMetafactory 一致在链接调用点时抛出 BootstrapMethodError
+func newLambdaTarget(thread *Thread, refKind uint8, implMethod *Field, captured int, instantiatedDesc string, desc string) *lambdaTarget {
+	implDesc := implMethod.GetDesc()
+	implClass := "L" + implMethod.Class.GetName() + ";"
+	argTo := splitArgDescs(implDesc)
+	retFrom := returnDesc(implDesc)
+	switch refKind {
+	case RefInvokeVirtual, RefInvokeInterface, RefInvokeSpecial: // 接收者作为第一个参数
+		argTo = append([]string{implClass}, argTo...)
+	case RefNewInvokeSpecial:
+		retFrom = implClass
+	}
+	argFrom := splitArgDescs(instantiatedDesc)
+	if captured > len(argTo) || len(argTo)-captured != len(argFrom) {
+		ThrowNew(thread, "java/lang/BootstrapMethodError", fmt.Sprintf("Incorrect number of parameters for %s", methodName(implMethod)))
+	}
+	target := &lambdaTarget{refKind: refKind, implMethod: implMethod, argFrom: argFrom, argTo: argTo[captured:],
+		retFrom: retFrom, retTo: returnDesc(desc)}
+	for i, item := range target.argFrom {
+		if !lambdaAdaptable(thread, item, target.argTo[i], true) {
+			ThrowNew(thread, "java/lang/BootstrapMethodError", fmt.Sprintf("Type mismatch for lambda argument %d: %s is not convertible to %s", i, item, target.argTo[i]))
+		}
+	}
+	if !lambdaAdaptable(thread, target.retFrom, target.retTo, false) {
+		ThrowNew(thread, "java/lang/BootstrapMethodError", fmt.Sprintf("Type mismatch for lambda return: %s is not convertible to %s", target.retFrom, target.retTo))
+	}
+	return target
+}
+
+// 基本类型对应的包装类型
+var wrapperClassNames = map[string]string{"Z": "java/lang/Boolean", "B": "java/lang/Byte", "C": "java/lang/Character",
+	"S": "java/lang/Short", "I": "java/lang/Integer", "J": "java/lang/Long", "F": "java/lang/Float", "D": "java/lang/Double"}
+
+// 包装类型对应的基本类型，不是包装类型返回空字符串
+func unwrapDesc(desc string) string {
+	for primitive, className := range wrapperClassNames {
+		if desc == "L"+className+";" {
+			return primitive
+		}
+	}
+	return ""
+}
+
+// 基本类型的拓宽转换，https://docs.oracle.com/javase/specs/jls/se8/html/jls-5.html#jls-5.1.2
+var primitiveWidening = map[string]string{"B": "SIJFD", "S": "IJFD", "C": "IJFD", "I": "JFD", "J": "FD", "F": "D"}
+
+// 参数使用严格的规则，引用类型需要是 to 的子类型，返回值在运行时转换
+func lambdaAdaptable(thread *Thread, from string, to string, strict bool) bool {
+	switch {
+	case from == to || to == "V": // 接口方法返回 void 时丢弃返回值
+		return true
+	case from == "V":
+		return false
+	case IsPrimitiveDesc(from) && IsPrimitiveDesc(to):
+		return strings.Contains(primitiveWidening[from], to)
+	case IsPrimitiveDesc(from): // 装箱后需要是 to 的子类型，例如 int 可以转换为 Integer Number Object
+		return instanceOf(thread, thread.Loader.LoadClass(wrapperClassNames[from]), thread.Loader.LoadClass(ComponentClassName("["+to)))
+	case IsPrimitiveDesc(to): // 包装类型拆箱后拓宽，其他类型运行时转换为 to 的包装类型后拆箱
+		primitive := unwrapDesc(from)
+		if primitive == "" {
+			return !strict
+		}
+		return primitive == to || strings.Contains(primitiveWidening[primitive], to)
+	default:
+		return !strict || instanceOf(thread, thread.Loader.LoadClass(ComponentClassName("["+from)), thread.Loader.LoadClass(ComponentClassName("["+to)))
+	}
+}
+
+// 按照 from 的类型转换为 to 的类型，装箱拆箱调用包装类型的 valueOf xxxValue 方法
+func lambdaConvert(thread *Thread, val Value, from string, to string) Value {
+	switch {
+	case from == to || to == "V":
+		return val
+	case IsPrimitiveDesc(from) && IsPrimitiveDesc(to):
+		return widenPrimitive(val, from, to)
+	case IsPrimitiveDesc(from):
+		wrapper := wrapperClassNames[from]
+		return callWrapper(thread, wrapper, "valueOf", "("+from+")L"+wrapper+";", val, from)
+	case IsPrimitiveDesc(to):
+		primitive := unwrapDesc(from)
+		if primitive == "" {
+			primitive = to
+		}
+		wrapper := wrapperClassNames[primitive]
+		obj := checkNotNull(thread, val)
+		checkCast(thread, obj, thread.Loader.LoadClass(wrapper))
+		res := callWrapper(thread, wrapper, primitiveClassNames[primitive]+"Value", "()"+primitive, val, "L"+wrapper+";")
+		return widenPrimitive(res, primitive, to)
+	default:
+		if val.Object != nil {
+			checkCast(thread, val.Object, thread.Loader.LoadClass(ComponentClassName("["+to)))
+		}
+		return val
+	}
+}
+
+func widenPrimitive(val Value, from string, to string) Value {
+	if from == to {
+		return val
+	}
+	switch from {
+	case "J":
+		if to == "F" {
+			return NewFloat(float32(val.Long()))
+		}
+		return NewDouble(float64(val.Long()))
+	case "F":
+		return NewDouble(float64(val.Float()))
+	}
+	switch to { // byte short char int 都以 int 存储
+	case "J":
+		return NewLong(int64(val.Integer()))
+	case "F":
+		return NewFloat(float32(val.Integer()))
+	case "D":
+		return NewDouble(float64(val.Integer()))
+	}
+	return val
+}
+
+// 调用包装类型的方法，参数通过当前栈帧传递，返回值从当前栈帧取回
+func callWrapper(thread *Thread, className string, name string, desc string, arg Value, argDesc string) Value {
+	class := thread.Loader.LoadClass(className)
+	InitClass(thread, class)
+	frame := thread.Peek()
+	pushValue(frame, arg, argDesc)
+	CallMethod(thread, class.GetMethod(name, desc))
+	return popValue(frame, returnDesc(desc))
+}
+
+func pushValue(frame *Frame, val Value, desc string) {
+	if desc == "J" || desc == "D" {
+		frame.Push2(val)
+	} else {
+		frame.Push(val)
+	}
+}
+
+func popValue(frame *Frame, desc string) Value {
+	if desc == "J" || desc == "D" {
+		return frame.Pop2()
+	}
+	return frame.Pop()
+}
+
+func lambdaNativeFunc(target *lambdaTarget) NativeFunc {
+	return func(thread *Thread) {
+		frame := thread.Peek()
+		args := make([]Value, len(target.argFrom))
+		for i := len(args) - 1; i >= 0; i-- {
+			args[i] = popValue(frame, target.argFrom[i])
+		}
+		lambda := frame.Pop().Object
+		method := target.implMethod
+		if target.refKind == RefNewInvokeSpecial { // Xxx::new 先创建对象，与 new dup 一致压入两次，<init> 返回后留下一个作为返回值
+			InitClass(thread, method.Class)
+			obj := NewObject(HeapAlloc(thread, method.Class, 0))
+			frame.Push(obj)
+			frame.Push(obj)
+		}
+		// 按照 捕获参数 接口方法参数 的顺序压回
+		for _, val := range lambda.Fields {
+			frame.Push(val)
+		}
+		for i, val := range args {
+			pushValue(frame, lambdaConvert(thread, val, target.argFrom[i], target.argTo[i]), target.argTo[i])
+		}
+		switch target.refKind {
+		case RefInvokeStatic:
+			InitClass(thread, method.Class)
+		case RefInvokeVirtual, RefInvokeInterface: // 方法引用需要按照接收者动态绑定
+			method = selectMethod(thread, peekReceiver(thread, method).Class, method)
+		}
+		if target.retFrom == target.retTo || (!IsPrimitiveDesc(target.retFrom) && !IsPrimitiveDesc(target.retTo)) {
+			invokeMethod(thread, method) // 与 invoke 指令相同只压入栈帧，本地方法返回后由解释器执行
+			return
+		}
+		// 返回值需要转换，等待执行完成
+		CallMethod(thread, method)
+		if target.retTo != "V" {
+			pushValue(frame, lambdaConvert(thread, popValue(frame, target.retFrom), target.retFrom, target.retTo), target.retTo)
+		} else if target.retFrom != "V" {
+			popValue(frame, target.retFrom)
+		}
+	}
+}
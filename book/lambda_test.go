@@ -0,0 +1,132 @@
+/*
+@author: sk
+@date: 2026/10/18
+*/
+package main
+
+import "testing"
+
+const metafactoryDesc = "(Ljava/lang/invoke/MethodHandles$Lookup;Ljava/lang/String;Ljava/lang/invoke/MethodType;" +
+	"Ljava/lang/invoke/MethodType;Ljava/lang/invoke/MethodHandle;Ljava/lang/invoke/MethodType;)Ljava/lang/invoke/CallSite;"
+
+// 只有 value 字段的 Integer，装箱拆箱需要 valueOf intValue
+func integerClass() *testClass {
+	class := newTestClass("java/lang/Integer", "java/lang/Object")
+	class.access |= AccessFinal
+	class.field(AccessPrivate|AccessFinal, "value", "I")
+	value := class.ref(ConstField, "java/lang/Integer", "value", "I")
+	class.method(AccessPublic, "<init>", "(I)V", newTestCode(2, 2).
+		op(0x2A).op16(0xB7, class.ref(ConstMethod, "java/lang/Object", "<init>", "()V")). // aload_0 invokespecial
+		op(0x2A, 0x1B).op16(0xB5, value).op(0xB1))                                        // aload_0 iload_1 putfield return
+	class.method(AccessPublic|AccessStatic, "valueOf", "(I)Ljava/lang/Integer;", newTestCode(3, 1).
+		op16(0xBB, class.class("java/lang/Integer")).op(0x59, 0x1A). // new dup iload_0
+		op16(0xB7, class.ref(ConstMethod, "java/lang/Integer", "<init>", "(I)V")).op(0xB0))
+	class.method(AccessPublic, "intValue", "()I", newTestCode(1, 1).op(0x2A).op16(0xB4, value).op(0xAC)) // aload_0 getfield ireturn
+	return class
+}
+
+func interfaceClass(name string, method string, desc string) *testClass {
+	class := newTestClass(name, "java/lang/Object")
+	class.access = AccessPublic | AccessInterface | AccessAbstract
+	class.method(AccessPublic|AccessAbstract, method, desc, nil)
+	return class
+}
+
+// Fn.apply(Object)Object 与 IntFn.applyAsInt(Object)I 相当于 Function 与 ToIntFunction
+// static int twice(int x) { return x * 2; }
+// static Integer same(Integer x) { return x; }
+// static int box(Object arg) { Function<Integer, Integer> f = Lambdas::twice; return f.apply((Integer) arg).intValue(); }
+// static int unbox(Object arg) { ToIntFunction<Integer> f = Lambdas::same; return f.applyAsInt(arg); }
+// static int mismatch(Object arg) instantiatedMethodType 为 (Object)Object，Object 不能严格转换为 int
+func lambdaClass() *testClass {
+	class := newTestClass("Lambdas", "java/lang/Object")
+	class.method(AccessStatic, "twice", "(I)I", newTestCode(2, 1).op(0x1A, 0x05, 0x68, 0xAC))
+	class.method(AccessStatic, "same", "(Ljava/lang/Integer;)Ljava/lang/Integer;", newTestCode(1, 1).op(0x2A, 0xB0))
+	metafactory := class.methodHandle(RefInvokeStatic, ConstMethod, "java/lang/invoke/LambdaMetafactory", "metafactory", metafactoryDesc)
+	lambda := func(name string, iface string, method string, samDesc string, impl string, implDesc string, instantiated string) {
+		callSite := class.invokeDynamic(metafactory, []uint16{class.methodType(samDesc),
+			class.methodHandle(RefInvokeStatic, ConstMethod, "Lambdas", impl, implDesc), class.methodType(instantiated)},
+			method, "()L"+iface+";")
+		code := newTestCode(2, 1).op16(0xBA, callSite).op(0, 0, 0x2A) // invokedynamic aload_0
+		code.op16(0xB9, class.ref(ConstInterfaceMethod, iface, method, samDesc)).op(2, 0)
+		if samDesc[len(samDesc)-1] == 'I' {
+			code.op(0xAC) // ireturn
+		} else { // checkcast Integer invokevirtual intValue ireturn
+			code.op16(0xC0, class.class("java/lang/Integer")).
+				op16(0xB6, class.ref(ConstMethod, "java/lang/Integer", "intValue", "()I")).op(0xAC)
+		}
+		class.method(AccessStatic, name, "(Ljava/lang/Object;)I", code)
+	}
+	lambda("box", "Fn", "apply", "(Ljava/lang/Object;)Ljava/lang/Object;", "twice", "(I)I",
+		"(Ljava/lang/Integer;)Ljava/lang/Integer;")
+	lambda("unbox", "IntFn", "applyAsInt", "(Ljava/lang/Object;)I", "same", "(Ljava/lang/Integer;)Ljava/lang/Integer;",
+		"(Ljava/lang/Integer;)I")
+	lambda("mismatch", "Fn", "apply", "(Ljava/lang/Object;)Ljava/lang/Object;", "twice", "(I)I",
+		"(Ljava/lang/Object;)Ljava/lang/Object;")
+	return class
+}
+
+func newLambdaVM(t *testing.T) *VM {
+	return newTestVM(t, integerClass(), lambdaClass(), interfaceClass("Fn", "apply", "(Ljava/lang/Object;)Ljava/lang/Object;"),
+		interfaceClass("IntFn", "applyAsInt", "(Ljava/lang/Object;)I"))
+}
+
+func TestLambdaAdapt(t *testing.T) {
+	vm := newLambdaVM(t)
+	integer := vm.Loader.LoadClass("java/lang/Integer")
+	boxed := func(val int32) Value {
+		obj := Alloc(integer, 0)
+		obj.Fields[integer.GetField("value", "I").SlotID] = NewInteger(val)
+		return NewObject(obj)
+	}
+	tests := []struct {
+		name      string
+		method    string
+		arg       Value
+		res       int32
+		exception string
+	}{
+		{"unbox argument box return", "box", boxed(21), 42, ""},
+		{"unbox return", "unbox", boxed(7), 7, ""},
+		{"unbox null", "box", NewNull(), 0, "java/lang/NullPointerException"},
+		{"cast argument", "box", NewString(vm.Loader, "21"), 0, "java/lang/ClassCastException"},
+		{"type mismatch", "mismatch", boxed(1), 0, "java/lang/BootstrapMethodError"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, exception := runStatic(vm, "Lambdas", test.method, "(Ljava/lang/Object;)I", test.arg)
+			if test.exception != "" {
+				if exception == nil || exception.Object.Class.GetName() != test.exception {
+					t.Fatalf("%s exception = %v, want %s", test.method, exception, test.exception)
+				}
+				return
+			}
+			if exception != nil {
+				t.Fatalf("%s uncaught %s", test.method, exception.Object.Class.GetName())
+			}
+			if res.Integer() != test.res {
+				t.Errorf("%s = %d, want %d", test.method, res.Integer(), test.res)
+			}
+		})
+	}
+}
+
+// lambda 类的编号与转发的本地方法属于各自的虚拟机
+func TestLambdaPerVM(t *testing.T) {
+	vms := []*VM{newLambdaVM(t), newLambdaVM(t)}
+	for _, vm := range vms {
+		arg := Alloc(vm.Loader.LoadClass("java/lang/Integer"), 0)
+		if _, exception := runStatic(vm, "Lambdas", "box", "(Ljava/lang/Object;)I", NewObject(arg)); exception != nil {
+			t.Fatalf("box uncaught %s", exception.Object.Class.GetName())
+		}
+		if vm.LambdaCount != 1 || len(vm.Natives) != 1 {
+			t.Errorf("LambdaCount = %d, Natives = %d, want 1 1", vm.LambdaCount, len(vm.Natives))
+		}
+		if vm.Loader.Classes["Lambdas$$Lambda$1"] == nil {
+			t.Errorf("Lambdas$$Lambda$1 not defined")
+		}
+	}
+	if GetNativeFunc("Lambdas$$Lambda$1", "apply", "(Ljava/lang/Object;)Ljava/lang/Object;") != nil {
+		t.Errorf("lambda native registered globally")
+	}
+}
@@ -7,6 +7,7 @@ package main
 import (
 	"archive/zip"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -15,6 +16,7 @@ import (
 type Loader struct {
 	Paths   []string
 	Classes map[string]*Class
+	Strings *StringTable // 虚拟机的字符串常量池，准备阶段创建字符串常量字段时使用
 }
 
 func (l *Loader) LoadClass(className string) *Class { // 最好静态与运行时分开
@@ -30,6 +32,17 @@ func (l *Loader) LoadClass(className string) *Class { // 最好静态与运行
 				SupperIndex: 3,
 				Interfaces:  []uint16{5, 7}, // 因该实现序列化接口啥的
 			}
+			l.DefineClass(l.Classes[className])
+			l.LinkClass(l.Classes[className])
+		} else if isPrimitiveClassName(className) { // 基本类型只用于创建 int.class 等 Class 对象，没有父类也没有 class 文件
+			l.Classes[className] = &Class{
+				Consts:           []*Const{{}, {Type: 7, Index: 2}, {Type: 1, String: className}},
+				Access:           AccessPublic | AccessFinal | AccessAbstract,
+				ThisIndex:        1,
+				InterfaceClasses: make([]*Class, 0),
+				InitState:        ClassInitialized,
+			}
+			l.LinkClass(l.Classes[className])
 		} else { // 加载普通类
 			// 加载解析 class
 			bs := l.LoadData(className)
@@ -43,45 +56,221 @@ func (l *Loader) LoadClass(className string) *Class { // 最好静态与运行
 	return l.Classes[className]
 }
 
+// 类的初始化，在 new getstatic putstatic invokestatic 首次使用类时触发，先初始化父类再执行自己的 <clinit>
+func InitClass(thread *Thread, class *Class) {
+	if class.FormatError != "" {
+		ThrowNew(thread, "java/lang/ClassFormatError", class.FormatError)
+	}
+	switch class.InitState {
+	case ClassInitialized, ClassInitializing: // 正在初始化说明是同一线程递归触发的，直接使用
+		return
+	case ClassErroneous:
+		ThrowNew(thread, "java/lang/NoClassDefFoundError", "Could not initialize class "+toJavaName(class.GetString(class.ThisIndex)))
+	}
+	class.InitState = ClassInitializing
+	defer func() { // 父类初始化失败或者 <clinit> 抛出异常都会使该类不可用
+		if err := recover(); err != nil {
+			class.InitState = ClassErroneous
+			panic(err)
+		}
+	}()
+
+	if !IsInterface(class.Access) { // 接口初始化时不会初始化父接口
+		if class.SupperIndex > 0 {
+			InitClass(thread, thread.Loader.LoadClass(class.GetString(class.SupperIndex)))
+		}
+		initDefaultInterfaces(thread, class)
+	}
+	if clinit := class.GetMethod("<clinit>", "()V"); clinit != nil {
+		if exception := runClinit(thread, clinit); exception != nil {
+			errorClass := thread.Loader.LoadClass("java/lang/Error")
+			if instanceOf(thread, exception.Object.Class, errorClass) {
+				panic(exception)
+			} // 非 Error 的异常需要包装为 ExceptionInInitializerError
+			obj := NewInstance(thread, "java/lang/ExceptionInInitializerError", "(Ljava/lang/Throwable;)V", NewObject(exception.Object))
+			panic(&JavaException{Object: obj})
+		}
+	}
+	class.InitState = ClassInitialized
+}
+
+// 声明了 default 方法的父接口(包括间接的)需要在子类之前初始化
+func initDefaultInterfaces(thread *Thread, class *Class) {
+	for _, index := range class.Interfaces {
+		iface := thread.Loader.LoadClass(class.GetString(index))
+		for _, method := range iface.Methods {
+			if !IsStatic(method.Access) && !IsAbstract(method.Access) {
+				InitClass(thread, iface)
+				break
+			}
+		}
+		initDefaultInterfaces(thread, iface)
+	}
+}
+
+func runClinit(thread *Thread, clinit *Field) *JavaException {
+	return CatchException(thread, func() {
+		RunMethod(thread, clinit, nil)
+	})
+}
+
 func (l *Loader) LinkClass(class *Class) {
 	// TODO 校验类
+	// 计算字段类型与方法参数占用的槽位，调用时不再解析描述符
+	for _, field := range class.Fields {
+		field.Kind = FieldKind(field.GetDesc())
+	}
+	for _, method := range class.Methods {
+		method.ArgSlotCount = parseArgCount(method)
+	}
 	// 计算实例字段下标
 	l.calcuInstSlotID(class)
 	// 计算静态字段下标
 	l.calcuStaticSlotID(class)
 	// 为静态变量分配内存与初始化
 	l.initStaticFinalField(class)
+	// 构建虚方法表与接口方法表
+	l.buildVTable(class)
+	// 创建 Class 对象
+	l.createMirror(class)
+	l.buildITables(class)
+}
+
+// 先复制父类的虚方法表，自己的方法覆盖同名同描述符的项或者追加到末尾，子类与父类相同方法的下标一致
+func (l *Loader) buildVTable(class *Class) {
+	vtable := make([]*Field, 0)
+	if class.SupperClass != nil {
+		vtable = append(vtable, class.SupperClass.VTable...)
+	}
+	isInterface := IsInterface(class.Access)
+	for i, method := range class.Methods {
+		method.VTableIndex = -1
+		method.ITableIndex = -1
+		if !method.IsVirtual() {
+			continue
+		}
+		if isInterface { // 接口方法只在接口方法表中有下标
+			method.ITableIndex = i
+			continue
+		}
+		method.VTableIndex = indexOfMethod(vtable, method.GetName(), method.GetDesc())
+		if method.VTableIndex < 0 {
+			method.VTableIndex = len(vtable)
+			vtable = append(vtable, method)
+		} else {
+			vtable[method.VTableIndex] = method
+		}
+	}
+	if !isInterface { // 没有实现的接口方法也要占位，以 class 引用调用 default 方法时使用
+		conflicts := make(map[string]bool)
+		for _, iface := range class.GetAllInterfaces() {
+			for _, method := range iface.Methods {
+				key := method.GetName() + method.GetDesc()
+				if !method.IsVirtual() || conflicts[key] || indexOfMethod(vtable, method.GetName(), method.GetDesc()) >= 0 {
+					continue
+				}
+				if selected, conflict := class.SelectMethod(method); conflict {
+					conflicts[key] = true // 有冲突的不放入表中，调用时再选择并抛出异常
+				} else {
+					vtable = append(vtable, selected)
+				}
+			}
+		}
+	}
+	class.VTable = vtable
+}
+
+// 为实现的每个接口构建接口方法表，表项为该接口方法在当前类中的实现，没有实现时为 nil
+func (l *Loader) buildITables(class *Class) {
+	class.ITables = make(map[*Class][]*Field)
+	if IsInterface(class.Access) {
+		return
+	}
+	for _, iface := range class.GetAllInterfaces() {
+		itable := make([]*Field, len(iface.Methods))
+		for _, method := range iface.Methods {
+			if method.ITableIndex >= 0 {
+				itable[method.ITableIndex], _ = class.SelectMethod(method)
+			}
+		}
+		class.ITables[iface] = itable
+	}
 }
 
 func (l *Loader) initStaticFinalField(class *Class) {
-	class.StaticValues = make([]*Value, class.StaticSlotCount)
+	// 没有常量值的静态字段都是零值
+	class.StaticValues = make([]Value, class.StaticSlotCount)
 	for _, field := range class.Fields { // final 值直接存储在常量池 中
 		if IsStatic(field.Access) && IsFinal(field.Access) {
 			constantValueIndex := field.GetConstantValueAttribute()
 			if constantValueIndex == 0 {
 				continue
 			} // 对于常量值直接赋值
-			constantValue := class.Consts[constantValueIndex]
-			desc := class.GetString(field.DescIndex)
-
-			switch desc {
-			case "Z", "B", "C", "S", "I":
+			constantValue := l.checkConstantValue(class, field, constantValueIndex)
+			if constantValue == nil {
+				continue
+			}
+			switch field.Kind {
+			case KindInt:
 				class.StaticValues[field.SlotID] = NewInteger(constantValue.Integer)
-			case "J":
+			case KindLong:
 				class.StaticValues[field.SlotID] = NewLong(constantValue.Long)
-			case "F":
+			case KindFloat:
 				class.StaticValues[field.SlotID] = NewFloat(constantValue.Float)
-			case "D":
+			case KindDouble:
 				class.StaticValues[field.SlotID] = NewDouble(constantValue.Double)
-			case "Ljava/lang/String;": // 字符串常量
-				//class.StaticValues[field.SlotID] = NewString()
-			default:
-				panic(fmt.Sprintf("unknown field desc %s", desc))
+			case KindReference: // 字符串常量与 ldc 加载的是同一个对象
+				class.StaticValues[field.SlotID] = l.Strings.InternString(class.GetString(constantValue.Index))
 			}
 		}
 	}
 }
 
+// 与 hotspot 一致，常量类型与字段类型不匹配时是格式错误，记录到 FormatError 并返回 nil
+func (l *Loader) checkConstantValue(class *Class, field *Field, index uint16) *Const {
+	className := class.GetName()
+	if int(index) >= len(class.Consts) {
+		class.FormatError = fmt.Sprintf("Bad initial value index %d in ConstantValue attribute in class file %s", index, className)
+		return nil
+	}
+	constantValue := class.Consts[index]
+	var expect uint8
+	switch field.Kind {
+	case KindInt:
+		expect = ConstInteger
+	case KindLong:
+		expect = ConstLong
+	case KindFloat:
+		expect = ConstFloat
+	case KindDouble:
+		expect = ConstDouble
+	default:
+		if field.GetDesc() != "Ljava/lang/String;" || constantValue.Type != ConstString {
+			class.FormatError = "Bad string initial value in class file " + className
+			return nil
+		}
+		return constantValue
+	}
+	if constantValue.Type != expect {
+		class.FormatError = "Inconsistent constant value type in class file " + className
+		return nil
+	}
+	return constantValue
+}
+
+// 线程使用类时通过这里加载，找不到时抛出 NoClassDefFoundError
+// 类有格式错误时抛出 ClassFormatError，与 hotspot 加载失败一样每次使用都会抛出
+func ResolveClass(thread *Thread, className string) *Class {
+	if thread.Loader.Classes[className] == nil && !thread.Loader.HasClass(className) {
+		ThrowNew(thread, "java/lang/NoClassDefFoundError", className)
+	}
+	class := thread.Loader.LoadClass(className)
+	if class.FormatError != "" {
+		ThrowNew(thread, "java/lang/ClassFormatError", class.FormatError)
+	}
+	return class
+}
+
 func (l *Loader) calcuStaticSlotID(class *Class) {
 	slotID := 0
 	for _, field := range class.Fields {
@@ -119,31 +308,63 @@ func (l *Loader) DefineClass(class *Class) {
 	// 先加载父类
 	if className != "java/lang/Object" {
 		supperClass := class.GetString(class.SupperIndex)
-		l.LoadClass(supperClass)
+		class.SupperClass = l.LoadClass(supperClass)
+		if class.FormatError == "" { // 父类加载失败，子类也无法使用
+			class.FormatError = class.SupperClass.FormatError
+		}
 	}
 	// 再加载接口
+	class.InterfaceClasses = make([]*Class, 0)
 	for _, tempIndex := range class.Interfaces {
 		tempClass := class.GetString(tempIndex)
-		l.LoadClass(tempClass)
+		class.InterfaceClasses = append(class.InterfaceClasses, l.LoadClass(tempClass))
 	}
 	// 最后定义自己
 	l.Classes[className] = class
 }
 
 func (l *Loader) LoadData(class string) []byte {
+	if bs := l.FindData(class); bs != nil {
+		return bs
+	}
+	panic(fmt.Sprintf("class %s.class not found", class))
+}
+
+// 类是否已经加载或者能找到 class 文件，数组类需要元素类存在，基本类型没有 class 文件
+func (l *Loader) HasClass(className string) bool {
+	elemName := strings.TrimLeft(className, "[")
+	if elemName != className {
+		if len(elemName) == 1 {
+			return elemName != "V" && primitiveClassNames[elemName] != ""
+		}
+		if len(elemName) < 3 || elemName[0] != 'L' || elemName[len(elemName)-1] != ';' {
+			return false
+		}
+		elemName = elemName[1 : len(elemName)-1]
+	}
+	if isPrimitiveClassName(elemName) {
+		return false
+	}
+	return l.Classes[elemName] != nil || l.FindData(elemName) != nil
+}
+
+// 与 LoadData 相同，找不到时返回 nil
+func (l *Loader) FindData(class string) []byte {
 	class = class + ".class" // 转换为路径
 	for _, path := range l.Paths {
-		if strings.HasSuffix(path, ".jar") { // 两种加载方式
-			if bs := l.loadJarData(path, class); bs != nil {
-				return bs
-			}
+		var bs []byte // 三种加载方式
+		if strings.HasSuffix(path, ".jar") || strings.HasSuffix(path, ".zip") {
+			bs = l.loadJarData(path, class)
+		} else if strings.HasSuffix(path, ".jmod") {
+			bs = l.loadJmodData(path, class)
 		} else {
-			if bs := l.loadDirData(path, class); bs != nil {
-				return bs
-			}
+			bs = l.loadDirData(path, class)
+		}
+		if bs != nil {
+			return bs
 		}
 	}
-	panic(fmt.Sprintf("class %s not found", class))
+	return nil
 }
 
 func (l *Loader) loadJarData(path string, class string) []byte {
@@ -159,6 +380,23 @@ func (l *Loader) loadJarData(path string, class string) []byte {
 	return ReadAll(file) // 找到了
 }
 
+// jmod 文件是 4 字节文件头 "JM\x01\x00" 加上一个 zip，类文件都在 classes 目录下
+func (l *Loader) loadJmodData(path string, class string) []byte {
+	file, err := os.Open(path)
+	HandleErr(err)
+	defer file.Close()
+	info, err := file.Stat()
+	HandleErr(err)
+
+	reader, err := zip.NewReader(io.NewSectionReader(file, 4, info.Size()-4), info.Size()-4)
+	HandleErr(err)
+	item, err := reader.Open("classes/" + class)
+	if err != nil { // 没有找到
+		return nil
+	}
+	return ReadAll(item) // 找到了
+}
+
 func (l *Loader) loadDirData(dirPath string, class string) []byte {
 	file, err := os.Open(filepath.Join(dirPath, class))
 	if err != nil { // 没有找到
@@ -168,19 +406,145 @@ func (l *Loader) loadDirData(dirPath string, class string) []byte {
 	return ReadAll(file) // 找到了
 }
 
-func NewLoader(path string) *Loader {
+// 数组元素的类名，[I 返回 I，[Ljava/lang/String; 返回 java/lang/String，[[I 返回 [I
+func ComponentClassName(className string) string {
+	name := className[1:]
+	if name[0] == 'L' {
+		return name[1 : len(name)-1]
+	}
+	return name
+}
+
+// 数组的类名，与 class 文件中的描述符格式一致
+func ArrayClassName(className string) string {
+	if className[0] == '[' {
+		return "[" + className
+	}
+	return "[L" + className + ";"
+}
+
+func IsPrimitiveDesc(desc string) bool {
+	return len(desc) == 1
+}
+
+// 基本类型描述符对应的类名
+var primitiveClassNames = map[string]string{"Z": "boolean", "B": "byte", "C": "char", "S": "short",
+	"I": "int", "J": "long", "F": "float", "D": "double", "V": "void"}
+
+func isPrimitiveClassName(className string) bool {
+	for _, name := range primitiveClassNames {
+		if name == className {
+			return true
+		}
+	}
+	return false
+}
+
+// 数组元素的类，基本类型返回对应的基本类型类
+func (l *Loader) LoadComponentClass(className string) *Class {
+	name := ComponentClassName(className)
+	if IsPrimitiveDesc(name) {
+		name = primitiveClassNames[name]
+	}
+	return l.LoadClass(name)
+}
+
+// 创建 Class 对象，java/lang/Class 加载之前已经加载的类在它链接完成后补上
+func (l *Loader) createMirror(class *Class) {
+	classClass := l.Classes["java/lang/Class"]
+	if classClass == nil || classClass.VTable == nil { // java/lang/Class 还没有链接完成
+		return
+	}
+	mirror := Alloc(classClass, 0)
+	mirror.Extra = class
+	className := class.GetName()
+	if field := classClass.GetField("componentType", "Ljava/lang/Class;"); field != nil && className[0] == '[' { // jdk9+ 由虚拟机设置
+		mirror.Fields[field.SlotID] = NewObject(l.LoadComponentClass(className).Mirror)
+	}
+	class.Mirror = mirror
+	if className == "java/lang/Class" {
+		classes := make([]*Class, 0)
+		for _, item := range l.Classes {
+			if item.Mirror == nil && item.VTable != nil {
+				classes = append(classes, item)
+			}
+		}
+		for _, item := range classes {
+			l.createMirror(item)
+		}
+	}
+}
+
+// bootPaths 中的目录本身以及其中所有的 jar 与 jmod 都会作为搜索路径，userPaths 按原样使用
+func NewLoader(bootPaths []string, userPaths []string) *Loader {
 	paths := make([]string, 0)
 	// 先添加基本搜索路径
-	err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
-		if strings.HasSuffix(path, ".jar") {
-			paths = append(paths, path)
+	for _, bootPath := range bootPaths {
+		info, err := os.Stat(bootPath)
+		if err != nil || !info.IsDir() {
+			paths = append(paths, bootPath)
+			continue
 		}
-		return err
-	})
-	HandleErr(err)
+		paths = append(paths, bootPath)
+		err = filepath.Walk(bootPath, func(path string, info os.FileInfo, err error) error {
+			if strings.HasSuffix(path, ".jar") || strings.HasSuffix(path, ".jmod") {
+				paths = append(paths, path)
+			}
+			return err
+		})
+		HandleErr(err)
+	}
 	// 再添加用户搜索路径
-	wd, err := os.Getwd()
-	HandleErr(err)
-	paths = append(paths, wd)
+	paths = append(paths, userPaths...)
 	return &Loader{Paths: paths, Classes: make(map[string]*Class)}
 }
+
+// 读取 jar 中 META-INF/MANIFEST.MF 的 Main-Class 与 Class-Path
+func ReadJarManifest(path string) (string, []string, error) {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer reader.Close()
+
+	file, err := reader.Open("META-INF/MANIFEST.MF")
+	if err != nil {
+		return "", nil, fmt.Errorf("no main manifest attribute, in %s", path)
+	}
+	attrs := ParseManifest(string(ReadAll(file)))
+	classPath := make([]string, 0)
+	for _, item := range strings.Fields(attrs["Class-Path"]) { // 相对于 jar 所在目录
+		if !filepath.IsAbs(item) {
+			item = filepath.Join(filepath.Dir(path), item)
+		}
+		classPath = append(classPath, item)
+	}
+	return attrs["Main-Class"], classPath, nil
+}
+
+// 只解析主属性段，以空格开头的行是上一行的延续
+func ParseManifest(content string) map[string]string {
+	res := make(map[string]string)
+	lastKey := ""
+	for _, line := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
+		if line == "" {
+			if len(res) > 0 {
+				break // 主属性段结束
+			}
+			continue
+		}
+		if line[0] == ' ' {
+			if lastKey != "" {
+				res[lastKey] += line[1:]
+			}
+			continue
+		}
+		index := strings.Index(line, ":")
+		if index < 0 {
+			continue
+		}
+		lastKey = strings.TrimSpace(line[:index])
+		res[lastKey] = strings.TrimSpace(line[index+1:])
+	}
+	return res
+}
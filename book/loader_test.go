@@ -0,0 +1,74 @@
+/*
+@author: sk
+@date: 2026/10/18
+*/
+package main
+
+import "testing"
+
+// static final int BAD = "bad"; 常量类型与字段类型不一致，BadSub 继承 BadConst
+// static final String GOOD = "good"; static int same() { return GOOD == "good" ? 1 : 0; }
+// Users 中的方法分别通过 invokestatic new checkcast anewarray 使用 BadConst
+func constClasses() []*testClass {
+	bad := newTestClass("BadConst", "java/lang/Object")
+	defaultInit(bad)
+	bad.constField(AccessStatic|AccessFinal, "BAD", "I", bad.str("bad"))
+	bad.method(AccessStatic, "get", "()I", newTestCode(1, 0).op(0x04, 0xAC)) // iconst_1 ireturn
+	sub := newTestClass("BadSub", "BadConst")
+	sub.method(AccessPublic, "<init>", "()V", newTestCode(1, 1).
+		op(0x2A).op16(0xB7, sub.ref(ConstMethod, "BadConst", "<init>", "()V")).op(0xB1))
+	good := newTestClass("GoodConst", "java/lang/Object")
+	good.constField(AccessStatic|AccessFinal, "GOOD", "Ljava/lang/String;", good.str("good"))
+	good.method(AccessStatic, "same", "()I", newTestCode(2, 0).
+		op16(0xB2, good.ref(ConstField, "GoodConst", "GOOD", "Ljava/lang/String;")).op16(0x13, good.str("good")). // getstatic ldc_w
+		jump(0xA5, "same").op(0x03, 0xAC).label("same").op(0x04, 0xAC))                                           // if_acmpeq
+	users := newTestClass("Users", "java/lang/Object")
+	users.method(AccessStatic, "invoke", "()V", newTestCode(1, 0).
+		op16(0xB8, users.ref(ConstMethod, "BadConst", "get", "()I")).op(0x57, 0xB1)) // invokestatic pop return
+	users.method(AccessStatic, "newSub", "()V", newTestCode(1, 0).
+		op16(0xBB, users.class("BadSub")).op(0x57, 0xB1)) // new pop return
+	users.method(AccessStatic, "cast", "()V", newTestCode(1, 0).
+		op(0x01).op16(0xC0, users.class("BadConst")).op(0x57, 0xB1)) // aconst_null checkcast pop return
+	users.method(AccessStatic, "array", "()V", newTestCode(1, 0).
+		op(0x04).op16(0xBD, users.class("BadConst")).op(0x57, 0xB1)) // iconst_1 anewarray pop return
+	return []*testClass{bad, sub, good, users}
+}
+
+func TestClassFormatError(t *testing.T) {
+	vm := newTestVM(t, constClasses()...)
+	msg := vm.Loader.LoadClass("java/lang/Throwable").GetField("detailMessage", "Ljava/lang/String;")
+	for _, method := range []string{"invoke", "newSub", "cast", "array", "invoke"} {
+		_, exception := runStatic(vm, "Users", method, "()V")
+		if exception == nil || exception.Object.Class.GetName() != "java/lang/ClassFormatError" {
+			t.Fatalf("%s exception = %v, want ClassFormatError", method, exception)
+		}
+		if got := GoString(exception.Object.Fields[msg.SlotID].Object); got != "Inconsistent constant value type in class file BadConst" {
+			t.Errorf("%s message = %q", method, got)
+		}
+	}
+}
+
+// 字符串常量字段与 ldc 使用虚拟机的同一个常量池
+func TestConstantValueIntern(t *testing.T) {
+	vm := newTestVM(t, constClasses()...)
+	res, exception := runStatic(vm, "GoodConst", "same", "()I")
+	if exception != nil || res.Integer() != 1 {
+		t.Fatalf("same() = %d, exception %v, want 1", res.Integer(), exception)
+	}
+}
+
+// 常量池中的 4 byte utf-8 编码不是合法的 Modified UTF-8
+func TestIllegalUTF8(t *testing.T) {
+	bad := newTestClass("BadUTF8", "java/lang/Object")
+	bad.method(AccessStatic, "get", "()Ljava/lang/Object;", newTestCode(1, 0).
+		op16(0x13, bad.str("\xF0\x9F\x98\x80")).op(0xB0)) // ldc_w areturn
+	vm := newTestVM(t, bad)
+	_, exception := runStatic(vm, "BadUTF8", "get", "()Ljava/lang/Object;")
+	if exception == nil || exception.Object.Class.GetName() != "java/lang/ClassFormatError" {
+		t.Fatalf("exception = %v, want ClassFormatError", exception)
+	}
+	msg := vm.Loader.LoadClass("java/lang/Throwable").GetField("detailMessage", "Ljava/lang/String;")
+	if got := GoString(exception.Object.Fields[msg.SlotID].Object); got != "Illegal UTF8 string in constant pool in class file BadUTF8" {
+		t.Errorf("message = %q", got)
+	}
+}
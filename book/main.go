@@ -4,29 +4,238 @@
 */
 package main
 
-import "strings"
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
 
 // 对于 panic 的 OpCode 可以直接在这里搜代码
 // https://docs.oracle.com/javase/specs/jvms/se16/html/jvms-6.html
 
+const usage = `usage: book [-options] <class> [args...]
+       book [-options] -jar <jarfile> [args...]
+options:
+  -cp, -classpath <path>    用户类搜索路径，使用 ':' 分隔目录与 jar
+  --boot-classpath <path>   启动类搜索路径，默认从 JAVA_HOME 查找 rt.jar 或 jmods
+  -jar <jarfile>            从 jar 的 META-INF/MANIFEST.MF 读取 Main-Class 运行
+  -verbose:inst             打印每条执行的指令
+  -verbose:init             打印 System 初始化缺少本地方法时被忽略的异常
+  -Xss<depth>               线程的最大调用深度(栈帧数，不支持 k m g 后缀)，默认 1024，最大 1048576
+  -Xmx<size>                堆大小，可以使用 k m g 后缀，默认不限制
+  -XX:hashCode=<n>          身份哈希的生成策略，3 依次递增，5 xor-shift(默认)`
+
+type Options struct {
+	ClassPath     []string
+	BootClassPath []string
+	Jar           string
+	MainClass     string
+	Args          []string // 传递给 main 方法的参数
+	VerboseInit   bool     // 打印 System 初始化时被忽略的异常
+	HashCode      int      // 身份哈希的生成策略
+	StackDepth    int      // 最大调用深度
+	HeapSize      int64    // 堆大小，0 表示不限制
+}
+
 func main() {
-	//if len(os.Args) < 2 {
-	//	fmt.Println("usage: book <class> <args...>")
-	//	return
-	//}
-	//class := os.Args[1]
-	//args := os.Args[2:]
-	//Run(class, args...)
-	// 临时测试使用
-	Run("ExceptionTest")
-}
-
-// 默认使用当前路径作为类搜索路径
-func Run(className string, args ...string) {
-	className = strings.ReplaceAll(className, ".", "/")
-	loader := NewLoader("/Users/bytedance/Library/Java/JavaVirtualMachines/corretto-1.8.0_352/Contents/Home/jre/lib")
-	class0 := loader.LoadClass(className) // 静态方法没有调用，这里拿不到 thread
+	options, err := ParseOptions(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	os.Exit(Run(options))
+}
+
+// 与 java 命令一致，选项必须在主类之前，主类之后的都作为程序参数
+func ParseOptions(args []string) (*Options, error) {
+	options := &Options{HashCode: HashXorShift, StackDepth: DefaultStackDepth}
+	classPath := ""
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-cp" || arg == "-classpath" || arg == "--class-path":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("%s requires class path specification", arg)
+			}
+			i++
+			classPath = args[i]
+		case arg == "--boot-classpath":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("%s requires class path specification", arg)
+			}
+			i++
+			options.BootClassPath = SplitClassPath(args[i])
+		case arg == "-jar":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("%s requires jar file specification", arg)
+			}
+			options.Jar = args[i+1]
+			options.Args = args[i+2:]
+			i = len(args)
+		case arg == "-verbose:inst":
+			TraceInstruction = true
+		case arg == "-verbose:init":
+			options.VerboseInit = true
+		case strings.HasPrefix(arg, "-Xss"):
+			// 与 java 命令不同，这里是栈帧数而不是字节数，所以不支持后缀
+			depth, err := strconv.Atoi(strings.TrimPrefix(arg, "-Xss"))
+			if err != nil || depth <= 0 || depth > StackDepthLimit {
+				return nil, fmt.Errorf("invalid thread stack depth: %s, must be a frame count between 1 and %d", arg, StackDepthLimit)
+			}
+			options.StackDepth = depth
+		case strings.HasPrefix(arg, "-Xmx"):
+			size, err := ParseSize(strings.TrimPrefix(arg, "-Xmx"))
+			if err != nil {
+				return nil, fmt.Errorf("invalid maximum heap size: %s", arg)
+			}
+			if size <= HeapReserve {
+				return nil, fmt.Errorf("too small maximum heap: %s", arg)
+			}
+			options.HeapSize = size
+		case strings.HasPrefix(arg, "-XX:hashCode="):
+			hashCode, err := strconv.Atoi(strings.TrimPrefix(arg, "-XX:hashCode="))
+			if err != nil || (hashCode != HashSequential && hashCode != HashXorShift) {
+				return nil, fmt.Errorf("unsupported hashCode: %s", arg)
+			}
+			options.HashCode = hashCode
+		case strings.HasPrefix(arg, "-"):
+			return nil, fmt.Errorf("unrecognized option: %s", arg)
+		default:
+			options.MainClass = arg
+			options.Args = args[i+1:]
+			i = len(args)
+		}
+	}
+
+	if options.Jar != "" { // -jar 时忽略 -cp 与 java 行为一致
+		mainClass, classPath, err := ReadJarManifest(options.Jar)
+		if err != nil {
+			return nil, err
+		}
+		if mainClass == "" {
+			return nil, fmt.Errorf("no main manifest attribute, in %s", options.Jar)
+		}
+		options.MainClass = mainClass
+		options.ClassPath = append([]string{options.Jar}, classPath...)
+	} else {
+		if options.MainClass == "" {
+			return nil, fmt.Errorf("missing main class")
+		}
+		if classPath == "" {
+			classPath = os.Getenv("CLASSPATH")
+		}
+		if classPath == "" { // 默认使用当前路径作为类搜索路径
+			classPath = "."
+		}
+		options.ClassPath = SplitClassPath(classPath)
+	}
+
+	if len(options.BootClassPath) == 0 {
+		bootClassPath, err := FindBootClassPath(os.Getenv("JAVA_HOME"))
+		if err != nil {
+			return nil, err
+		}
+		options.BootClassPath = bootClassPath
+	}
+	return options, nil
+}
+
+// 解析 -Xmx 的大小，与 java 命令一致支持 k m g 后缀
+func ParseSize(val string) (int64, error) {
+	unit := int64(1)
+	if val != "" {
+		switch val[len(val)-1] {
+		case 'k', 'K':
+			unit = 1 << 10
+		case 'm', 'M':
+			unit = 1 << 20
+		case 'g', 'G':
+			unit = 1 << 30
+		}
+		if unit > 1 {
+			val = val[:len(val)-1]
+		}
+	}
+	size, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if size < 0 || size > math.MaxInt64/unit {
+		return 0, fmt.Errorf("size %s out of range", val)
+	}
+	return size * unit, nil
+}
+
+func SplitClassPath(path string) []string {
+	res := make([]string, 0)
+	for _, item := range strings.Split(path, string(os.PathListSeparator)) {
+		if item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
+}
+
+// 返回进程退出码 正常结束 0 未捕获异常 1 System.exit(n) 为 n
+func Run(options *Options) int {
+	vm := NewVM(options.BootClassPath, options.ClassPath)
+	vm.HashCode = options.HashCode
+	vm.MaxStackDepth = options.StackDepth
+	vm.MaxHeapSize = options.HeapSize
+	vm.VerboseInit = options.VerboseInit
 	InitInstruction()
 	InitNativeFunc()
-	RunMain(class0, loader, args)
+	return vm.Run(options.MainClass, options.Args)
+}
+
+// 运行主类的 main 方法，返回值与 Run 一致，输出写到 vm.Stdout vm.Stderr
+func (vm *VM) Run(mainClass string, args []string) (status int) {
+	className := strings.ReplaceAll(mainClass, ".", "/")
+	loader := vm.Loader
+	defer func() {
+		switch err := recover().(type) {
+		case nil:
+		case *SystemExit:
+			status = err.Status
+		case *JavaException:
+			PrintStackTrace(loader, err, vm.Stderr)
+			status = 1
+		default:
+			panic(err)
+		}
+	}()
+	// 与 java 命令的提示一致，找不到主类或者 main 方法时不抛出异常
+	if !loader.HasClass(className) {
+		fmt.Fprintf(vm.Stderr, "Error: Could not find or load main class %s\n", mainClass)
+		return 1
+	}
+	class0 := loader.LoadClass(className) // 静态方法没有调用，这里拿不到 thread
+	if method := class0.GetMethod("main", "([Ljava/lang/String;)V"); method == nil || !IsStatic(method.Access) {
+		fmt.Fprintf(vm.Stderr, "Error: Main method not found in class %s, please define the main method as:\n"+
+			"   public static void main(String[] args)\n", mainClass)
+		return 1
+	}
+	RunMain(class0, vm, args)
+	return 0
+}
+
+// 寻找启动类路径，jdk8 使用 jre/lib 下的 jar，jdk9+ 使用 jmods 下的模块
+func FindBootClassPath(javaHome string) ([]string, error) {
+	if javaHome == "" {
+		return nil, fmt.Errorf("JAVA_HOME is not set, use --boot-classpath to specify boot class path")
+	}
+	for _, dir := range []string{filepath.Join(javaHome, "jre", "lib"), filepath.Join(javaHome, "lib")} {
+		if _, err := os.Stat(filepath.Join(dir, "rt.jar")); err == nil {
+			return []string{dir}, nil
+		}
+	}
+	dir := filepath.Join(javaHome, "jmods")
+	if _, err := os.Stat(dir); err == nil {
+		return []string{dir}, nil
+	}
+	return nil, fmt.Errorf("can not find rt.jar or jmods in JAVA_HOME %s", javaHome)
 }
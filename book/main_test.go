@@ -0,0 +1,376 @@
+/*
+@author: sk
+@date: 2026/10/18
+*/
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 仓库根目录下的测试类，输出与同名的 .out 文件比较，.out 是 javac 编译后 HotSpot 运行的输出
+// 设置了 JAVA_HOME 时启动类来自 JDK，否则使用 fixtureRuntime，没有 JDK 时也会比较
+func TestFixtures(t *testing.T) {
+	bootPaths, err := FindBootClassPath(os.Getenv("JAVA_HOME"))
+	if err != nil {
+		t.Logf("%v, use test runtime", err)
+		dir := t.TempDir()
+		for _, item := range append(testRuntime(), fixtureRuntime()...) {
+			item.write(t, dir)
+		}
+		bootPaths = []string{dir}
+	}
+	InitInstruction()
+	InitNativeFunc()
+	for _, name := range []string{"ArithTest", "SwitchTest", "CloneTest"} {
+		t.Run(name, func(t *testing.T) {
+			want, err := os.ReadFile(filepath.Join("..", name+".out"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			vm := NewVM(bootPaths, []string{".."})
+			out := new(bytes.Buffer)
+			vm.Stdout, vm.Stderr = out, out
+			if status := vm.Run(name, nil); status != 0 {
+				t.Fatalf("exit status %d\n%s", status, out)
+			}
+			if out.String() != string(want) {
+				t.Errorf("output differs from %s.out\ngot:\n%s\nwant:\n%s", name, out, want)
+			}
+		})
+	}
+}
+
+// 测试类用到的其余运行时类，String 的 hashCode 与 equals 用于字符串 switch，Enum 用于枚举 switch
+func fixtureRuntime() []*testClass {
+	str := newTestClass("java/lang/String", "java/lang/Object")
+	str.access |= AccessFinal
+	str.field(AccessPrivate|AccessFinal, "value", "[C")
+	value := str.ref(ConstField, "java/lang/String", "value", "[C")
+	// int h = 0; for (int i = 0; i < value.length; i++) h = 31 * h + value[i]
+	str.method(AccessPublic, "hashCode", "()I", newTestCode(3, 4).
+		op(0x2A).op16(0xB4, value).op(0x4E, 0x03, 0x3C, 0x03, 0x3D). // aload_0 getfield astore_3 iconst_0 istore_1 iconst_0 istore_2
+		label("loop").op(0x1C, 0x2D, 0xBE).jump(0xA2, "end").        // iload_2 aload_3 arraylength if_icmpge
+		op(0x10, 31, 0x1B, 0x68, 0x2D, 0x1C, 0x34, 0x60, 0x3C).      // bipush iload_1 imul aload_3 iload_2 caload iadd istore_1
+		op(0x84, 2, 1).jump(0xA7, "loop").                           // iinc goto
+		label("end").op(0x1B, 0xAC))                                 // iload_1 ireturn
+	str.method(AccessPublic, "equals", "(Ljava/lang/Object;)Z", newTestCode(3, 5).
+		op(0x2B).op16(0xC1, str.class("java/lang/String")).jump(0x99, "false").        // aload_1 instanceof ifeq
+		op(0x2B).op16(0xC0, str.class("java/lang/String")).op16(0xB4, value).op(0x4D). // aload_1 checkcast getfield astore_2
+		op(0x2A).op16(0xB4, value).op(0x4E).                                           // aload_0 getfield astore_3
+		op(0x2C, 0xBE, 0x2D, 0xBE).jump(0xA0, "false").                                // aload_2 arraylength aload_3 arraylength if_icmpne
+		op(0x03, 0x36, 4).                                                             // iconst_0 istore
+		label("loop").op(0x15, 4, 0x2C, 0xBE).jump(0xA2, "true").                      // iload aload_2 arraylength if_icmpge
+		op(0x2C, 0x15, 4, 0x34, 0x2D, 0x15, 4, 0x34).jump(0xA0, "false").              // aload_2 iload caload aload_3 iload caload if_icmpne
+		op(0x84, 4, 1).jump(0xA7, "loop").                                             // iinc goto
+		label("true").op(0x04, 0xAC).                                                  // iconst_1 ireturn
+		label("false").op(0x03, 0xAC))                                                 // iconst_0 ireturn
+	enum := newTestClass("java/lang/Enum", "java/lang/Object")
+	enum.access |= AccessAbstract
+	enum.field(AccessPrivate|AccessFinal, "name", "Ljava/lang/String;")
+	enum.field(AccessPrivate|AccessFinal, "ordinal", "I")
+	ordinal := enum.ref(ConstField, "java/lang/Enum", "ordinal", "I")
+	enum.method(AccessProtected, "<init>", "(Ljava/lang/String;I)V", newTestCode(2, 3).
+		op(0x2A).op16(0xB7, enum.ref(ConstMethod, "java/lang/Object", "<init>", "()V")).
+		op(0x2A, 0x2B).op16(0xB5, enum.ref(ConstField, "java/lang/Enum", "name", "Ljava/lang/String;")).
+		op(0x2A, 0x1C).op16(0xB5, ordinal).op(0xB1))
+	enum.method(AccessPublic|AccessFinal, "ordinal", "()I", newTestCode(1, 1).op(0x2A).op16(0xB4, ordinal).op(0xAC))
+	return append(systemClasses("real"), str, enum)
+}
+
+// -Xss 是栈帧数，不支持 k m g 后缀，也不能超过 StackDepthLimit
+func TestParseStackDepth(t *testing.T) {
+	tests := []struct {
+		arg   string
+		depth int
+		ok    bool
+	}{
+		{"-Xss2048", 2048, true},
+		{"-Xss1048576", StackDepthLimit, true},
+		{"-Xss1048577", 0, false},
+		{"-Xss1k", 0, false},
+		{"-Xss1m", 0, false},
+		{"-Xss0", 0, false},
+		{"-Xss-1", 0, false},
+	}
+	for _, test := range tests {
+		options, err := ParseOptions([]string{"--boot-classpath", ".", test.arg, "Main"})
+		if (err == nil) != test.ok {
+			t.Errorf("ParseOptions(%s) err = %v, want ok %v", test.arg, err, test.ok)
+			continue
+		}
+		if test.ok && options.StackDepth != test.depth {
+			t.Errorf("ParseOptions(%s) depth = %d, want %d", test.arg, options.StackDepth, test.depth)
+		}
+	}
+}
+
+// 写入只包含 MANIFEST.MF 的 jar
+func writeJar(t *testing.T, path string, manifest string) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	writer := zip.NewWriter(file)
+	entry, err := writer.Create("META-INF/MANIFEST.MF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = entry.Write([]byte(manifest)); err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	t.Setenv("CLASSPATH", "")
+	dir := t.TempDir()
+	appJar, noMainJar := filepath.Join(dir, "app.jar"), filepath.Join(dir, "nomain.jar")
+	writeJar(t, appJar, "Manifest-Version: 1.0\r\nMain-Class: app.Main\r\nClass-Path: lib/a.jar /opt/b.j\r\n ar\r\n\r\n")
+	writeJar(t, noMainJar, "Manifest-Version: 1.0\n")
+	sep := string(os.PathListSeparator)
+	tests := []struct {
+		name      string
+		args      []string
+		mainClass string
+		classPath []string
+		jar       string
+		args0     []string // 传递给 main 方法的参数
+		err       string   // 不为空时解析失败，错误信息包含 err
+	}{
+		{"default class path", []string{"Main"}, "Main", []string{"."}, "", []string{}, ""},
+		{"-cp", []string{"-cp", "a" + sep + sep + "b", "Main", "x"}, "Main", []string{"a", "b"}, "", []string{"x"}, ""},
+		{"-classpath", []string{"-classpath", "a", "Main"}, "Main", []string{"a"}, "", []string{}, ""},
+		{"trailing options are args", []string{"Main", "-cp", "a", "-Xss1"}, "Main", []string{"."}, "", []string{"-cp", "a", "-Xss1"}, ""},
+		{"-jar", []string{"-cp", "ignored", "-jar", appJar, "x", "-y"}, "app.Main",
+			[]string{appJar, filepath.Join(dir, "lib/a.jar"), "/opt/b.jar"}, appJar, []string{"x", "-y"}, ""},
+		{"-jar without Main-Class", []string{"-jar", noMainJar}, "", nil, "", nil, "no main manifest attribute"},
+		{"-jar missing file", []string{"-jar", filepath.Join(dir, "missing.jar")}, "", nil, "", nil, "missing.jar"},
+		{"-cp missing argument", []string{"-cp"}, "", nil, "", nil, "-cp requires class path specification"},
+		{"--boot-classpath missing argument", []string{"--boot-classpath"}, "", nil, "", nil, "--boot-classpath requires class path specification"},
+		{"-jar missing argument", []string{"-jar"}, "", nil, "", nil, "-jar requires jar file specification"},
+		{"missing main class", []string{"-cp", "a"}, "", nil, "", nil, "missing main class"},
+		{"unrecognized option", []string{"-foo", "Main"}, "", nil, "", nil, "unrecognized option: -foo"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options, err := ParseOptions(append([]string{"--boot-classpath", "boot"}, test.args...))
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("ParseOptions(%v) err = %v, want %q", test.args, err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseOptions(%v) err = %v", test.args, err)
+			}
+			if options.MainClass != test.mainClass || options.Jar != test.jar {
+				t.Errorf("MainClass, Jar = %q %q, want %q %q", options.MainClass, options.Jar, test.mainClass, test.jar)
+			}
+			if !reflect.DeepEqual(options.ClassPath, test.classPath) || !reflect.DeepEqual(options.Args, test.args0) {
+				t.Errorf("ClassPath, Args = %q %q, want %q %q", options.ClassPath, options.Args, test.classPath, test.args0)
+			}
+			if !reflect.DeepEqual(options.BootClassPath, []string{"boot"}) {
+				t.Errorf("BootClassPath = %q, want [boot]", options.BootClassPath)
+			}
+		})
+	}
+}
+
+func TestParseManifest(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string
+	}{
+		{"crlf", "Manifest-Version: 1.0\r\nMain-Class: a.Main\r\n", map[string]string{"Manifest-Version": "1.0", "Main-Class": "a.Main"}},
+		{"continuation", "Main-Class: a.Very\n LongMain\n", map[string]string{"Main-Class": "a.VeryLongMain"}},
+		{"leading blank lines", "\n\nMain-Class: a.Main\n", map[string]string{"Main-Class": "a.Main"}},
+		{"only main section", "Main-Class: a.Main\n\nName: a/\nMain-Class: b.Main\n", map[string]string{"Main-Class": "a.Main"}},
+		{"no colon", "garbage\nMain-Class:a.Main", map[string]string{"Main-Class": "a.Main"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if res := ParseManifest(test.content); !reflect.DeepEqual(res, test.want) {
+				t.Errorf("ParseManifest(%q) = %v, want %v", test.content, res, test.want)
+			}
+		})
+	}
+}
+
+// 与 java 命令一样提示错误并返回 1，不会向上抛出 go 的 panic
+func TestRunMainErrors(t *testing.T) {
+	noMain := newTestClass("NoMain", "java/lang/Object")
+	noMain.method(AccessPublic, "main", "([Ljava/lang/String;)V", newTestCode(0, 2).op(0xB1)) // 实例方法不能作为入口
+	vm := newTestVM(t, noMain)
+	tests := []struct {
+		mainClass string
+		want      string
+	}{
+		{"DoesNotExist", "Error: Could not find or load main class DoesNotExist\n"},
+		{"no.such.Main", "Error: Could not find or load main class no.such.Main\n"},
+		{"NoMain", "Error: Main method not found in class NoMain, please define the main method as:\n" +
+			"   public static void main(String[] args)\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.mainClass, func(t *testing.T) {
+			out := new(bytes.Buffer)
+			vm.Stdout, vm.Stderr = out, out
+			if status := vm.Run(test.mainClass, nil); status != 1 {
+				t.Errorf("Run(%s) = %d, want 1", test.mainClass, status)
+			}
+			if out.String() != test.want {
+				t.Errorf("Run(%s) output = %q, want %q", test.mainClass, out, test.want)
+			}
+		})
+	}
+}
+
+// System.out 经过 FileOutputStream.writeBytes 写到 vm.Stdout，PrintStream.println 只转换 ASCII 字符
+// init 为 real 时 initializeSystemClass 正常创建 System.out，native 时 initPhase1 调用缺少的本地方法，退回简化的初始化流程
+// throw 时 initPhase1 抛出 IllegalArgumentException，不会退回
+func systemClasses(init string) []*testClass {
+	fd := newTestClass("java/io/FileDescriptor", "java/lang/Object")
+	fd.field(AccessPrivate, "fd", "I")
+	fd.field(AccessPublic|AccessStatic|AccessFinal, "out", "Ljava/io/FileDescriptor;")
+	fd.field(AccessPublic|AccessStatic|AccessFinal, "err", "Ljava/io/FileDescriptor;")
+	fd.method(AccessPublic, "<init>", "(I)V", newTestCode(2, 2).
+		op(0x2A).op16(0xB7, fd.ref(ConstMethod, "java/lang/Object", "<init>", "()V")).               // aload_0 invokespecial
+		op(0x2A, 0x1B).op16(0xB5, fd.ref(ConstField, "java/io/FileDescriptor", "fd", "I")).op(0xB1)) // aload_0 iload_1 putfield return
+	clinit := newTestCode(3, 0)
+	for i, name := range []string{"out", "err"} { // new dup iconst_x invokespecial putstatic
+		clinit.op16(0xBB, fd.class("java/io/FileDescriptor")).op(0x59, byte(0x04+i)).
+			op16(0xB7, fd.ref(ConstMethod, "java/io/FileDescriptor", "<init>", "(I)V")).
+			op16(0xB3, fd.ref(ConstField, "java/io/FileDescriptor", name, "Ljava/io/FileDescriptor;"))
+	}
+	fd.method(AccessStatic, "<clinit>", "()V", clinit.op(0xB1))
+	fos := newTestClass("java/io/FileOutputStream", "java/lang/Object")
+	fos.field(AccessPrivate|AccessFinal, "fd", "Ljava/io/FileDescriptor;")
+	fos.method(AccessPublic, "<init>", "(Ljava/io/FileDescriptor;)V", newTestCode(2, 2).
+		op(0x2A).op16(0xB7, fos.ref(ConstMethod, "java/lang/Object", "<init>", "()V")).
+		op(0x2A, 0x2B).op16(0xB5, fos.ref(ConstField, "java/io/FileOutputStream", "fd", "Ljava/io/FileDescriptor;")).op(0xB1))
+	fos.method(AccessPrivate|AccessNative, "writeBytes", "([BIIZ)V", nil)
+	// byte[] bs = new byte[s.value.length + 1]; for (i = 0; i < s.value.length; i++) bs[i] = (byte) s.value[i]; bs[i] = '\n'
+	ps := newTestClass("java/io/PrintStream", "java/lang/Object")
+	ps.field(AccessPrivate|AccessFinal, "out", "Ljava/io/OutputStream;")
+	out := ps.ref(ConstField, "java/io/PrintStream", "out", "Ljava/io/OutputStream;")
+	ps.method(AccessPublic, "<init>", "(Ljava/io/OutputStream;Z)V", newTestCode(2, 3).
+		op(0x2A).op16(0xB7, ps.ref(ConstMethod, "java/lang/Object", "<init>", "()V")).
+		op(0x2A, 0x2B).op16(0xB5, out).op(0xB1))
+	ps.method(AccessPublic, "println", "(Ljava/lang/String;)V", newTestCode(5, 5).
+		op(0x2B).op16(0xB4, ps.ref(ConstField, "java/lang/String", "value", "[C")).op(0x4D). // aload_1 getfield astore_2
+		op(0x2C, 0xBE, 0x04, 0x60, 0xBC, ArrayByte, 0x4E, 0x03, 0x36, 4).                    // aload_2 arraylength iconst_1 iadd newarray astore_3 iconst_0 istore
+		label("loop").op(0x15, 4, 0x2C, 0xBE).jump(0xA2, "end").                             // iload aload_2 arraylength if_icmpge
+		op(0x2D, 0x15, 4, 0x2C, 0x15, 4, 0x34, 0x91, 0x54).                                  // aload_3 iload aload_2 iload caload i2b bastore
+		op(0x84, 4, 1).jump(0xA7, "loop").                                                   // iinc goto
+		label("end").op(0x2D, 0x15, 4, 0x10, '\n', 0x54).                                    // aload_3 iload bipush bastore
+		op(0x2A).op16(0xB4, out).op(0x2D, 0x03, 0x2D, 0xBE, 0x03).                           // aload_0 getfield aload_3 iconst_0 aload_3 arraylength iconst_0
+		op16(0xB6, ps.ref(ConstMethod, "java/io/FileOutputStream", "writeBytes", "([BIIZ)V")).op(0xB1))
+	// byte[] bs = new byte[21]; 从后往前写入换行与各位数字，与 Long.toString 一样用负数计算避免 MIN_VALUE 溢出
+	ps.method(AccessPublic, "println", "(J)V", newTestCode(8, 6).
+		op(0x10, 21, 0xBC, ArrayByte, 0x4E, 0x10, 20, 0x36, 4).           // bipush newarray astore_3 bipush istore
+		op(0x2D, 0x10, 20, 0x10, '\n', 0x54, 0x03, 0x36, 5).              // aload_3 bipush bipush bastore iconst_0 istore
+		op(0x1F, 0x09, 0x94).jump(0x9B, "neg").                           // lload_1 lconst_0 lcmp iflt
+		op(0x1F, 0x75, 0x40).jump(0xA7, "digit").                         // lload_1 lneg lstore_1 goto
+		label("neg").op(0x04, 0x36, 5).                                   // iconst_1 istore
+		label("digit").op(0x84, 4, 0xFF, 0x2D, 0x15, 4, 0x10, '0', 0x1F). // iinc aload_3 iload bipush lload_1
+		op16(0x14, ps.long(10)).op(0x71, 0x88, 0x64, 0x91, 0x54).         // ldc2_w lrem l2i isub i2b bastore
+		op(0x1F).op16(0x14, ps.long(10)).op(0x6D, 0x40).                  // lload_1 ldc2_w ldiv lstore_1
+		op(0x1F, 0x09, 0x94).jump(0x9A, "digit").                         // lload_1 lconst_0 lcmp ifne
+		op(0x15, 5).jump(0x99, "write").                                  // iload ifeq
+		op(0x84, 4, 0xFF, 0x2D, 0x15, 4, 0x10, '-', 0x54).                // iinc aload_3 iload bipush bastore
+		label("write").op(0x2A).op16(0xB4, out).                          // aload_0 getfield
+		op(0x2D, 0x15, 4, 0x10, 21, 0x15, 4, 0x64, 0x03).                 // aload_3 iload bipush iload isub iconst_0
+		op16(0xB6, ps.ref(ConstMethod, "java/io/FileOutputStream", "writeBytes", "([BIIZ)V")).op(0xB1))
+	ps.method(AccessPublic, "println", "(I)V", newTestCode(3, 2).
+		op(0x2A, 0x1B, 0x85).op16(0xB6, ps.ref(ConstMethod, "java/io/PrintStream", "println", "(J)V")).op(0xB1)) // aload_0 iload_1 i2l invokevirtual
+	ps.method(AccessPublic, "println", "(Z)V", newTestCode(2, 2).
+		op(0x2A, 0x1B).jump(0x99, "false").op16(0x13, ps.str("true")).jump(0xA7, "print"). // aload_0 iload_1 ifeq ldc_w goto
+		label("false").op16(0x13, ps.str("false")).
+		label("print").op16(0xB6, ps.ref(ConstMethod, "java/io/PrintStream", "println", "(Ljava/lang/String;)V")).op(0xB1))
+	system := newTestClass("java/lang/System", "java/lang/Object")
+	system.field(AccessPublic|AccessStatic|AccessFinal, "out", "Ljava/io/PrintStream;")
+	system.field(AccessPublic|AccessStatic|AccessFinal, "err", "Ljava/io/PrintStream;")
+	switch init {
+	case "real": // System.out = new PrintStream(new FileOutputStream(FileDescriptor.out), true)
+		system.method(AccessPrivate|AccessStatic, "initializeSystemClass", "()V", newTestCode(5, 0).
+			op16(0xBB, system.class("java/io/PrintStream")).op(0x59).
+			op16(0xBB, system.class("java/io/FileOutputStream")).op(0x59).
+			op16(0xB2, system.ref(ConstField, "java/io/FileDescriptor", "out", "Ljava/io/FileDescriptor;")).
+			op16(0xB7, system.ref(ConstMethod, "java/io/FileOutputStream", "<init>", "(Ljava/io/FileDescriptor;)V")).op(0x04).
+			op16(0xB7, system.ref(ConstMethod, "java/io/PrintStream", "<init>", "(Ljava/io/OutputStream;Z)V")).
+			op16(0xB3, system.ref(ConstField, "java/lang/System", "out", "Ljava/io/PrintStream;")).op(0xB1))
+	case "native":
+		system.method(AccessPrivate|AccessStatic|AccessNative, "missing", "()V", nil)
+		system.method(AccessPrivate|AccessStatic, "initPhase1", "()V", newTestCode(0, 0).
+			op16(0xB8, system.ref(ConstMethod, "java/lang/System", "missing", "()V")).op(0xB1))
+	case "throw":
+		system.method(AccessPrivate|AccessStatic, "initPhase1", "()V", newTestCode(2, 0).
+			op16(0xBB, system.class("java/lang/IllegalArgumentException")).op(0x59).
+			op16(0xB7, system.ref(ConstMethod, "java/lang/IllegalArgumentException", "<init>", "()V")).op(0xBF))
+	}
+	return []*testClass{fd, fos, ps, system}
+}
+
+func helloClasses(init string) []*testClass {
+	hello := newTestClass("HelloWorld", "java/lang/Object")
+	hello.method(AccessPublic|AccessStatic, "main", "([Ljava/lang/String;)V", newTestCode(2, 1).
+		op16(0xB2, hello.ref(ConstField, "java/lang/System", "out", "Ljava/io/PrintStream;")).                  // getstatic
+		op16(0x13, hello.str("Hello World")).                                                                   // ldc_w
+		op16(0xB6, hello.ref(ConstMethod, "java/io/PrintStream", "println", "(Ljava/lang/String;)V")).op(0xB1)) // invokevirtual return
+	return append(systemClasses(init), hello)
+}
+
+func TestHelloWorld(t *testing.T) {
+	tests := []struct {
+		name     string
+		init     string
+		verbose  bool
+		status   int
+		stdout   string
+		stderr   []string // stderr 包含的内容，为空时 stderr 也为空
+		fallback bool     // 退回简化流程时才会设置 System.err
+	}{
+		{"initializeSystemClass", "real", false, 0, "Hello World\n", nil, false},
+		{"fallback on missing native", "native", false, 0, "Hello World\n", nil, true},
+		{"fallback verbose", "native", true, 0, "Hello World\n",
+			[]string{"warning: java.lang.System.initPhase1()V failed", "java.lang.UnsatisfiedLinkError"}, true},
+		{"other exception", "throw", false, 1, "", []string{"java.lang.IllegalArgumentException"}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			vm := newTestVM(t, helloClasses(test.init)...)
+			vm.VerboseInit = test.verbose
+			out, errOut := new(bytes.Buffer), new(bytes.Buffer)
+			vm.Stdout, vm.Stderr = out, errOut
+			if status := vm.Run("HelloWorld", nil); status != test.status {
+				t.Fatalf("exit status %d, want %d\n%s", status, test.status, errOut)
+			}
+			if out.String() != test.stdout {
+				t.Errorf("stdout = %q, want %q", out, test.stdout)
+			}
+			if len(test.stderr) == 0 && errOut.Len() > 0 {
+				t.Errorf("stderr = %q, want empty", errOut)
+			}
+			for _, item := range test.stderr {
+				if !strings.Contains(errOut.String(), item) {
+					t.Errorf("stderr = %q, want containing %q", errOut, item)
+				}
+			}
+			system := vm.Loader.LoadClass("java/lang/System")
+			if err := system.StaticValues[system.GetField("err", "Ljava/io/PrintStream;").SlotID].Object; (err != nil) != test.fallback {
+				t.Errorf("System.err = %v, want fallback %v", err, test.fallback)
+			}
+		})
+	}
+}
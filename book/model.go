@@ -25,6 +25,14 @@ const (
 	AccessEnum         = 0x4000 // class field
 )
 
+// 类的初始化状态 https://docs.oracle.com/javase/specs/jvms/se8/html/jvms-5.html#jvms-5.5
+const (
+	ClassUninitialized = 0 // 已链接，还没有执行 <clinit>
+	ClassInitializing  = 1 // 正在执行 <clinit>
+	ClassInitialized   = 2
+	ClassErroneous     = 3 // <clinit> 执行失败，后续使用都会抛出 NoClassDefFoundError
+)
+
 type Class struct {
 	// 静态读取出来的
 	Magic        uint32
@@ -40,10 +48,39 @@ type Class struct {
 	// 动态后来添加的
 	InstSlotCount   int
 	StaticSlotCount int
-	StaticValues    []*Value
+	StaticValues    []Value
+	InitState       uint8
+	// 定义类时加载的父类与直接实现的接口
+	SupperClass      *Class
+	InterfaceClasses []*Class
+	// 链接时构建的虚方法表与接口方法表，接口方法表以接口为键，下标为接口方法的 ITableIndex
+	VTable  []*Field
+	ITables map[*Class][]*Field
+	// 对应的 java.lang.Class 对象，每个类只有一个
+	Mirror *Object
+	// 准备阶段发现的格式错误，链接时没有线程，线程使用该类时再抛出 ClassFormatError
+	FormatError string
 }
 
-// 还没有考虑继承
+// 虚方法表中 name desc 对应的下标，不存在返回 -1
+func (c *Class) GetVTableIndex(name string, desc string) int {
+	return indexOfMethod(c.VTable, name, desc)
+}
+
+func indexOfMethod(methods []*Field, name string, desc string) int {
+	for i, method := range methods {
+		if method != nil && method.GetName() == name && method.GetDesc() == desc {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c *Class) GetName() string {
+	return c.GetString(c.ThisIndex)
+}
+
+// 只查找当前类中声明的方法
 func (c *Class) GetMethod(name string, desc string) *Field {
 	for _, method := range c.Methods {
 		if c.GetString(method.NameIndex) == name && c.GetString(method.DescIndex) == desc {
@@ -53,7 +90,7 @@ func (c *Class) GetMethod(name string, desc string) *Field {
 	return nil
 }
 
-// 还没有考虑继承
+// 只查找当前类中声明的字段
 func (c *Class) GetField(name string, desc string) *Field {
 	for _, field := range c.Fields {
 		if c.GetString(field.NameIndex) == name && c.GetString(field.DescIndex) == desc {
@@ -63,9 +100,178 @@ func (c *Class) GetField(name string, desc string) *Field {
 	return nil
 }
 
+// 字段解析 https://docs.oracle.com/javase/specs/jvms/se8/html/jvms-5.html#jvms-5.4.3.2
+// 依次查找自己，父接口，父类
+func (c *Class) LookupField(name string, desc string) *Field {
+	if field := c.GetField(name, desc); field != nil {
+		return field
+	}
+	for _, iface := range c.InterfaceClasses {
+		if field := iface.LookupField(name, desc); field != nil {
+			return field
+		}
+	}
+	if c.SupperClass != nil {
+		return c.SupperClass.LookupField(name, desc)
+	}
+	return nil
+}
+
+// 类方法解析 https://docs.oracle.com/javase/specs/jvms/se8/html/jvms-5.html#jvms-5.4.3.3
+// 依次查找自己与父类，最具体的父接口方法，任意父接口方法
+func (c *Class) LookupMethod(name string, desc string) *Field {
+	for temp := c; temp != nil; temp = temp.SupperClass {
+		if method := temp.GetMethod(name, desc); method != nil {
+			return method
+		}
+	}
+	return c.lookupSupperInterfaceMethod(name, desc)
+}
+
+// 接口方法解析 https://docs.oracle.com/javase/specs/jvms/se8/html/jvms-5.html#jvms-5.4.3.4
+// 依次查找自己，Object 中的 public 实例方法，父接口方法
+func (c *Class) LookupInterfaceMethod(name string, desc string) *Field {
+	if method := c.GetMethod(name, desc); method != nil {
+		return method
+	}
+	if object := c.SupperClass; object != nil { // 接口的父类总是 Object
+		method := object.GetMethod(name, desc)
+		if method != nil && IsPublic(method.Access) && !IsStatic(method.Access) {
+			return method
+		}
+	}
+	return c.lookupSupperInterfaceMethod(name, desc)
+}
+
+func (c *Class) lookupSupperInterfaceMethod(name string, desc string) *Field {
+	var res *Field
+	count := 0
+	for _, method := range c.MaxSpecificInterfaceMethods(name, desc) {
+		if !IsAbstract(method.Access) {
+			res = method
+			count++
+		}
+	}
+	if count == 1 { // 优先选择唯一的非抽象方法
+		return res
+	}
+	for _, iface := range c.GetAllInterfaces() {
+		method := iface.GetMethod(name, desc)
+		if method != nil && !IsPrivate(method.Access) && !IsStatic(method.Access) {
+			return method
+		}
+	}
+	return nil
+}
+
+// 方法选择 https://docs.oracle.com/javase/specs/jvms/se8/html/jvms-6.html#jvms-6.5.invokevirtual
+// 从接收者的运行时类开始查找覆盖 resolved 的方法，都没有时选择最具体的 default 方法
+// 返回 nil 表示没有实现，多个 default 方法冲突时 conflict 为 true
+func (c *Class) SelectMethod(resolved *Field) (method *Field, conflict bool) {
+	name, desc := resolved.GetName(), resolved.GetDesc()
+	for temp := c; temp != nil; temp = temp.SupperClass {
+		method = temp.GetMethod(name, desc)
+		if method != nil && !IsStatic(method.Access) && !IsPrivate(method.Access) {
+			return method, false
+		}
+	}
+	methods := make([]*Field, 0)
+	for _, item := range c.MaxSpecificInterfaceMethods(name, desc) {
+		if !IsAbstract(item.Access) {
+			methods = append(methods, item)
+		}
+	}
+	if len(methods) == 1 {
+		return methods[0], false
+	}
+	return nil, len(methods) > 1
+}
+
+// 所有父接口中声明的方法，去掉被其他候选接口继承覆盖的方法，剩下的就是最具体的
+func (c *Class) MaxSpecificInterfaceMethods(name string, desc string) []*Field {
+	candidates := make([]*Field, 0)
+	for _, iface := range c.GetAllInterfaces() {
+		method := iface.GetMethod(name, desc)
+		if method != nil && !IsPrivate(method.Access) && !IsStatic(method.Access) {
+			candidates = append(candidates, method)
+		}
+	}
+	res := make([]*Field, 0)
+	for _, method := range candidates {
+		specific := true
+		for _, other := range candidates {
+			if other != method && other.Class.IsImplements(method.Class) {
+				specific = false
+				break
+			}
+		}
+		if specific {
+			res = append(res, method)
+		}
+	}
+	return res
+}
+
+// 自己与所有父类实现的接口，包含间接继承的接口，按照声明顺序去重
+func (c *Class) GetAllInterfaces() []*Class {
+	res := make([]*Class, 0)
+	visited := make(map[*Class]bool)
+	var collect func(ifaces []*Class)
+	collect = func(ifaces []*Class) {
+		for _, iface := range ifaces {
+			if !visited[iface] {
+				visited[iface] = true
+				res = append(res, iface)
+				collect(iface.InterfaceClasses)
+			}
+		}
+	}
+	for temp := c; temp != nil; temp = temp.SupperClass {
+		collect(temp.InterfaceClasses)
+	}
+	return res
+}
+
+func (c *Class) IsSubClassOf(class *Class) bool {
+	for temp := c.SupperClass; temp != nil; temp = temp.SupperClass {
+		if temp == class {
+			return true
+		}
+	}
+	return false
+}
+
+// 自己或者父类直接或者间接实现了接口 iface，接口之间的继承也算
+func (c *Class) IsImplements(iface *Class) bool {
+	for _, item := range c.GetAllInterfaces() {
+		if item == iface {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Class) GetBootstrapMethods() []*BootstrapMethod {
+	for _, attr := range c.Attributes {
+		if attr.Name == AttributeBootstrapMethods {
+			return attr.BootstrapMethods
+		}
+	}
+	return nil
+}
+
+func (c *Class) GetSourceFile() string {
+	for _, attr := range c.Attributes {
+		if attr.Name == AttributeSourceFile {
+			return c.GetString(attr.SourceFileIndex)
+		}
+	}
+	return ""
+}
+
 func (c *Class) GetString(index uint16) string {
 	temp := c.Consts[index]
-	if temp.Type == ConstClass || temp.Type == ConstString {
+	if temp.Type == ConstClass || temp.Type == ConstString || temp.Type == ConstMethodType {
 		return c.GetString(temp.Index)
 	}
 	return temp.String
@@ -77,8 +283,25 @@ type Field struct {
 	DescIndex  uint16
 	Attributes []*Attribute
 	// 后面添加的非 class 文件中
-	Class  *Class
-	SlotID int
+	Class        *Class
+	SlotID       int
+	Kind         uint8 // 字段类型，方法不使用
+	ArgSlotCount int   // 方法参数占用的槽位，包括 this，字段不使用
+	VTableIndex  int   // 方法在虚方法表中的下标，不参与动态绑定的为 -1
+	ITableIndex  int   // 接口方法在接口方法表中的下标
+}
+
+// 是否参与动态绑定，静态方法，私有方法，构造方法都不需要
+func (f *Field) IsVirtual() bool {
+	return !IsStatic(f.Access) && !IsPrivate(f.Access) && f.GetName()[0] != '<'
+}
+
+func (f *Field) GetName() string {
+	return f.Class.GetString(f.NameIndex)
+}
+
+func (f *Field) GetDesc() string {
+	return f.Class.GetString(f.DescIndex)
 }
 
 func (f *Field) GetCodeAttribute() *Code {
@@ -100,8 +323,43 @@ func (f *Field) GetConstantValueAttribute() uint16 {
 }
 
 func (f *Field) IsTwoSlot() bool {
-	desc := f.Class.GetString(f.DescIndex)
-	return desc == "J" || desc == "D"
+	return f.Kind == KindLong || f.Kind == KindDouble
+}
+
+// 字段类型，链接时按照描述符计算一次，之后访问字段不再解析描述符
+const (
+	KindInt       = iota + 1 // boolean byte char short int 都使用 int 存储
+	KindLong                 // 占用两个槽位
+	KindFloat                // 以位模式存储
+	KindDouble               // 占用两个槽位
+	KindReference            // 对象与数组
+)
+
+func FieldKind(desc string) uint8 {
+	switch desc[0] {
+	case 'Z', 'B', 'C', 'S', 'I':
+		return KindInt
+	case 'J':
+		return KindLong
+	case 'F':
+		return KindFloat
+	case 'D':
+		return KindDouble
+	default:
+		return KindReference
+	}
+}
+
+func IsPublic(access uint16) bool {
+	return access&AccessPublic > 0
+}
+
+func IsPrivate(access uint16) bool {
+	return access&AccessPrivate > 0
+}
+
+func IsProtected(access uint16) bool {
+	return access&AccessProtected > 0
 }
 
 func IsStatic(access uint16) bool {
@@ -124,12 +382,17 @@ func IsNative(access uint16) bool {
 	return access&AccessNative > 0
 }
 
+func IsSynchronized(access uint16) bool {
+	return access&AccessSynchronized > 0
+}
+
 const (
-	AttributeCode            = "Code"
-	AttributeSourceFile      = "SourceFile"
-	AttributeExceptions      = "Exceptions"
-	AttributeLineNumberTable = "LineNumberTable"
-	AttributeConstantValue   = "ConstantValue"
+	AttributeCode             = "Code"
+	AttributeSourceFile       = "SourceFile"
+	AttributeExceptions       = "Exceptions"
+	AttributeLineNumberTable  = "LineNumberTable"
+	AttributeConstantValue    = "ConstantValue"
+	AttributeBootstrapMethods = "BootstrapMethods"
 )
 
 type Attribute struct {
@@ -140,6 +403,13 @@ type Attribute struct {
 	ExceptionIndexes   []uint16
 	LineNumbers        []*LineNumber
 	ConstantValueIndex uint16
+	BootstrapMethods   []*BootstrapMethod
+}
+
+// invokedynamic 使用的启动方法
+type BootstrapMethod struct {
+	MethodRef uint16   // ConstMethodHandle
+	Args      []uint16 // 静态参数，都是常量池下标
 }
 
 type Code struct { // 解析出来最好不要是裸信息，还是尽可能转换为其包装信息为好
@@ -150,12 +420,17 @@ type Code struct { // 解析出来最好不要是裸信息，还是尽可能转
 	Attributes []*Attribute
 }
 
-func (c *Code) FindException(class *Class, pc uint16, obj *Object) *Exception {
+// https://docs.oracle.com/javase/specs/jvms/se8/html/jvms-4.html#jvms-4.7.3 按顺序匹配第一个可以处理的
+func (c *Code) FindException(thread *Thread, class *Class, pc uint16, obj *Object) *Exception {
 	for _, item := range c.Exceptions {
-		if item.Start > pc || item.End < pc { // 处于范围内
+		if pc < item.Start || pc >= item.End { // 处于 [start_pc, end_pc) 范围内
 			continue
-		} // 这里需要判定处理的类型兼容 允许继承 这里简单判断必须相等
-		if obj.Class.GetString(obj.Class.ThisIndex) == class.GetString(item.CatchType) {
+		}
+		if item.CatchType == 0 { // catch all，用于 finally 与 synchronized
+			return item
+		} // 捕获类型可以是抛出类型的父类
+		catchClass := thread.Loader.LoadClass(class.GetString(item.CatchType))
+		if instanceOf(thread, obj.Class, catchClass) {
 			return item
 		}
 	}
@@ -212,6 +487,19 @@ const (
 	ConstInvokeDynamic   = 18
 )
 
+// ConstMethodHandle 的引用类型
+const (
+	RefGetField         = 1
+	RefGetStatic        = 2
+	RefPutField         = 3
+	RefPutStatic        = 4
+	RefInvokeVirtual    = 5
+	RefInvokeStatic     = 6
+	RefInvokeSpecial    = 7
+	RefNewInvokeSpecial = 8
+	RefInvokeInterface  = 9
+)
+
 type Const struct {
 	Type uint8
 	// ConstClass, ConstString
@@ -226,10 +514,18 @@ type Const struct {
 	Long int64
 	// ConstDouble
 	Double float64
-	// ConstField, ConstMethod, ConstInterfaceMethod
+	// ConstField, ConstMethod, ConstInterfaceMethod, ConstInvokeDynamic(ClassIndex 为启动方法下标)
 	ClassIndex    uint16
 	NameTypeIndex uint16
 	// ConstNameType
 	NameIndex uint16
 	DescIndex uint16
+	// ConstMethodHandle
+	RefKind  uint8
+	RefIndex uint16
+	// 运行时解析 ConstMethod ConstInterfaceMethod 后缓存的结果
+	Method      *Field
+	MethodIndex int // 接口方法为 itable 中的下标，其他为 vtable 中的下标，-1 表示不需要动态绑定
+	// 运行时解析 ConstInvokeDynamic 后生成的 lambda 类
+	CallSite *Class
 }
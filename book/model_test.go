@@ -0,0 +1,67 @@
+/*
+@author: sk
+@date: 2026/10/18
+*/
+package main
+
+import "testing"
+
+// 异常表 [Start, End) 为 [2, 5)，检查范围边界、catch all 与子类匹配
+func TestFindException(t *testing.T) {
+	class := newTestClass("Handlers", "java/lang/Object")
+	catchTypes := map[string]uint16{"": 0}
+	for _, name := range []string{"java/lang/RuntimeException", "java/lang/ArithmeticException", "java/lang/Throwable"} {
+		catchTypes[name] = class.class(name)
+	}
+	vm := newTestVM(t, class)
+	handlers := vm.Loader.LoadClass("Handlers")
+	thread := NewThread(vm)
+	tests := []struct {
+		name      string
+		catchType string
+		pc        uint16
+		thrown    string
+		match     bool
+	}{
+		{"before start", "", 1, "java/lang/RuntimeException", false},
+		{"at start", "", 2, "java/lang/RuntimeException", true},
+		{"before end", "", 4, "java/lang/RuntimeException", true},
+		{"at end", "", 5, "java/lang/RuntimeException", false},
+		{"catch all error", "", 3, "java/lang/Error", true},
+		{"same class", "java/lang/RuntimeException", 3, "java/lang/RuntimeException", true},
+		{"subclass", "java/lang/RuntimeException", 3, "java/lang/IllegalArgumentException", true},
+		{"indirect subclass", "java/lang/Throwable", 3, "java/lang/ArrayIndexOutOfBoundsException", true},
+		{"superclass", "java/lang/ArithmeticException", 3, "java/lang/RuntimeException", false},
+		{"sibling", "java/lang/ArithmeticException", 3, "java/lang/NullPointerException", false},
+		{"subclass at end", "java/lang/RuntimeException", 5, "java/lang/IllegalArgumentException", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			item := &Exception{Start: 2, End: 5, Handler: 9, CatchType: catchTypes[test.catchType]}
+			code := &Code{Exceptions: []*Exception{item}}
+			obj := Alloc(vm.Loader.LoadClass(test.thrown), 0)
+			if res := code.FindException(thread, handlers, test.pc, obj); (res != nil) != test.match {
+				t.Errorf("FindException(pc %d, %s) = %v, want match %v", test.pc, test.thrown, res, test.match)
+			}
+		})
+	}
+}
+
+// 多个处理代码覆盖同一位置时按照异常表中的顺序选择第一个匹配的
+func TestFindExceptionOrder(t *testing.T) {
+	class := newTestClass("Handlers", "java/lang/Object")
+	arithmetic := class.class("java/lang/ArithmeticException")
+	vm := newTestVM(t, class)
+	code := &Code{Exceptions: []*Exception{
+		{Start: 0, End: 10, Handler: 20, CatchType: arithmetic},
+		{Start: 0, End: 10, Handler: 30, CatchType: 0},
+	}}
+	thread := NewThread(vm)
+	handlers := vm.Loader.LoadClass("Handlers")
+	for thrown, handler := range map[string]uint16{"java/lang/ArithmeticException": 20, "java/lang/Error": 30} {
+		res := code.FindException(thread, handlers, 5, Alloc(vm.Loader.LoadClass(thrown), 0))
+		if res == nil || res.Handler != handler {
+			t.Errorf("FindException(%s) = %v, want handler %d", thrown, res, handler)
+		}
+	}
+}
@@ -6,7 +6,12 @@ package main
 
 import (
 	"fmt"
+	"math"
 	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type NativeFunc func(thread *Thread)
@@ -15,12 +20,16 @@ var (
 	nativeFuncs = make(map[string]NativeFunc)
 )
 
+func nativeKey(class string, name string, desc string) string {
+	return fmt.Sprintf("%s-%s-%s", class, name, desc)
+}
+
 func RegisterNativeFunc(class string, name string, desc string, func0 NativeFunc) {
-	nativeFuncs[fmt.Sprintf("%s-%s-%s", class, name, desc)] = func0
+	nativeFuncs[nativeKey(class, name, desc)] = func0
 }
 
 func GetNativeFunc(class string, name string, desc string) NativeFunc {
-	return nativeFuncs[fmt.Sprintf("%s-%s-%s", class, name, desc)]
+	return nativeFuncs[nativeKey(class, name, desc)]
 }
 
 func InitNativeFunc() {
@@ -28,17 +37,548 @@ func InitNativeFunc() {
 		frame := thread.Peek()
 		val1 := frame.Pop()
 		val2 := frame.Pop()
-		frame.Push(NewInteger(max(val1.Integer, val2.Integer)))
+		frame.Push(NewInteger(max(val1.Integer(), val2.Integer())))
 	})
 	RegisterNativeFunc("java/lang/Object", "getClass", "()Ljava/lang/Class;", func(thread *Thread) {
 		frame := thread.Peek()
-		class := frame.Pop().Object.Class
-		name := class.GetString(class.ThisIndex)
-		frame.Push(NewObject(makeClassObject(thread, name)))
+		frame.Push(NewObject(frame.Pop().Object.Class.Mirror))
 	})
 	RegisterNativeFunc("java/lang/Object", "hashCode", "()I", func(thread *Thread) {
 		frame := thread.Peek()
 		obj := frame.Pop().Object
-		frame.Push(NewInteger(int32(reflect.ValueOf(obj).Pointer())))
+		frame.Push(NewInteger(identityHashCode(thread, obj)))
+	})
+	RegisterNativeFunc("java/lang/Object", "clone", "()Ljava/lang/Object;", func(thread *Thread) {
+		frame := thread.Peek()
+		obj := frame.Pop().Object
+		frame.Push(NewObject(cloneObject(thread, obj)))
+	})
+	// 只有一个线程，不会有其他线程来唤醒，wait 按照虚假唤醒处理直接返回
+	RegisterNativeFunc("java/lang/Object", "notify", "()V", func(thread *Thread) {
+		checkMonitorOwner(thread, thread.Peek().Pop().Object)
+	})
+	RegisterNativeFunc("java/lang/Object", "notifyAll", "()V", func(thread *Thread) {
+		checkMonitorOwner(thread, thread.Peek().Pop().Object)
+	})
+	RegisterNativeFunc("java/lang/Object", "wait", "(J)V", func(thread *Thread) {
+		frame := thread.Peek()
+		timeout := frame.Pop2().Long()
+		obj := frame.Pop().Object
+		if timeout < 0 {
+			ThrowNew(thread, "java/lang/IllegalArgumentException", "timeout value is negative")
+		}
+		checkMonitorOwner(thread, obj)
+	})
+	// 执行 <clinit> 时会调用到的本地方法
+	for _, class := range []string{"java/lang/Object", "java/lang/System", "java/lang/Class", "java/lang/Thread"} {
+		RegisterNativeFunc(class, "registerNatives", "()V", func(thread *Thread) {})
+	}
+	for _, class := range []string{"sun/misc/VM", "jdk/internal/misc/VM"} {
+		RegisterNativeFunc(class, "initialize", "()V", func(thread *Thread) {})
+	}
+	RegisterNativeFunc("java/lang/System", "arraycopy", "(Ljava/lang/Object;ILjava/lang/Object;II)V", arraycopy)
+	RegisterNativeFunc("java/lang/System", "currentTimeMillis", "()J", func(thread *Thread) {
+		thread.Peek().Push2(NewLong(time.Now().UnixMilli()))
+	})
+	RegisterNativeFunc("java/lang/System", "nanoTime", "()J", func(thread *Thread) {
+		thread.Peek().Push2(NewLong(time.Since(startTime).Nanoseconds()))
+	})
+	RegisterNativeFunc("java/lang/System", "identityHashCode", "(Ljava/lang/Object;)I", func(thread *Thread) {
+		frame := thread.Peek()
+		obj := frame.Pop().Object
+		if obj == nil {
+			frame.Push(NewInteger(0))
+		} else {
+			frame.Push(NewInteger(identityHashCode(thread, obj)))
+		}
+	})
+	// jdk8 System.initializeSystemClass 中调用，通过 Properties.setProperty 逐个设置
+	RegisterNativeFunc("java/lang/System", "initProperties", "(Ljava/util/Properties;)Ljava/util/Properties;", func(thread *Thread) {
+		frame := thread.Peek()
+		props := frame.Pop().Object
+		initProperties(thread, props)
+		frame.Push(NewObject(props))
+	})
+	RegisterNativeFunc("java/lang/Class", "getPrimitiveClass", "(Ljava/lang/String;)Ljava/lang/Class;", func(thread *Thread) {
+		frame := thread.Peek()
+		name := GoString(frame.Pop().Object)
+		if !isPrimitiveClassName(name) {
+			ThrowNew(thread, "java/lang/ClassNotFoundException", name)
+		}
+		frame.Push(NewObject(thread.Loader.LoadClass(name).Mirror))
+	})
+	// jdk8 使用 getName0，jdk9+ 使用 initClassName 并由虚拟机缓存到 name 字段
+	RegisterNativeFunc("java/lang/Class", "getName0", "()Ljava/lang/String;", func(thread *Thread) {
+		frame := thread.Peek()
+		class := mirrorClass(frame.Pop().Object)
+		frame.Push(thread.VM.Strings.InternString(toJavaName(class.GetName())))
+	})
+	RegisterNativeFunc("java/lang/Class", "initClassName", "()Ljava/lang/String;", func(thread *Thread) {
+		frame := thread.Peek()
+		mirror := frame.Pop().Object
+		name := thread.VM.Strings.InternString(toJavaName(mirrorClass(mirror).GetName()))
+		mirror.Fields[mirror.Class.GetField("name", "Ljava/lang/String;").SlotID] = name
+		frame.Push(name)
+	})
+	RegisterNativeFunc("java/lang/Class", "getSuperclass", "()Ljava/lang/Class;", func(thread *Thread) {
+		frame := thread.Peek()
+		class := mirrorClass(frame.Pop().Object)
+		if class.SupperClass == nil || IsInterface(class.Access) { // Object 接口 基本类型都返回 null
+			frame.Push(NewNull())
+		} else {
+			frame.Push(NewObject(class.SupperClass.Mirror))
+		}
+	})
+	RegisterNativeFunc("java/lang/Class", "isInterface", "()Z", func(thread *Thread) {
+		frame := thread.Peek()
+		frame.Push(NewBoolean(IsInterface(mirrorClass(frame.Pop().Object).Access)))
+	})
+	RegisterNativeFunc("java/lang/Class", "isArray", "()Z", func(thread *Thread) {
+		frame := thread.Peek()
+		frame.Push(NewBoolean(mirrorClass(frame.Pop().Object).GetName()[0] == '['))
+	})
+	RegisterNativeFunc("java/lang/Class", "isPrimitive", "()Z", func(thread *Thread) {
+		frame := thread.Peek()
+		frame.Push(NewBoolean(isPrimitiveClassName(mirrorClass(frame.Pop().Object).GetName())))
+	})
+	RegisterNativeFunc("java/lang/Class", "getComponentType", "()Ljava/lang/Class;", func(thread *Thread) {
+		frame := thread.Peek()
+		class := mirrorClass(frame.Pop().Object)
+		if class.GetName()[0] != '[' {
+			frame.Push(NewNull())
+		} else {
+			frame.Push(NewObject(thread.Loader.LoadComponentClass(class.GetName()).Mirror))
+		}
+	})
+	// 基本类型只能赋值给自己
+	RegisterNativeFunc("java/lang/Class", "isAssignableFrom", "(Ljava/lang/Class;)Z", func(thread *Thread) {
+		frame := thread.Peek()
+		other := frame.Pop().Object
+		class := mirrorClass(frame.Pop().Object)
+		if other == nil {
+			ThrowNew(thread, "java/lang/NullPointerException", "")
+		}
+		otherClass := mirrorClass(other)
+		if isPrimitiveClassName(class.GetName()) || isPrimitiveClassName(otherClass.GetName()) {
+			frame.Push(NewBoolean(class == otherClass))
+		} else {
+			frame.Push(NewBoolean(instanceOf(thread, otherClass, class)))
+		}
+	})
+	RegisterNativeFunc("java/lang/Class", "isInstance", "(Ljava/lang/Object;)Z", func(thread *Thread) {
+		frame := thread.Peek()
+		obj := frame.Pop().Object
+		class := mirrorClass(frame.Pop().Object)
+		frame.Push(NewBoolean(obj != nil && instanceOf(thread, obj.Class, class)))
+	})
+	RegisterNativeFunc("java/lang/Class", "forName0", "(Ljava/lang/String;ZLjava/lang/ClassLoader;Ljava/lang/Class;)Ljava/lang/Class;", func(thread *Thread) {
+		frame := thread.Peek()
+		frame.Pop() // caller 与 loader 只用于权限检查与选择类加载器，这里只有一个类加载器
+		frame.Pop()
+		initialize := frame.Pop().Integer() != 0
+		name := frame.Pop().Object
+		if name == nil {
+			ThrowNew(thread, "java/lang/NullPointerException", "")
+		}
+		class := forName(thread, GoString(name))
+		if initialize {
+			InitClass(thread, class)
+		}
+		frame.Push(NewObject(class.Mirror))
+	})
+	// jdk8 使用本地方法，jdk9+ 直接读取 classLoader 字段，所有类都由启动类加载器加载
+	RegisterNativeFunc("java/lang/Class", "getClassLoader0", "()Ljava/lang/ClassLoader;", func(thread *Thread) {
+		frame := thread.Peek()
+		frame.Pop()
+		frame.Push(NewNull())
+	})
+	// 与 hotspot 一样跳过调用 getCallerClass 的方法(必须是 @CallerSensitive)与反射调用的栈帧
+	for _, class := range []string{"sun/reflect/Reflection", "jdk/internal/reflect/Reflection"} {
+		RegisterNativeFunc(class, "getCallerClass", "()Ljava/lang/Class;", func(thread *Thread) {
+			frame := thread.Peek()
+			for i := thread.Stack.Index - 2; i >= 0; i-- {
+				method := thread.Stack.Data[i].Method
+				if method.Class.GetName() == "java/lang/reflect/Method" && method.GetName() == "invoke" {
+					continue
+				}
+				frame.Push(NewObject(method.Class.Mirror))
+				return
+			}
+			frame.Push(NewNull())
+		})
+	}
+	// jdk8 AccessController.doPrivileged 是本地方法，没有实现权限检查直接调用 run
+	// PrivilegedExceptionAction 抛出的受检异常包装为 PrivilegedActionException
+	for _, action := range []string{"java/security/PrivilegedAction", "java/security/PrivilegedExceptionAction"} {
+		checked := action == "java/security/PrivilegedExceptionAction"
+		for _, context := range []string{"", "Ljava/security/AccessControlContext;"} {
+			hasContext := context != ""
+			RegisterNativeFunc("java/security/AccessController", "doPrivileged", "(L"+action+";"+context+")Ljava/lang/Object;", func(thread *Thread) {
+				if hasContext {
+					thread.Peek().Pop()
+				}
+				doPrivileged(thread, action, checked)
+			})
+		}
+	}
+	RegisterNativeFunc("java/security/AccessController", "getStackAccessControlContext", "()Ljava/security/AccessControlContext;", func(thread *Thread) {
+		thread.Peek().Push(NewNull()) // null 表示只有系统代码，拥有全部权限
+	})
+	// jdk8 使用 sun/misc/Unsafe，jdk9+ 使用 jdk/internal/misc/Unsafe 并且本地方法名以 0 结尾
+	// 没有实现内存访问，偏移量与 objectSize 的估算一致
+	for _, item := range [][2]string{{"sun/misc/Unsafe", ""}, {"jdk/internal/misc/Unsafe", "0"}} {
+		class, suffix := item[0], item[1]
+		RegisterNativeFunc(class, "registerNatives", "()V", func(thread *Thread) {})
+		RegisterNativeFunc(class, "arrayBaseOffset"+suffix, "(Ljava/lang/Class;)I", func(thread *Thread) {
+			frame := thread.Peek()
+			checkArrayClass(thread, frame.Pop().Object)
+			frame.Pop()
+			frame.Push(NewInteger(16))
+		})
+		RegisterNativeFunc(class, "arrayIndexScale"+suffix, "(Ljava/lang/Class;)I", func(thread *Thread) {
+			frame := thread.Peek()
+			class := checkArrayClass(thread, frame.Pop().Object)
+			frame.Pop()
+			frame.Push(NewInteger(int32(arrayElemSize(class.GetName()))))
+		})
+		RegisterNativeFunc(class, "addressSize"+suffix, "()I", func(thread *Thread) {
+			frame := thread.Peek()
+			frame.Pop()
+			frame.Push(NewInteger(8))
+		})
+	}
+	RegisterNativeFunc("java/lang/Float", "floatToRawIntBits", "(F)I", func(thread *Thread) {
+		frame := thread.Peek()
+		frame.Push(NewInteger(int32(math.Float32bits(frame.Pop().Float()))))
+	})
+	RegisterNativeFunc("java/lang/Float", "intBitsToFloat", "(I)F", func(thread *Thread) {
+		frame := thread.Peek()
+		frame.Push(NewFloat(math.Float32frombits(uint32(frame.Pop().Integer()))))
+	})
+	RegisterNativeFunc("java/lang/Double", "doubleToRawLongBits", "(D)J", func(thread *Thread) {
+		frame := thread.Peek()
+		frame.Push2(NewLong(int64(math.Float64bits(frame.Pop2().Double()))))
+	})
+	RegisterNativeFunc("java/lang/Double", "longBitsToDouble", "(J)D", func(thread *Thread) {
+		frame := thread.Peek()
+		frame.Push2(NewDouble(math.Float64frombits(uint64(frame.Pop2().Long()))))
+	})
+	RegisterNativeFunc("java/lang/Thread", "currentThread", "()Ljava/lang/Thread;", func(thread *Thread) {
+		thread.Peek().Push(NewObject(currentThreadObject(thread)))
+	})
+	RegisterNativeFunc("java/lang/Thread", "setPriority0", "(I)V", func(thread *Thread) {
+		frame := thread.Peek()
+		frame.Pop()
+		frame.Pop()
 	})
+	RegisterNativeFunc("java/lang/Runtime", "availableProcessors", "()I", func(thread *Thread) {
+		frame := thread.Peek()
+		frame.Pop()
+		frame.Push(NewInteger(int32(runtime.NumCPU())))
+	})
+	for _, class := range []string{"java/io/FileDescriptor", "java/io/FileInputStream", "java/io/FileOutputStream"} {
+		RegisterNativeFunc(class, "initIDs", "()V", func(thread *Thread) {})
+	}
+	// jdk9+ FileDescriptor 构造方法中调用，handle 只在 windows 上使用
+	RegisterNativeFunc("java/io/FileDescriptor", "getHandle", "(I)J", func(thread *Thread) {
+		frame := thread.Peek()
+		frame.Pop()
+		frame.Push2(NewLong(-1))
+	})
+	RegisterNativeFunc("java/io/FileDescriptor", "getAppend", "(I)Z", func(thread *Thread) {
+		frame := thread.Peek()
+		frame.Pop()
+		frame.Push(NewInteger(0))
+	})
+	RegisterNativeFunc("java/io/FileOutputStream", "writeBytes", "([BIIZ)V", func(thread *Thread) {
+		frame := thread.Peek()
+		frame.Pop() // append 只对文件有效
+		length := frame.Pop().Integer()
+		offset := frame.Pop().Integer()
+		bytes := frame.Pop().Object
+		this := frame.Pop().Object
+		if bytes == nil {
+			ThrowNew(thread, "java/lang/NullPointerException", "")
+		}
+		data := bytes.ArrayData.([]int8)
+		if offset < 0 || length < 0 || int(offset)+int(length) > len(data) {
+			ThrowNew(thread, "java/lang/IndexOutOfBoundsException", "")
+		}
+		fosClass := thread.Loader.LoadClass("java/io/FileOutputStream")
+		fdObj := this.Fields[fosClass.GetField("fd", "Ljava/io/FileDescriptor;").SlotID].Object
+		fd := fdObj.Fields[fdObj.Class.GetField("fd", "I").SlotID].Integer()
+		writer := thread.VM.fdWriter(fd)
+		if writer == nil {
+			ThrowNew(thread, "java/io/IOException", "Stream Closed")
+		}
+		bs := make([]byte, length)
+		for i := range bs {
+			bs[i] = byte(data[int(offset)+i])
+		}
+		if _, err := writer.Write(bs); err != nil {
+			ThrowNew(thread, "java/io/IOException", err.Error())
+		}
+	})
+	// System.setOut System.setErr System.setIn 修改的是 final 静态字段只能通过本地方法
+	for _, item := range [][]string{{"setIn0", "in", "Ljava/io/InputStream;"}, {"setOut0", "out", "Ljava/io/PrintStream;"}, {"setErr0", "err", "Ljava/io/PrintStream;"}} {
+		name, desc := item[1], item[2]
+		RegisterNativeFunc("java/lang/System", item[0], "("+desc+")V", func(thread *Thread) {
+			system := thread.Loader.LoadClass("java/lang/System")
+			system.StaticValues[system.GetField(name, desc).SlotID] = thread.Peek().Pop()
+		})
+	}
+	RegisterNativeFunc("java/lang/String", "intern", "()Ljava/lang/String;", func(thread *Thread) {
+		frame := thread.Peek()
+		str := frame.Pop().Object
+		frame.Push(NewObject(thread.VM.Strings.Intern(str)))
+	})
+	RegisterNativeFunc("java/lang/StringUTF16", "isBigEndian", "()Z", func(thread *Thread) {
+		thread.Peek().Push(NewInteger(0)) // 与 encodeStringBytes 一致使用小端序
+	})
+	RegisterNativeFunc("java/lang/Class", "desiredAssertionStatus0", "(Ljava/lang/Class;)Z", func(thread *Thread) {
+		frame := thread.Peek()
+		frame.Pop()
+		frame.Push(NewInteger(0))
+	})
+	RegisterNativeFunc("java/lang/Throwable", "fillInStackTrace", "(I)Ljava/lang/Throwable;", func(thread *Thread) {
+		frame := thread.Peek()
+		frame.Pop()
+		fillInStackTrace(thread, frame.Peek().Object) // 返回 this
+	})
+	// jdk8 Throwable.getOurStackTrace 逐个获取
+	RegisterNativeFunc("java/lang/Throwable", "getStackTraceDepth", "()I", func(thread *Thread) {
+		frame := thread.Peek()
+		frame.Push(NewInteger(int32(len(getBacktrace(frame.Pop().Object)))))
+	})
+	RegisterNativeFunc("java/lang/Throwable", "getStackTraceElement", "(I)Ljava/lang/StackTraceElement;", func(thread *Thread) {
+		frame := thread.Peek()
+		index := frame.Pop().Integer()
+		backtrace := getBacktrace(frame.Pop().Object)
+		if index < 0 || int(index) >= len(backtrace) {
+			ThrowNew(thread, "java/lang/IndexOutOfBoundsException", strconv.Itoa(int(index)))
+		}
+		class := thread.Loader.LoadClass("java/lang/StackTraceElement")
+		InitClass(thread, class)
+		element := HeapAlloc(thread, class, 0)
+		initStackTraceElement(thread, element, backtrace[index])
+		frame.Push(NewObject(element))
+	})
+	// jdk9+ StackTraceElement.of 创建好数组后填充，jdk19 开始传递的是 backtrace 字段，这里 backtrace 就是 Throwable 自己
+	initStackTraceElements := func(thread *Thread, elements *Object, backtrace *Object) {
+		for i, item := range getBacktrace(backtrace) {
+			initStackTraceElement(thread, elements.ArrayData.([]*Object)[i], item)
+		}
+	}
+	RegisterNativeFunc("java/lang/StackTraceElement", "initStackTraceElements", "([Ljava/lang/StackTraceElement;Ljava/lang/Throwable;)V", func(thread *Thread) {
+		frame := thread.Peek()
+		throwable := frame.Pop().Object
+		initStackTraceElements(thread, frame.Pop().Object, throwable)
+	})
+	RegisterNativeFunc("java/lang/StackTraceElement", "initStackTraceElements", "([Ljava/lang/StackTraceElement;Ljava/lang/Object;I)V", func(thread *Thread) {
+		frame := thread.Peek()
+		frame.Pop() // depth 与记录的调用栈长度一致
+		backtrace := frame.Pop().Object
+		initStackTraceElements(thread, frame.Pop().Object, backtrace)
+	})
+	RegisterNativeFunc("java/lang/Shutdown", "halt0", "(I)V", func(thread *Thread) {
+		frame := thread.Peek()
+		panic(&SystemExit{Status: int(frame.Pop().Integer())})
+	})
+}
+
+var (
+	startTime = time.Now() // nanoTime 的起点，只用于计算时间差
+)
+
+// 第一次获取时生成并保存在对象中，之后不再变化，与对象地址无关
+func identityHashCode(thread *Thread, obj *Object) int32 {
+	if obj.Hash == 0 {
+		obj.Hash = thread.VM.nextHash(thread)
+	}
+	return obj.Hash
+}
+
+// 记录创建异常时的调用栈，与 hotspot 一致跳过 fillInStackTrace 与异常类自己的构造方法
+// 调用栈保存在 Extra 中，backtrace 字段指向自己只用于标识已经记录，jdk9+ 还需要设置 depth
+func fillInStackTrace(thread *Thread, throwable *Object) {
+	trace := thread.StackTrace()
+	i := 0
+	for i < len(trace) && trace[i].Method.GetName() == "fillInStackTrace" {
+		i++
+	}
+	for i < len(trace) && trace[i].Method.GetName() == "<init>" && instanceOf(thread, throwable.Class, trace[i].Method.Class) {
+		i++
+	}
+	trace = trace[i:]
+	if len(trace) > MaxStackTrace {
+		trace = trace[:MaxStackTrace]
+	}
+	throwable.Extra = trace
+	class := thread.Loader.LoadClass("java/lang/Throwable")
+	if field := class.GetField("backtrace", "Ljava/lang/Object;"); field != nil {
+		throwable.Fields[field.SlotID] = NewObject(throwable)
+	}
+	if field := class.GetField("depth", "I"); field != nil {
+		throwable.Fields[field.SlotID] = NewInteger(int32(len(trace)))
+	}
+}
+
+func getBacktrace(throwable *Object) []*StackElement {
+	backtrace, _ := throwable.Extra.([]*StackElement)
+	return backtrace
+}
+
+// 直接设置字段，不调用构造方法，jdk9+ 的 declaringClassObject 用于 computeFormat 计算类加载器与模块
+func initStackTraceElement(thread *Thread, element *Object, item *StackElement) {
+	class := thread.Loader.LoadClass("java/lang/StackTraceElement")
+	method := item.Method
+	element.Fields[class.GetField("declaringClass", "Ljava/lang/String;").SlotID] = thread.VM.Strings.InternString(toJavaName(method.Class.GetName()))
+	element.Fields[class.GetField("methodName", "Ljava/lang/String;").SlotID] = thread.VM.Strings.InternString(method.GetName())
+	if source := method.Class.GetSourceFile(); source != "" {
+		element.Fields[class.GetField("fileName", "Ljava/lang/String;").SlotID] = thread.VM.Strings.InternString(source)
+	}
+	element.Fields[class.GetField("lineNumber", "I").SlotID] = NewInteger(item.LineNumber())
+	if field := class.GetField("declaringClassObject", "Ljava/lang/Class;"); field != nil {
+		element.Fields[field.SlotID] = NewObject(method.Class.Mirror)
+	}
+}
+
+// 通过 Properties.setProperty 逐个设置 VM.Properties 中的系统属性
+func initProperties(thread *Thread, props *Object) {
+	frame := thread.Peek()
+	setProperty := props.Class.LookupMethod("setProperty", "(Ljava/lang/String;Ljava/lang/String;)Ljava/lang/Object;")
+	for _, item := range thread.VM.Properties() {
+		frame.Push(NewObject(props))
+		frame.Push(NewString(thread.Loader, item[0]))
+		frame.Push(NewString(thread.Loader, item[1]))
+		CallMethod(thread, setProperty)
+		frame.Pop() // 丢弃返回的旧值
+	}
+}
+
+// 加载 java 类名对应的类，找不到时抛出 ClassNotFoundException
+func forName(thread *Thread, javaName string) *Class {
+	className := strings.ReplaceAll(javaName, ".", "/")
+	if strings.Contains(javaName, "/") || !thread.Loader.HasClass(className) {
+		ThrowNew(thread, "java/lang/ClassNotFoundException", javaName)
+	}
+	return ResolveClass(thread, className)
+}
+
+// 调用 action.run()，返回值留在当前栈帧中
+func doPrivileged(thread *Thread, action string, checked bool) {
+	obj := checkNotNull(thread, thread.Peek().Peek())
+	run := thread.Loader.LoadClass(action).GetMethod("run", "()Ljava/lang/Object;")
+	run = selectMethod(thread, obj.Class, run)
+	if !checked {
+		CallMethod(thread, run)
+		return
+	}
+	exception := CatchException(thread, func() {
+		CallMethod(thread, run)
+	})
+	if exception == nil {
+		return
+	}
+	loader := thread.Loader
+	if !instanceOf(thread, exception.Object.Class, loader.LoadClass("java/lang/Exception")) ||
+		instanceOf(thread, exception.Object.Class, loader.LoadClass("java/lang/RuntimeException")) {
+		panic(exception)
+	}
+	obj = NewInstance(thread, "java/security/PrivilegedActionException", "(Ljava/lang/Exception;)V", NewObject(exception.Object))
+	panic(NewJavaException(thread, obj))
+}
+
+// Unsafe 获取数组布局的参数必须是数组类
+func checkArrayClass(thread *Thread, mirror *Object) *Class {
+	if mirror == nil {
+		ThrowNew(thread, "java/lang/NullPointerException", "")
+	}
+	class := mirrorClass(mirror)
+	if class.GetName()[0] != '[' {
+		ThrowNew(thread, "java/lang/IllegalArgumentException", "not an array class: "+toJavaName(class.GetName()))
+	}
+	return class
+}
+
+// wait notify notifyAll 要求当前线程持有对象的锁
+func checkMonitorOwner(thread *Thread, obj *Object) {
+	if !obj.IsOwner(thread) {
+		ThrowNew(thread, "java/lang/IllegalMonitorStateException", "current thread is not owner")
+	}
+}
+
+// 浅拷贝，数组复制元素，对象复制字段
+func cloneObject(thread *Thread, obj *Object) *Object {
+	cloneable := thread.Loader.LoadClass("java/lang/Cloneable")
+	if !instanceOf(thread, obj.Class, cloneable) {
+		ThrowNew(thread, "java/lang/CloneNotSupportedException", toJavaName(obj.Class.GetName()))
+	}
+	if obj.ArrayData != nil {
+		res := HeapAlloc(thread, obj.Class, obj.ArrayLength())
+		reflect.Copy(reflect.ValueOf(res.ArrayData), reflect.ValueOf(obj.ArrayData))
+		return res
+	}
+	res := HeapAlloc(thread, obj.Class, 0)
+	copy(res.Fields, obj.Fields)
+	return res
+}
+
+// https://docs.oracle.com/javase/8/docs/api/java/lang/System.html#arraycopy-java.lang.Object-int-java.lang.Object-int-int-
+func arraycopy(thread *Thread) {
+	frame := thread.Peek()
+	length := int(frame.Pop().Integer())
+	destPos := int(frame.Pop().Integer())
+	dest := frame.Pop().Object
+	srcPos := int(frame.Pop().Integer())
+	src := frame.Pop().Object
+	if src == nil || dest == nil {
+		ThrowNew(thread, "java/lang/NullPointerException", "")
+	}
+	srcName, destName := src.Class.GetName(), dest.Class.GetName()
+	if srcName[0] != '[' {
+		ThrowNew(thread, "java/lang/ArrayStoreException", "arraycopy: source type "+toJavaName(srcName)+" is not an array")
+	}
+	if destName[0] != '[' {
+		ThrowNew(thread, "java/lang/ArrayStoreException", "arraycopy: destination type "+toJavaName(destName)+" is not an array")
+	}
+	srcComponent, destComponent := ComponentClassName(srcName), ComponentClassName(destName)
+	if (IsPrimitiveDesc(srcComponent) || IsPrimitiveDesc(destComponent)) && srcComponent != destComponent {
+		ThrowNew(thread, "java/lang/ArrayStoreException", "arraycopy: type mismatch: can not copy "+srcName+" into "+destName)
+	}
+	if srcPos < 0 || destPos < 0 || length < 0 || srcPos+length > src.ArrayLength() || destPos+length > dest.ArrayLength() {
+		ThrowNew(thread, "java/lang/ArrayIndexOutOfBoundsException", "arraycopy: last index out of bounds")
+	}
+	if IsPrimitiveDesc(srcComponent) || instanceOf(thread, src.Class, dest.Class) { // 元素类型兼容整段复制，reflect.Copy 可以处理重叠
+		reflect.Copy(reflect.ValueOf(dest.ArrayData).Slice(destPos, destPos+length), reflect.ValueOf(src.ArrayData).Slice(srcPos, srcPos+length))
+		return
+	}
+	// 逐个检查元素类型，遇到不兼容的元素时前面已经复制的保留
+	destClass := thread.Loader.LoadClass(destComponent)
+	srcData, destData := src.ArrayData.([]*Object), dest.ArrayData.([]*Object)
+	for i := 0; i < length; i++ {
+		item := srcData[srcPos+i]
+		if item != nil && !instanceOf(thread, item.Class, destClass) {
+			ThrowNew(thread, "java/lang/ArrayStoreException", "arraycopy: element type mismatch")
+		}
+		destData[destPos+i] = item
+	}
+}
+
+// 与 hotspot 创建主线程的过程一致，先创建 system 与 main 线程组，再调用 Thread(ThreadGroup, String) 构造方法
+// 构造方法中会调用 currentThread 获取父线程，所以需要先设置 ThreadObj
+func currentThreadObject(thread *Thread) *Object {
+	if thread.ThreadObj == nil {
+		system := NewInstance(thread, "java/lang/ThreadGroup", "()V")
+		group := NewInstance(thread, "java/lang/ThreadGroup", "(Ljava/lang/ThreadGroup;Ljava/lang/String;)V",
+			NewObject(system), NewString(thread.Loader, "main"))
+		class := thread.Loader.LoadClass("java/lang/Thread")
+		InitClass(thread, class)
+		obj := HeapAlloc(thread, class, 0)
+		obj.Fields[class.GetField("priority", "I").SlotID] = NewInteger(5) // Thread.NORM_PRIORITY
+		thread.ThreadObj = obj
+		RunMethod(thread, class.GetMethod("<init>", "(Ljava/lang/ThreadGroup;Ljava/lang/String;)V"),
+			[]Value{NewObject(obj), NewObject(group), NewString(thread.Loader, "main")})
+	}
+	return thread.ThreadObj
 }
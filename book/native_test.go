@@ -0,0 +1,316 @@
+/*
+@author: sk
+@date: 2026/10/18
+*/
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Act 同时实现 PrivilegedAction 与 PrivilegedExceptionAction
+// public Object run() { if (kind == 0) return "ok"; if (kind == 1) throw new Exception(); throw new IllegalArgumentException(); }
+// static Object plain(int kind) { return AccessController.doPrivileged((PrivilegedAction) new Act(kind)); } checked context 类似
+func privilegedClasses() []*testClass {
+	controller := newTestClass("java/security/AccessController", "java/lang/Object")
+	exception := newTestClass("java/security/PrivilegedActionException", "java/lang/Exception")
+	exception.method(AccessPublic, "<init>", "(Ljava/lang/Exception;)V", newTestCode(1, 2).op(0x2A).
+		op16(0xB7, exception.ref(ConstMethod, "java/lang/Exception", "<init>", "()V")).op(0xB1))
+	act := newTestClass("Act", "java/lang/Object", "java/security/PrivilegedAction", "java/security/PrivilegedExceptionAction")
+	act.field(AccessPrivate, "kind", "I")
+	kind := act.ref(ConstField, "Act", "kind", "I")
+	act.method(AccessPublic, "<init>", "(I)V", newTestCode(2, 2).
+		op(0x2A).op16(0xB7, act.ref(ConstMethod, "java/lang/Object", "<init>", "()V")). // aload_0 invokespecial
+		op(0x2A, 0x1B).op16(0xB5, kind).op(0xB1))                                       // aload_0 iload_1 putfield return
+	newThrow := func(code *testCode, name string) *testCode {
+		code.op16(0xBB, act.class(name)).op(0x59) // new dup
+		return code.op16(0xB7, act.ref(ConstMethod, name, "<init>", "()V")).op(0xBF)
+	}
+	// aload_0 getfield ifne ldc_w areturn，aload_0 getfield iconst_1 if_icmpne
+	run := newTestCode(2, 1).op(0x2A).op16(0xB4, kind).jump(0x9A, "checked").op16(0x13, act.str("ok")).op(0xB0).
+		label("checked").op(0x2A).op16(0xB4, kind).op(0x04).jump(0xA0, "unchecked")
+	newThrow(run, "java/lang/Exception").label("unchecked")
+	act.method(AccessPublic, "run", "()Ljava/lang/Object;", newThrow(run, "java/lang/IllegalArgumentException"))
+	for _, item := range [][3]string{{"plain", "PrivilegedAction", ""}, {"checked", "PrivilegedExceptionAction", ""},
+		{"context", "PrivilegedAction", "Ljava/security/AccessControlContext;"}} {
+		desc := "(Ljava/security/" + item[1] + ";" + item[2] + ")Ljava/lang/Object;"
+		controller.method(AccessPublic|AccessStatic|AccessNative, "doPrivileged", desc, nil)
+		// new dup iload_0 invokespecial
+		code := newTestCode(4, 1).op16(0xBB, act.class("Act")).op(0x59, 0x1A).op16(0xB7, act.ref(ConstMethod, "Act", "<init>", "(I)V"))
+		if item[2] != "" {
+			code.op(0x01) // aconst_null
+		}
+		code.op16(0xB8, act.ref(ConstMethod, "java/security/AccessController", "doPrivileged", desc)).op(0xB0)
+		act.method(AccessStatic, item[0], "(I)Ljava/lang/Object;", code)
+	}
+	res := []*testClass{controller, exception, act}
+	for _, name := range []string{"java/security/PrivilegedAction", "java/security/PrivilegedExceptionAction"} {
+		res = append(res, interfaceClass(name, "run", "()Ljava/lang/Object;"))
+	}
+	return res
+}
+
+func TestDoPrivileged(t *testing.T) {
+	vm := newTestVM(t, privilegedClasses()...)
+	tests := []struct {
+		name      string
+		method    string
+		kind      int32
+		exception string
+	}{
+		{"return", "plain", 0, ""},
+		{"return with context", "context", 0, ""},
+		{"exception action return", "checked", 0, ""},
+		{"unchecked exception", "plain", 2, "java/lang/IllegalArgumentException"},
+		{"checked exception wrapped", "checked", 1, "java/security/PrivilegedActionException"},
+		{"runtime exception not wrapped", "checked", 2, "java/lang/IllegalArgumentException"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, exception := runStatic(vm, "Act", test.method, "(I)Ljava/lang/Object;", NewInteger(test.kind))
+			if test.exception != "" {
+				if exception == nil || exception.Object.Class.GetName() != test.exception {
+					t.Fatalf("%s(%d) exception = %v, want %s", test.method, test.kind, exception, test.exception)
+				}
+				return
+			}
+			if exception != nil {
+				t.Fatalf("%s(%d) uncaught %s", test.method, test.kind, exception.Object.Class.GetName())
+			}
+			if res.Object == nil || GoString(res.Object) != "ok" {
+				t.Errorf("%s(%d) = %v, want ok", test.method, test.kind, res.Object)
+			}
+		})
+	}
+}
+
+// static Object callee() { return Reflection.getCallerClass(); } static Object call() { return Callee.callee(); }
+// static Object find(String name) { return Class.forName0(name, true, null, null); }
+// static int scale(Object cls) { return new Unsafe().arrayIndexScale(cls); }
+func reflectionClasses() []*testClass {
+	reflection := newTestClass("sun/reflect/Reflection", "java/lang/Object")
+	reflection.method(AccessPublic|AccessStatic|AccessNative, "getCallerClass", "()Ljava/lang/Class;", nil)
+	callee := newTestClass("Callee", "java/lang/Object")
+	callee.method(AccessStatic, "callee", "()Ljava/lang/Object;", newTestCode(1, 0).
+		op16(0xB8, callee.ref(ConstMethod, "sun/reflect/Reflection", "getCallerClass", "()Ljava/lang/Class;")).op(0xB0))
+	caller := newTestClass("Caller", "java/lang/Object")
+	caller.method(AccessStatic, "call", "()Ljava/lang/Object;", newTestCode(1, 0).
+		op16(0xB8, caller.ref(ConstMethod, "Callee", "callee", "()Ljava/lang/Object;")).op(0xB0))
+	forName0 := "(Ljava/lang/String;ZLjava/lang/ClassLoader;Ljava/lang/Class;)Ljava/lang/Class;"
+	class := newTestClass("java/lang/Class", "java/lang/Object")
+	class.access |= AccessFinal
+	class.method(AccessPrivate|AccessStatic|AccessNative, "forName0", forName0, nil)
+	caller.method(AccessStatic, "find", "(Ljava/lang/String;)Ljava/lang/Object;", newTestCode(4, 1).
+		op(0x2A, 0x04, 0x01, 0x01).op16(0xB8, caller.ref(ConstMethod, "java/lang/Class", "forName0", forName0)).op(0xB0))
+	unsafe := newTestClass("sun/misc/Unsafe", "java/lang/Object")
+	defaultInit(unsafe)
+	unsafe.method(AccessPublic|AccessNative, "arrayIndexScale", "(Ljava/lang/Class;)I", nil)
+	caller.method(AccessStatic, "scale", "(Ljava/lang/Object;)I", newTestCode(3, 1).
+		op16(0xBB, caller.class("sun/misc/Unsafe")).op(0x59).                             // new dup
+		op16(0xB7, caller.ref(ConstMethod, "sun/misc/Unsafe", "<init>", "()V")).op(0x2A). // invokespecial aload_0
+		op16(0xB6, caller.ref(ConstMethod, "sun/misc/Unsafe", "arrayIndexScale", "(Ljava/lang/Class;)I")).op(0xAC))
+	return []*testClass{reflection, callee, caller, class, unsafe}
+}
+
+func TestGetCallerClass(t *testing.T) {
+	vm := newTestVM(t, reflectionClasses()...)
+	res, exception := runStatic(vm, "Caller", "call", "()Ljava/lang/Object;")
+	if exception != nil {
+		t.Fatalf("call uncaught %s", exception.Object.Class.GetName())
+	}
+	if want := vm.Loader.LoadClass("Caller").Mirror; res.Object != want {
+		t.Errorf("getCallerClass() = %v, want Caller", res.Object)
+	}
+}
+
+func TestForName(t *testing.T) {
+	vm := newTestVM(t, reflectionClasses()...)
+	tests := []struct {
+		name  string
+		class string // 为空时抛出 ClassNotFoundException
+	}{
+		{"Callee", "Callee"},
+		{"java.lang.String", "java/lang/String"},
+		{"[LCallee;", "[LCallee;"},
+		{"[[I", "[[I"},
+		{"NoSuchClass", ""},
+		{"[LNoSuchClass;", ""},
+		{"java/lang/String", ""},
+		{"int", ""},
+		{"[V", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, exception := runStatic(vm, "Caller", "find", "(Ljava/lang/String;)Ljava/lang/Object;", NewString(vm.Loader, test.name))
+			if test.class == "" {
+				if exception == nil || exception.Object.Class.GetName() != "java/lang/ClassNotFoundException" {
+					t.Fatalf("forName(%s) exception = %v, want ClassNotFoundException", test.name, exception)
+				}
+				return
+			}
+			if exception != nil {
+				t.Fatalf("forName(%s) uncaught %s", test.name, exception.Object.Class.GetName())
+			}
+			if want := vm.Loader.LoadClass(test.class).Mirror; res.Object != want {
+				t.Errorf("forName(%s) = %v, want %s", test.name, res.Object, test.class)
+			}
+		})
+	}
+}
+
+func TestArrayIndexScale(t *testing.T) {
+	vm := newTestVM(t, reflectionClasses()...)
+	for class, want := range map[string]int32{"[Z": 1, "[C": 2, "[I": 4, "[J": 8, "[Ljava/lang/Object;": 8} {
+		res, exception := runStatic(vm, "Caller", "scale", "(Ljava/lang/Object;)I", NewObject(vm.Loader.LoadClass(class).Mirror))
+		if exception != nil || res.Integer() != want {
+			t.Errorf("arrayIndexScale(%s) = %d, exception %v, want %d", class, res.Integer(), exception, want)
+		}
+	}
+	_, exception := runStatic(vm, "Caller", "scale", "(Ljava/lang/Object;)I", NewObject(vm.Loader.LoadClass("Callee").Mirror))
+	if exception == nil || exception.Object.Class.GetName() != "java/lang/IllegalArgumentException" {
+		t.Errorf("arrayIndexScale(Callee) exception = %v, want IllegalArgumentException", exception)
+	}
+}
+
+// Point 实现 Cloneable，Plain 没有实现，两者的 copy 都是 super.clone()
+// Cloner 与 Point 没有继承关系，直接调用 Point 对象受保护的 clone 时抛出 IllegalAccessError，数组的 clone 视为 public
+func cloneClasses() []*testClass {
+	point := newTestClass("Point", "java/lang/Object", "java/lang/Cloneable")
+	point.field(0, "x", "I")
+	point.field(0, "next", "LPoint;")
+	plain := newTestClass("Plain", "java/lang/Object")
+	for _, class := range []*testClass{point, plain} {
+		class.method(AccessPublic, "copy", "()Ljava/lang/Object;", newTestCode(1, 1).
+			op(0x2A).op16(0xB7, class.ref(ConstMethod, "java/lang/Object", "clone", "()Ljava/lang/Object;")).op(0xB0)) // aload_0 invokespecial areturn
+	}
+	cloner := newTestClass("Cloner", "java/lang/Object")
+	cloner.method(AccessStatic, "copy", "(LPoint;)Ljava/lang/Object;", newTestCode(1, 1).
+		op(0x2A).op16(0xB6, cloner.ref(ConstMethod, "Point", "copy", "()Ljava/lang/Object;")).op(0xB0))
+	cloner.method(AccessStatic, "copyPlain", "(LPlain;)Ljava/lang/Object;", newTestCode(1, 1).
+		op(0x2A).op16(0xB6, cloner.ref(ConstMethod, "Plain", "copy", "()Ljava/lang/Object;")).op(0xB0))
+	cloner.method(AccessStatic, "copyArray", "([I)[I", newTestCode(1, 1).
+		op(0x2A).op16(0xB6, cloner.ref(ConstMethod, "[I", "clone", "()Ljava/lang/Object;")). // aload_0 invokevirtual
+		op16(0xC0, cloner.class("[I")).op(0xB0))                                             // checkcast areturn
+	cloner.method(AccessStatic, "steal", "(LPoint;)Ljava/lang/Object;", newTestCode(1, 1).
+		op(0x2A).op16(0xB6, cloner.ref(ConstMethod, "java/lang/Object", "clone", "()Ljava/lang/Object;")).op(0xB0))
+	cloner.method(AccessStatic, "hash", "(Ljava/lang/Object;)I", newTestCode(1, 1).
+		op(0x2A).op16(0xB6, cloner.ref(ConstMethod, "java/lang/Object", "hashCode", "()I")).op(0xAC))
+	return []*testClass{point, plain, cloner}
+}
+
+func TestClone(t *testing.T) {
+	vm := newTestVM(t, cloneClasses()...)
+	point := vm.Loader.LoadClass("Point")
+	x, next := point.GetField("x", "I").SlotID, point.GetField("next", "LPoint;").SlotID
+	obj := Alloc(point, 0)
+	obj.Fields[x] = NewInteger(42)
+	obj.Fields[next] = NewObject(Alloc(point, 0))
+	obj.Monitor = &Monitor{}
+	if res, _ := runStatic(vm, "Cloner", "hash", "(Ljava/lang/Object;)I", NewObject(obj)); res.Integer() != 1 {
+		t.Fatalf("hashCode = %d, want 1", res.Integer())
+	}
+	res, exception := runStatic(vm, "Cloner", "copy", "(LPoint;)Ljava/lang/Object;", NewObject(obj))
+	if exception != nil {
+		t.Fatalf("uncaught %s", exception.Object.Class.GetName())
+	}
+	clone := res.Object
+	if clone == obj || clone.Class != point {
+		t.Fatalf("clone = %v, want a new Point", clone)
+	}
+	// 浅拷贝，引用字段指向同一个对象
+	if clone.Fields[x] != obj.Fields[x] || clone.Fields[next].Object != obj.Fields[next].Object {
+		t.Errorf("clone fields = %v, want %v", clone.Fields, obj.Fields)
+	}
+	// 对象头不复制，身份哈希重新生成
+	if clone.Hash != 0 || clone.Monitor != nil {
+		t.Errorf("clone header = %+v, want fresh", clone.Header)
+	}
+	if res, _ := runStatic(vm, "Cloner", "hash", "(Ljava/lang/Object;)I", NewObject(clone)); res.Integer() != 2 {
+		t.Errorf("clone hashCode = %d, want 2", res.Integer())
+	}
+
+	arr := Alloc(vm.Loader.LoadClass("[I"), 3)
+	copy(arr.ArrayData.([]int32), []int32{1, 2, 3})
+	res, exception = runStatic(vm, "Cloner", "copyArray", "([I)[I", NewObject(arr))
+	if exception != nil {
+		t.Fatalf("array clone uncaught %s", exception.Object.Class.GetName())
+	}
+	if res.Object == arr || !reflect.DeepEqual(res.Object.ArrayData, arr.ArrayData) {
+		t.Errorf("array clone = %v, want a copy of %v", res.Object.ArrayData, arr.ArrayData)
+	}
+	res.Object.ArrayData.([]int32)[0] = 9
+	if arr.ArrayData.([]int32)[0] != 1 {
+		t.Error("array clone shares elements with the original")
+	}
+
+	tests := []struct {
+		method    string
+		desc      string
+		arg       *Object
+		exception string
+	}{
+		{"copyPlain", "(LPlain;)Ljava/lang/Object;", Alloc(vm.Loader.LoadClass("Plain"), 0), "java/lang/CloneNotSupportedException"},
+		{"steal", "(LPoint;)Ljava/lang/Object;", obj, "java/lang/IllegalAccessError"},
+	}
+	for _, test := range tests {
+		_, exception := runStatic(vm, "Cloner", test.method, test.desc, NewObject(test.arg))
+		if exception == nil || exception.Object.Class.GetName() != test.exception {
+			t.Errorf("%s exception = %v, want %s", test.method, exception, test.exception)
+		}
+	}
+}
+
+// static int identity(Object o) { return System.identityHashCode(o); } static int hash(Object o) { return o.hashCode(); }
+func hashClasses() []*testClass {
+	system := newTestClass("java/lang/System", "java/lang/Object")
+	system.method(AccessPublic|AccessStatic|AccessNative, "identityHashCode", "(Ljava/lang/Object;)I", nil)
+	hasher := newTestClass("Hasher", "java/lang/Object")
+	hasher.method(AccessStatic, "identity", "(Ljava/lang/Object;)I", newTestCode(1, 1).
+		op(0x2A).op16(0xB8, hasher.ref(ConstMethod, "java/lang/System", "identityHashCode", "(Ljava/lang/Object;)I")).op(0xAC))
+	hasher.method(AccessStatic, "hash", "(Ljava/lang/Object;)I", newTestCode(1, 1).
+		op(0x2A).op16(0xB6, hasher.ref(ConstMethod, "java/lang/Object", "hashCode", "()I")).op(0xAC))
+	return []*testClass{system, hasher}
+}
+
+func TestIdentityHashCode(t *testing.T) {
+	vm := newTestVM(t, hashClasses()...)
+	object := vm.Loader.LoadClass("java/lang/Object")
+	hash := func(method string, obj *Object) int32 {
+		res, exception := runStatic(vm, "Hasher", method, "(Ljava/lang/Object;)I", NewObject(obj))
+		if exception != nil {
+			t.Fatalf("%s uncaught %s", method, exception.Object.Class.GetName())
+		}
+		return res.Integer()
+	}
+	// HashSequential 按照第一次获取的顺序依次递增，之后不再变化
+	objs := []*Object{Alloc(object, 0), Alloc(object, 0), Alloc(object, 0)}
+	for i, obj := range objs {
+		for _, method := range []string{"identity", "hash", "identity", "hash"} {
+			if res := hash(method, obj); res != int32(i+1) {
+				t.Errorf("%s(objs[%d]) = %d, want %d", method, i, res, i+1)
+			}
+		}
+	}
+	if res := hash("identity", nil); res != 0 {
+		t.Errorf("identity(null) = %d, want 0", res)
+	}
+
+	vm.HashCode = HashXorShift
+	obj := Alloc(object, 0)
+	first := hash("hash", obj)
+	if first <= 0 || hash("identity", obj) != first || hash("hash", obj) != first {
+		t.Errorf("xor-shift hash = %d, want the same positive value every time", first)
+	}
+	thread := NewThread(vm)
+	for i := 0; i < 10000; i++ {
+		if res := vm.nextHash(thread); res <= 0 {
+			t.Fatalf("nextHash = %d, want positive", res)
+		}
+	}
+	// 全 0 的状态生成 0，需要替换为其他值
+	thread.HashState = [4]uint32{}
+	if res := vm.nextHash(thread); res == 0 {
+		t.Error("nextHash returned 0 for the zero state")
+	}
+}
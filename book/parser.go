@@ -13,6 +13,8 @@ import (
 type Parser struct {
 	Data  []byte
 	Index int
+	// 解析过程中发现的格式错误，记录到 Class.FormatError，使用该类时抛出 ClassFormatError
+	FormatError string
 }
 
 func (p *Parser) ParseClass() *Class {
@@ -23,6 +25,9 @@ func (p *Parser) ParseClass() *Class {
 	class.Consts = p.ParseConsts()
 	class.Access = p.ReadU16()
 	class.ThisIndex = p.ReadU16()
+	if p.FormatError != "" {
+		class.FormatError = p.FormatError + " in class file " + class.GetString(class.ThisIndex)
+	}
 	class.SupperIndex = p.ReadU16()
 	class.Interfaces = p.ReadU16s()
 	class.Fields = p.ParseFields(class)
@@ -67,7 +72,11 @@ func (p *Parser) ParseConsts() []*Const {
 		switch item.Type {
 		case ConstUtf8:
 			l := p.ReadU16()
-			item.String = string(p.ReadBytes(int(l)))
+			units, ok := DecodeMUTF8(p.ReadBytes(int(l)))
+			if !ok && p.FormatError == "" {
+				p.FormatError = "Illegal UTF8 string in constant pool"
+			}
+			item.String = UTF16ToString(units)
 		case ConstInteger: // bool byte char 也都是这个
 			item.Integer = int32(p.ReadU32())
 		case ConstFloat:
@@ -85,8 +94,8 @@ func (p *Parser) ParseConsts() []*Const {
 			item.NameIndex = p.ReadU16()
 			item.DescIndex = p.ReadU16()
 		case ConstMethodHandle:
-			p.ReadU8()
-			p.ReadU16()
+			item.RefKind = p.ReadU8()
+			item.RefIndex = p.ReadU16()
 		default:
 			panic(fmt.Errorf("unknown constant type: %v", item.Type))
 		}
@@ -143,6 +152,8 @@ func (p *Parser) ParseAttributes(consts []*Const) []*Attribute {
 			attr.LineNumbers = temp.ParseLineNumbers()
 		case AttributeConstantValue:
 			attr.ConstantValueIndex = temp.ReadU16()
+		case AttributeBootstrapMethods:
+			attr.BootstrapMethods = temp.ParseBootstrapMethods()
 		default:
 			//fmt.Println("unknown attribute:", attr.Name)
 			attr.Data = temp.ReadAll()
@@ -182,6 +193,18 @@ func (p *Parser) ReadAll() []byte {
 	return p.Data[index:]
 }
 
+func (p *Parser) ParseBootstrapMethods() []*BootstrapMethod {
+	count := p.ReadU16()
+	res := make([]*BootstrapMethod, 0)
+	for i := 0; i < int(count); i++ {
+		res = append(res, &BootstrapMethod{
+			MethodRef: p.ReadU16(),
+			Args:      p.ReadU16s(),
+		})
+	}
+	return res
+}
+
 func (p *Parser) ParseLineNumbers() []*LineNumber {
 	count := p.ReadU16()
 	res := make([]*LineNumber, 0)
@@ -6,44 +6,33 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"math"
+	"os"
 )
 
-const (
-	ValueNull    = 1
-	ValueDouble  = 2
-	ValueFloat   = 3
-	ValueInteger = 4
-	ValueLong    = 5
-	ValueObject  = 6
-)
+// 操作数栈、局部变量表、字段都使用 Value 作为一个槽位，按值传递不会出现多处共享同一个可变对象的问题
+// int float long double 以位模式存储在 Num 中，引用存储在 Object 中，全零即为各类型的默认值(0 0.0 null)
+// double long 占用两个槽位，值存储在第一个槽位，第二个槽位固定为零值
+type Value struct {
+	Num    uint64
+	Object *Object
+}
 
-// double long 占用两个其他包含指针等都是占用一个 会采用第二个留空实际信息都存储在第一个的方式来占用两个
-type Value struct { // 通用值类型
-	Type    uint8
-	Double  float64
-	Float   float32
-	Integer int32
-	Long    int64
-	Object  *Object
-}
-
-func (v *Value) String() string {
-	switch v.Type {
-	case ValueDouble:
-		return fmt.Sprintf("%f", v.Double)
-	case ValueFloat:
-		return fmt.Sprintf("%f", v.Float)
-	case ValueInteger:
-		return fmt.Sprintf("%d", v.Integer)
-	case ValueLong:
-		return fmt.Sprintf("%d", v.Long)
-	case ValueObject:
-		return v.Object.String()
-	case ValueNull:
-		return "null"
-	default:
-		panic(fmt.Sprintf("unknown type %d", v.Type))
-	}
+func (v Value) Integer() int32 {
+	return int32(v.Num)
+}
+
+func (v Value) Long() int64 {
+	return int64(v.Num)
+}
+
+func (v Value) Float() float32 {
+	return math.Float32frombits(uint32(v.Num))
+}
+
+func (v Value) Double() float64 {
+	return math.Float64frombits(v.Num)
 }
 
 const (
@@ -57,104 +46,249 @@ const (
 	ArrayLong    = 11
 )
 
+// 对象头，锁、身份哈希等与对象内容无关的状态都放在这里
+type Header struct {
+	Class   *Class
+	Hash    int32    // 身份哈希，0 表示还没有生成
+	Monitor *Monitor // 第一次加锁时创建
+}
+
+// 可重入锁，只有一个线程所以不会有竞争
+type Monitor struct {
+	Owner *Thread
+	Count int
+}
+
+// 只记录持有者与重入次数
+func (h *Header) Enter(thread *Thread) {
+	if h.Monitor == nil {
+		h.Monitor = &Monitor{}
+	}
+	h.Monitor.Owner = thread
+	h.Monitor.Count++
+}
+
+// 当前线程没有持有锁时返回 false
+func (h *Header) Exit(thread *Thread) bool {
+	if !h.IsOwner(thread) {
+		return false
+	}
+	h.Monitor.Count--
+	if h.Monitor.Count == 0 {
+		h.Monitor.Owner = nil
+	}
+	return true
+}
+
+func (h *Header) IsOwner(thread *Thread) bool {
+	return h.Monitor != nil && h.Monitor.Owner == thread
+}
+
 type Object struct {
-	Class  *Class
-	Fields []*Value // 数组不使用
-	// 数组专用，暂时只支持  int 与 Object
-	ArrayType uint8
-	ArrayData []*Value // 支持多种数据
+	Header
+	Fields []Value // 数组不使用
+	// 数组专用，按照元素类型分别为 []int8(boolean byte) []uint16(char) []int16(short) []int32 []int64 []float32 []float64 []*Object
+	ArrayData any
+	// 虚拟机内部使用的附加数据，java.lang.Class 对象指向对应的 *Class
+	Extra any
+}
+
+// 所有对象与数组都通过这里分配，统一初始化对象头，length 只对数组有效，java 代码分配对象使用 HeapAlloc 检查堆大小
+// 字段与数组元素都是零值，按照字段类型分别为 0 0L 0.0f 0.0 null
+func Alloc(class *Class, length int) *Object {
+	obj := &Object{Header: Header{Class: class}}
+	if className := class.GetName(); className[0] == '[' {
+		obj.ArrayData = newArrayData(className, length)
+	} else {
+		obj.Fields = make([]Value, class.InstSlotCount)
+	}
+	return obj
+}
+
+// 按照数组类名创建对应类型的元素
+func newArrayData(className string, count int) any {
+	var data any
+	switch className[1] {
+	case 'Z', 'B':
+		data = make([]int8, count)
+	case 'C':
+		data = make([]uint16, count)
+	case 'S':
+		data = make([]int16, count)
+	case 'I':
+		data = make([]int32, count)
+	case 'J':
+		data = make([]int64, count)
+	case 'F':
+		data = make([]float32, count)
+	case 'D':
+		data = make([]float64, count)
+	default:
+		data = make([]*Object, count)
+	}
+	return data
+}
+
+func (o *Object) ArrayLength() int {
+	switch data := o.ArrayData.(type) {
+	case []int8:
+		return len(data)
+	case []uint16:
+		return len(data)
+	case []int16:
+		return len(data)
+	case []int32:
+		return len(data)
+	case []int64:
+		return len(data)
+	case []float32:
+		return len(data)
+	case []float64:
+		return len(data)
+	case []*Object:
+		return len(data)
+	default:
+		panic(fmt.Sprintf("%s is not array", o.Class.GetName()))
+	}
 }
 
 func (o *Object) String() string {
 	return fmt.Sprintf("<%s inst>", o.Class.GetString(o.Class.ThisIndex))
 }
 
-func NewObject(object *Object) *Value {
-	return &Value{Object: object, Type: ValueObject}
+func NewObject(object *Object) Value {
+	return Value{Object: object}
 }
 
-func NewLong(long int64) *Value {
-	return &Value{Long: long, Type: ValueLong}
+func NewLong(long int64) Value {
+	return Value{Num: uint64(long)}
 }
 
-func NewInteger(integer int32) *Value {
-	return &Value{Integer: integer, Type: ValueInteger}
+func NewInteger(integer int32) Value {
+	return Value{Num: uint64(uint32(integer))}
+}
+
+// boolean 使用 int 表示，true 为 1
+func NewBoolean(val bool) Value {
+	if val {
+		return NewInteger(1)
+	}
+	return NewInteger(0)
 }
 
-func NewFloat(float float32) *Value {
-	return &Value{Float: float, Type: ValueFloat}
+func NewFloat(float float32) Value {
+	return Value{Num: uint64(math.Float32bits(float))}
 }
 
-func NewDouble(double float64) *Value {
-	return &Value{Double: double, Type: ValueDouble}
+func NewDouble(double float64) Value {
+	return Value{Num: math.Float64bits(double)}
 }
 
-func NewNull() *Value {
-	return &Value{Type: ValueNull}
+func NewNull() Value {
+	return Value{}
+}
+
+// jsr 压入的返回地址
+func NewAddress(pc int) Value {
+	return Value{Num: uint64(pc)}
 }
 
 type Frame struct {
 	Method *Field
-	Local  []*Value       // double long 占用两个其他包含指针等都是占用一个
-	Stack  *Stack[*Value] // double long 占用两个其他包含指针等都是占用一个
+	Code   *Code
+	Local  []Value       // double long 占用两个其他包含指针等都是占用一个
+	Stack  *Stack[Value] // double long 占用两个其他包含指针等都是占用一个
+	Pc     int           // 当前正在执行的指令地址，调用其他方法时停留在 invoke 指令上
+	NextPc int           // 下一条要执行的指令地址
+	Lock   *Object       // synchronized 方法进入时加的锁，出栈时释放
 }
 
-func (f *Frame) Push(val *Value) {
+func (f *Frame) Push(val Value) {
 	f.Stack.Push(val)
 }
 
-func (f *Frame) Push2(val *Value) { // 用于double  long
-	f.Stack.Push(val)
+func (f *Frame) Push2(val Value) { // 用于double  long 第二个槽位为零值
 	f.Stack.Push(val)
+	f.Stack.Push(Value{})
 }
 
-func (f *Frame) Pop() *Value {
+func (f *Frame) Pop() Value {
 	return f.Stack.Pop()
 }
 
-func (f *Frame) Pop2() *Value { // 用于double  long
+func (f *Frame) Pop2() Value { // 用于double  long
 	f.Stack.Pop()
 	return f.Stack.Pop()
 }
 
-func (f *Frame) Peek() *Value {
+func (f *Frame) Peek() Value {
 	return f.Stack.Peek()
 }
 
-func (f *Frame) PeekAt(index int) *Value {
+func (f *Frame) PeekAt(index int) Value {
 	return f.Stack.PeekAt(index)
 }
 
-func (f *Frame) Get(index int) *Value { // 不需要 Get2 只取对应的位置
+func (f *Frame) Get(index int) Value { // 不需要 Get2 只取对应的位置
 	return f.Local[index]
 }
 
-func (f *Frame) Set(val *Value, index int) { // 不需要 Set2 只取对应的位置
+func (f *Frame) Set(val Value, index int) {
+	f.Local[index] = val
+}
+
+func (f *Frame) Set2(val Value, index int) { // 用于double  long 第二个槽位为零值
 	f.Local[index] = val
+	f.Local[index+1] = Value{}
 }
 
 func (f *Frame) Clear() {
-	for !f.Stack.IsEmpty() {
-		f.Stack.Pop()
-	}
+	f.Stack.Index = 0
 }
 
-func NewFrame(method *Field, maxLocal int, maxStack int, args []*Value) *Frame {
-	local := make([]*Value, maxLocal)
+func NewFrame(method *Field, args []Value) *Frame {
+	code := method.GetCodeAttribute()
+	local := make([]Value, code.MaxLocal)
 	for i, arg := range args { // 接收初始化参数
 		local[i] = arg
 	}
-	return &Frame{Method: method, Local: local, Stack: NewStack[*Value](maxStack)}
+	return &Frame{Method: method, Code: code, Local: local, Stack: NewStack[Value](int(code.MaxStack))}
 }
 
 type Thread struct {
-	Pc     int
-	Stack  *Stack[*Frame]
+	Stack  *Stack[*Frame] // 每个栈帧记录自己的 pc，栈顶是正在执行的方法
+	VM     *VM
 	Loader *Loader
+	// java.lang.Thread 对象，第一次调用 Thread.currentThread 时创建
+	ThreadObj *Object
+	HashState [4]uint32 // xor-shift 身份哈希的线程私有状态
+	// 正在创建 StackOverflowError OutOfMemoryError，期间可以使用预留的栈帧与堆空间
+	StackOverflow bool
+	OutOfMemory   bool
 }
 
+// 调用深度达到 -Xss 时抛出 StackOverflowError，创建异常对象需要的栈帧使用预留的空间
+// 预留的栈帧也用完时抛出预先创建的异常对象
 func (t *Thread) Push(frame *Frame) {
+	if t.Stack.Index >= t.VM.MaxStackDepth+StackReserve {
+		panic(NewJavaException(t, t.VM.StackOverflowError))
+	}
+	if t.Stack.Index >= t.VM.MaxStackDepth && !t.StackOverflow {
+		t.StackOverflow = true
+		defer func() {
+			t.StackOverflow = false
+		}()
+		ThrowNew(t, "java/lang/StackOverflowError", "")
+	}
 	t.Stack.Push(frame)
+	if IsSynchronized(frame.Method.Access) { // 静态方法锁 Class 对象，实例方法锁 this
+		if IsStatic(frame.Method.Access) {
+			frame.Lock = frame.Method.Class.Mirror
+		} else {
+			frame.Lock = frame.Local[0].Object
+		}
+		frame.Lock.Enter(t)
+	}
 }
 
 func (t *Thread) Peek() *Frame {
@@ -162,33 +296,159 @@ func (t *Thread) Peek() *Frame {
 }
 
 func (t *Thread) Pop() *Frame {
-	return t.Stack.Pop()
+	frame := t.Stack.Pop()
+	if frame.Lock != nil {
+		frame.Lock.Exit(t)
+	}
+	return frame
+}
+
+// 弹出 depth 以上的栈帧，同时释放 synchronized 方法的锁
+func (t *Thread) PopTo(depth int) {
+	for t.Stack.Index > depth {
+		t.Pop()
+	}
 }
 
 func (t *Thread) IsEmpty() bool {
 	return t.Stack.IsEmpty()
 }
 
-func NewThread(loader *Loader) *Thread {
-	return &Thread{Pc: 0, Stack: NewStack[*Frame](MaxStackDepth), Loader: loader}
+func (t *Thread) StackTrace() []*StackElement {
+	res := make([]*StackElement, 0)
+	for i := t.Stack.Index - 1; i >= 0; i-- {
+		frame := t.Stack.Data[i]
+		res = append(res, &StackElement{Method: frame.Method, Pc: frame.Pc})
+	}
+	return res
+}
+
+// 抛出的 java 异常，以 panic 的形式逐个栈帧向上传递，直到被某个方法捕获
+type JavaException struct {
+	Object *Object
+	Trace  []*StackElement // 抛出时的调用栈，栈顶在前
+}
+
+func NewJavaException(thread *Thread, obj *Object) *JavaException {
+	return &JavaException{Object: obj, Trace: thread.StackTrace()}
+}
+
+// 与 Throwable.printStackTrace 格式一致，用于打印未捕获的异常
+// 优先使用 fillInStackTrace 记录的调用栈，没有记录时使用抛出时的调用栈
+func PrintStackTrace(loader *Loader, exception *JavaException, writer io.Writer) {
+	fmt.Fprintf(writer, "Exception in thread \"main\" %s\n", describeThrowable(loader, exception.Object))
+	trace := exception.Trace
+	if backtrace, ok := exception.Object.Extra.([]*StackElement); ok {
+		trace = backtrace
+	}
+	for _, item := range trace {
+		fmt.Fprintf(writer, "\tat %s\n", item)
+	}
+	throwable := loader.LoadClass("java/lang/Throwable")
+	field := throwable.GetField("cause", "Ljava/lang/Throwable;")
+	visited := map[*Object]bool{exception.Object: true}
+	for obj := exception.Object; ; {
+		cause := obj.Fields[field.SlotID]
+		if cause.Object == nil || visited[cause.Object] {
+			break // cause 指向自己表示没有 cause
+		}
+		obj = cause.Object
+		visited[obj] = true
+		fmt.Fprintf(writer, "Caused by: %s\n", describeThrowable(loader, obj))
+		causeTrace, _ := obj.Extra.([]*StackElement)
+		// 与外层调用栈末尾相同的部分省略
+		m, n := len(causeTrace)-1, len(trace)-1
+		for m >= 0 && n >= 0 && *causeTrace[m] == *trace[n] {
+			m--
+			n--
+		}
+		for _, item := range causeTrace[:m+1] {
+			fmt.Fprintf(writer, "\tat %s\n", item)
+		}
+		if common := len(causeTrace) - 1 - m; common > 0 {
+			fmt.Fprintf(writer, "\t... %d more\n", common)
+		}
+		trace = causeTrace
+	}
+}
+
+func describeThrowable(loader *Loader, obj *Object) string {
+	name := toJavaName(obj.Class.GetString(obj.Class.ThisIndex))
+	throwable := loader.LoadClass("java/lang/Throwable")
+	field := throwable.GetField("detailMessage", "Ljava/lang/String;")
+	msg := obj.Fields[field.SlotID]
+	if msg.Object == nil {
+		return name
+	}
+	return name + ": " + GoString(msg.Object)
+}
+
+type StackElement struct {
+	Method *Field
+	Pc     int
+}
+
+// 与 StackTraceElement.toString 格式一致
+func (e *StackElement) String() string {
+	name := toJavaName(e.Method.Class.GetName()) + "." + e.Method.GetName()
+	line := e.LineNumber()
+	if line == -2 {
+		return name + "(Native Method)"
+	}
+	source := e.Method.Class.GetSourceFile()
+	if source == "" {
+		return name + "(Unknown Source)"
+	}
+	if line < 0 {
+		return fmt.Sprintf("%s(%s)", name, source)
+	}
+	return fmt.Sprintf("%s(%s:%d)", name, source, line)
+}
+
+// 与 StackTraceElement.lineNumber 一致，本地方法为 -2，行号表中找不到为 -1
+func (e *StackElement) LineNumber() int32 {
+	if IsNative(e.Method.Access) {
+		return -2
+	}
+	line := GetLine(e.Method.GetCodeAttribute().GetLineNumberTable(), uint16(e.Pc))
+	if line == 0 {
+		return -1
+	}
+	return int32(line)
 }
 
-func RunMain(class *Class, loader *Loader, args []string) {
+// System.exit 最终调用 Shutdown.halt0 结束虚拟机
+type SystemExit struct {
+	Status int
+}
+
+func NewThread(vm *VM) *Thread {
+	// 与 hotspot 初始化线程哈希状态的常量一致
+	hashState := [4]uint32{vm.random(), 842502087, 0x8767, 273326509}
+	return &Thread{Stack: NewStack[*Frame](vm.MaxStackDepth + StackReserve), VM: vm, Loader: vm.Loader, HashState: hashState}
+}
+
+func RunMain(class *Class, vm *VM, args []string) {
 	method := class.GetMethod("main", "([Ljava/lang/String;)V")
-	thread := NewThread(loader)
+	thread := NewThread(vm)
+	loader := vm.Loader
 	// 构造参数
-	argsClass := loader.LoadClass("[java/lang/String")
-	data := make([]*Value, 0)
-	for _, arg := range args {
-		data = append(data, NewString(thread, arg))
+	argsClass := loader.LoadClass("[Ljava/lang/String;")
+	argArr := HeapAlloc(thread, argsClass, len(args))
+	data := argArr.ArrayData.([]*Object)
+	for i, arg := range args {
+		data[i] = NewString(loader, arg).Object
 	}
-	argVal := NewObject(&Object{Class: argsClass, ArrayData: data})
-	RunMethod(thread, method, []*Value{argVal})
+	argVal := NewObject(argArr)
+	initSystemClass(thread)
+	InitClass(thread, class)
+	RunMethod(thread, method, []Value{argVal})
 }
 
 var (
 	Instructions     = make(map[byte]Instruction)
 	InstructionNames = make(map[byte]string)
+	TraceInstruction = false // -verbose:inst 打印每条执行的指令
 )
 
 func InitInstruction() {
@@ -196,6 +456,7 @@ func InitInstruction() {
 		// constants
 		0x00: InstructionNop,
 		0x01: InstructionAConstNull,
+		0x02: InstructionIConstM1,
 		0x03: InstructionIConst0,
 		0x04: InstructionIConst1,
 		0x05: InstructionIConst2,
@@ -205,7 +466,10 @@ func InitInstruction() {
 		0x09: InstructionLConst0,
 		0x0A: InstructionLConst1,
 		0x0B: InstructionFConst0,
+		0x0C: InstructionFConst1,
+		0x0D: InstructionFConst2,
 		0x0E: InstructionDConst0,
+		0x0F: InstructionDConst1,
 		0x10: InstructionBIPush,
 		0x11: InstructionSIPush,
 		0x12: InstructionLdc,
@@ -242,14 +506,14 @@ func InitInstruction() {
 		0x2C: InstructionLoad2,
 		0x2D: InstructionLoad3,
 		// 数组
-		0x2E: InstructionALoad,
-		0x2F: Instruction2ALoad,
-		0x30: InstructionALoad,
-		0x31: Instruction2ALoad,
-		0x32: InstructionALoad,
-		0x33: InstructionALoad,
-		0x34: InstructionALoad,
-		0x35: InstructionALoad,
+		0x2E: InstructionIALoad,
+		0x2F: InstructionLALoad,
+		0x30: InstructionFALoad,
+		0x31: InstructionDALoad,
+		0x32: InstructionAALoad,
+		0x33: InstructionBALoad,
+		0x34: InstructionCALoad,
+		0x35: InstructionSALoad,
 		// Integer
 		0x36: InstructionStore,
 		0x3B: InstructionStore0,
@@ -281,14 +545,14 @@ func InitInstruction() {
 		0x4D: InstructionStore2,
 		0x4E: InstructionStore3,
 		// array
-		0x4F: InstructionAStore,
-		0x50: Instruction2AStore,
-		0x51: InstructionAStore,
-		0x52: Instruction2AStore,
-		0x53: InstructionAStore,
-		0x54: InstructionAStore,
-		0x55: InstructionAStore,
-		0x56: InstructionAStore,
+		0x4F: InstructionIAStore,
+		0x50: InstructionLAStore,
+		0x51: InstructionFAStore,
+		0x52: InstructionDAStore,
+		0x53: InstructionAAStore,
+		0x54: InstructionBAStore,
+		0x55: InstructionCAStore,
+		0x56: InstructionSAStore,
 		// stack
 		0x57: InstructionPop,
 		0x58: InstructionPop2,
@@ -324,26 +588,60 @@ func InitInstruction() {
 		0x75: InstructionLNeg,
 		0x76: InstructionFNeg,
 		0x77: InstructionDNeg,
-		0x7e: InstructionIAnd,
+		0x78: InstructionIShl,
+		0x79: InstructionLShl,
+		0x7A: InstructionIShr,
+		0x7B: InstructionLShr,
+		0x7C: InstructionIUShr,
+		0x7D: InstructionLUShr,
+		0x7E: InstructionIAnd,
 		0x7F: InstructionLAnd,
 		0x80: InstructionIOr,
 		0x81: InstructionLOr,
 		0x82: InstructionIXor,
 		0x83: InstructionLXor,
 		0x84: InstructionIInc,
+		0x85: InstructionI2L,
+		0x86: InstructionI2F,
+		0x87: InstructionI2D,
+		0x88: InstructionL2I,
+		0x89: InstructionL2F,
+		0x8A: InstructionL2D,
+		0x8B: InstructionF2I,
+		0x8C: InstructionF2L,
+		0x8D: InstructionF2D,
+		0x8E: InstructionD2I,
+		0x8F: InstructionD2L,
+		0x90: InstructionD2F,
 		// conversions
 		0x91: InstructionI2B,
+		0x92: InstructionI2C,
+		0x93: InstructionI2S,
 		// comparisons
 		0x94: InstructionLCmp,
+		0x95: InstructionFCmpL,
+		0x96: InstructionFCmpG,
+		0x97: InstructionDCmpL,
+		0x98: InstructionDCmpG,
 		0x99: InstructionIfEq,
 		0x9A: InstructionIfNe,
+		0x9B: InstructionIfLt,
+		0x9C: InstructionIfGe,
 		0x9D: InstructionIfGt,
+		0x9E: InstructionIfLe,
+		0x9F: InstructionIfICmpEq,
 		0xA0: InstructionIfICmpNe,
+		0xA1: InstructionIfICmpLt,
 		0xA2: InstructionIfICmpGe,
 		0xA3: InstructionIfICmpGt,
 		0xA4: InstructionIfICmpLe,
+		0xA5: InstructionIfACmpEq,
 		0xA6: InstructionIfACmpNe,
 		0xA7: InstructionGoTo,
+		0xA8: InstructionJsr,
+		0xA9: InstructionRet,
+		0xAA: InstructionTableSwitch,
+		0xAB: InstructionLookupSwitch,
 		// control
 		0xAC: InstructionReturn1,
 		0xAD: InstructionReturn2,
@@ -360,6 +658,7 @@ func InitInstruction() {
 		0xB7: InstructionInvokeSpecial,
 		0xB8: InstructionInvokeStatic,
 		0xB9: InstructionInvokeInterface,
+		0xBA: InstructionInvokeDynamic,
 		0xBB: InstructionNew,
 		0xBC: InstructionNewArray,
 		0xBD: InstructionObjArray,
@@ -367,15 +666,21 @@ func InitInstruction() {
 		0xBF: InstructionAThrow,
 		0xC0: InstructionCheckCast,
 		0xC1: InstructionInstanceOf,
-		0xC5: InstructionMultiArray,
+		0xC2: InstructionMonitorEnter,
+		0xC3: InstructionMonitorExit,
 		// extended
+		0xC4: InstructionWide,
+		0xC5: InstructionMultiArray,
 		0xC6: InstructionIfNull,
 		0xC7: InstructionIfNonNull,
+		0xC8: InstructionGoToW,
+		0xC9: InstructionJsrW,
 	}
 	InstructionNames = map[byte]string{
 		// constants
 		0x00: "Nop",
 		0x01: "AConstNull",
+		0x02: "IConstM1",
 		0x03: "IConst0",
 		0x04: "IConst1",
 		0x05: "IConst2",
@@ -385,7 +690,10 @@ func InitInstruction() {
 		0x09: "LConst0",
 		0x0A: "LConst1",
 		0x0B: "FConst0",
+		0x0C: "FConst1",
+		0x0D: "FConst2",
 		0x0E: "DConst0",
+		0x0F: "DConst1",
 		0x10: "BIPush",
 		0x11: "SIPush",
 		0x12: "Ldc",
@@ -422,14 +730,14 @@ func InitInstruction() {
 		0x2C: "Load2",
 		0x2D: "Load3",
 		// 数组
-		0x2E: "ALoad",
-		0x2F: "2ALoad",
-		0x30: "ALoad",
-		0x31: "2ALoad",
-		0x32: "ALoad",
-		0x33: "ALoad",
-		0x34: "ALoad",
-		0x35: "ALoad",
+		0x2E: "IALoad",
+		0x2F: "LALoad",
+		0x30: "FALoad",
+		0x31: "DALoad",
+		0x32: "AALoad",
+		0x33: "BALoad",
+		0x34: "CALoad",
+		0x35: "SALoad",
 		// Integer
 		0x36: "Store",
 		0x3B: "Store0",
@@ -461,14 +769,14 @@ func InitInstruction() {
 		0x4D: "Store2",
 		0x4E: "Store3",
 		// array
-		0x4F: "AStore",
-		0x50: "2AStore",
-		0x51: "AStore",
-		0x52: "2AStore",
-		0x53: "AStore",
-		0x54: "AStore",
-		0x55: "AStore",
-		0x56: "AStore",
+		0x4F: "IAStore",
+		0x50: "LAStore",
+		0x51: "FAStore",
+		0x52: "DAStore",
+		0x53: "AAStore",
+		0x54: "BAStore",
+		0x55: "CAStore",
+		0x56: "SAStore",
 		// stack
 		0x57: "Pop",
 		0x58: "Pop2",
@@ -504,26 +812,60 @@ func InitInstruction() {
 		0x75: "LNeg",
 		0x76: "FNeg",
 		0x77: "DNeg",
-		0x7e: "IAnd",
+		0x78: "IShl",
+		0x79: "LShl",
+		0x7A: "IShr",
+		0x7B: "LShr",
+		0x7C: "IUShr",
+		0x7D: "LUShr",
+		0x7E: "IAnd",
 		0x7F: "LAnd",
 		0x80: "IOr",
 		0x81: "LOr",
 		0x82: "IXor",
 		0x83: "LXor",
 		0x84: "IInc",
+		0x85: "I2L",
+		0x86: "I2F",
+		0x87: "I2D",
+		0x88: "L2I",
+		0x89: "L2F",
+		0x8A: "L2D",
+		0x8B: "F2I",
+		0x8C: "F2L",
+		0x8D: "F2D",
+		0x8E: "D2I",
+		0x8F: "D2L",
+		0x90: "D2F",
 		// conversions
-		0x91: "I2b",
+		0x91: "I2B",
+		0x92: "I2C",
+		0x93: "I2S",
 		// comparisons
 		0x94: "LCmp",
+		0x95: "FCmpL",
+		0x96: "FCmpG",
+		0x97: "DCmpL",
+		0x98: "DCmpG",
 		0x99: "IfEq",
 		0x9A: "IfNe",
+		0x9B: "IfLt",
+		0x9C: "IfGe",
 		0x9D: "IfGt",
+		0x9E: "IfLe",
+		0x9F: "IfICmpEq",
 		0xA0: "IfICmpNe",
+		0xA1: "IfICmpLt",
 		0xA2: "IfICmpGe",
 		0xA3: "IfICmpGt",
 		0xA4: "IfICmpLe",
+		0xA5: "IfACmpEq",
 		0xA6: "IfACmpNe",
 		0xA7: "GoTo",
+		0xA8: "Jsr",
+		0xA9: "Ret",
+		0xAA: "TableSwitch",
+		0xAB: "LookupSwitch",
 		// control
 		0xAC: "Return1",
 		0xAD: "Return2",
@@ -540,6 +882,7 @@ func InitInstruction() {
 		0xB7: "InvokeSpecial",
 		0xB8: "InvokeStatic",
 		0xB9: "InvokeInterface",
+		0xBA: "InvokeDynamic",
 		0xBB: "New",
 		0xBC: "NewArray",
 		0xBD: "ObjArray",
@@ -547,63 +890,229 @@ func InitInstruction() {
 		0xBF: "AThrow",
 		0xC0: "CheckCast",
 		0xC1: "InstanceOf",
-		0xC5: "MultiArray",
+		0xC2: "MonitorEnter",
+		0xC3: "MonitorExit",
 		// extended
+		0xC4: "Wide",
+		0xC5: "MultiArray",
 		0xC6: "IfNull",
 		0xC7: "IfNonNull",
+		0xC8: "GoToW",
+		0xC9: "JsrW",
 	}
 }
 
-var (
-	internStrings = make(map[string]*Value)
+// 每次都创建新的字符串对象，字面量使用 InternString，只依赖 Loader，类准备阶段也可以创建
+func NewString(loader *Loader, val string) Value {
+	// string 对象
+	class := loader.LoadClass("java/lang/String")
+	res := NewObject(Alloc(class, 0))
+	units := StringToUTF16(val)
+	if field := class.GetField("value", "[C"); field != nil { // jdk8 使用 char[] 存储 utf-16 编码单元
+		chars := Alloc(loader.LoadClass("[C"), len(units))
+		copy(chars.ArrayData.([]uint16), units)
+		res.Object.Fields[field.SlotID] = NewObject(chars)
+	} else { // jdk9+ 使用 byte[] 存储，coder 标识编码
+		bytes, coder := encodeStringBytes(loader, class, units)
+		res.Object.Fields[class.GetField("value", "[B").SlotID] = NewObject(bytes)
+		res.Object.Fields[class.GetField("coder", "B").SlotID] = NewInteger(coder)
+	}
+	return res
+}
+
+// jdk9+ String 的 coder
+const (
+	StringLatin1 = 0
+	StringUTF16  = 1
 )
 
-func NewString(thread *Thread, val string) *Value {
-	if _, ok := internStrings[val]; !ok {
-		// string 对象
-		class := thread.Loader.LoadClass("java/lang/String")
-		res := NewObject(&Object{Class: class, Fields: make([]*Value, class.InstSlotCount)})
-		// char[] 对象
-		fieldClass := thread.Loader.LoadClass("[C")
-		data := make([]*Value, 0)
-		for i := 0; i < len(val); i++ { // 这里使用的 utf-8 编码 非  utf-16 编码
-			data = append(data, NewInteger(int32(val[i])))
+// 开启压缩字符串且只包含 latin1 字符时每个字符 1 byte，否则每个字符 2 byte
+// StringUTF16.isBigEndian 返回 false，所以按照小端序存储
+func encodeStringBytes(loader *Loader, class *Class, units []uint16) (*Object, int32) {
+	latin1 := compactStrings(class)
+	for _, item := range units {
+		if item > 0xFF {
+			latin1 = false
+			break
 		}
-		value := NewObject(&Object{Class: fieldClass, ArrayType: ArrayChar, ArrayData: data})
-		// 设置值
-		field := class.GetField("value", "[C")
-		res.Object.Fields[field.SlotID] = value
-		internStrings[val] = res
 	}
-	return internStrings[val]
+	bytesClass := loader.LoadClass("[B")
+	if latin1 {
+		bytes := Alloc(bytesClass, len(units))
+		data := bytes.ArrayData.([]int8)
+		for i, item := range units {
+			data[i] = int8(item)
+		}
+		return bytes, StringLatin1
+	}
+	bytes := Alloc(bytesClass, len(units)*2)
+	data := bytes.ArrayData.([]int8)
+	for i, item := range units {
+		data[i*2] = int8(item)
+		data[i*2+1] = int8(item >> 8)
+	}
+	return bytes, StringUTF16
 }
 
-func RunMethod(thread *Thread, method *Field, args []*Value) {
-	code := method.GetCodeAttribute()
-	thread.Push(NewFrame(method, int(code.MaxLocal), int(code.MaxStack), args))
-	pc := 0
-	class := method.Class
-	name := class.GetString(class.ThisIndex) + "." + class.GetString(method.NameIndex)
-	table := code.GetLineNumberTable()
-	for pc < len(code.Code) {
-		opCode := code.Code[pc]
-		fmt.Printf("pc:%d opcode:%x %s %s:%d\n", pc, opCode, InstructionNames[opCode], name, GetLine(table, uint16(pc)))
-		pc++
-		if instruction, ok := Instructions[opCode]; ok {
-			pc = instruction(thread, class, code, pc)
+// COMPACT_STRINGS 在 <clinit> 中赋值为 true，hotspot 只在 -XX:-CompactStrings 时改为 false
+// String 初始化之前创建的字符串(常量字段 ldc)没有线程执行 <clinit>，按照初始化后的值处理
+func compactStrings(class *Class) bool {
+	if class.InitState != ClassInitialized {
+		return true
+	}
+	return class.StaticValues[class.GetField("COMPACT_STRINGS", "Z").SlotID].Integer() != 0
+}
+
+func decodeStringBytes(data []int8, coder int32) []uint16 {
+	if coder == StringLatin1 {
+		res := make([]uint16, len(data))
+		for i, item := range data {
+			res[i] = uint16(uint8(item))
+		}
+		return res
+	}
+	res := make([]uint16, len(data)/2)
+	for i := range res {
+		res[i] = uint16(uint8(data[i*2])) | uint16(uint8(data[i*2+1]))<<8
+	}
+	return res
+}
+
+// 创建对象并调用指定的构造方法
+func NewInstance(thread *Thread, className string, desc string, args ...Value) *Object {
+	class := thread.Loader.LoadClass(className)
+	InitClass(thread, class)
+	obj := HeapAlloc(thread, class, 0)
+	RunMethod(thread, class.GetMethod("<init>", desc), append([]Value{NewObject(obj)}, args...))
+	return obj
+}
+
+// 由虚拟机抛出 java 异常，与 java 代码中 throw 的异常走相同的处理流程，msg 为空时不设置异常信息
+func ThrowNew(thread *Thread, className string, msg string) {
+	var obj *Object
+	if msg == "" {
+		obj = NewInstance(thread, className, "()V")
+	} else {
+		obj = NewInstance(thread, className, "(Ljava/lang/String;)V", NewString(thread.Loader, msg))
+	}
+	panic(NewJavaException(thread, obj))
+}
+
+// java 字符串转换为 go 字符串
+func GoString(obj *Object) string {
+	class := obj.Class
+	if field := class.GetField("value", "[C"); field != nil {
+		return UTF16ToString(obj.Fields[field.SlotID].Object.ArrayData.([]uint16))
+	}
+	data := obj.Fields[class.GetField("value", "[B").SlotID].Object.ArrayData.([]int8)
+	coder := obj.Fields[class.GetField("coder", "B").SlotID].Integer()
+	return UTF16ToString(decodeStringBytes(data, coder))
+}
+
+// go 代码(类初始化 本地方法等)调用 java 方法的入口，执行完成后才返回，返回值压入调用方栈帧
+func RunMethod(thread *Thread, method *Field, args []Value) {
+	depth := thread.Stack.Index
+	thread.Push(NewFrame(method, args))
+	Interpret(thread, depth)
+}
+
+// 与 RunMethod 相同，参数已经压入当前栈帧，本地方法与虚方法也可以使用
+func CallMethod(thread *Thread, method *Field) {
+	depth := thread.Stack.Index
+	invokeMethod(thread, method)
+	Interpret(thread, depth)
+}
+
+// go 代码中捕获 call 抛出的 java 异常，恢复到调用之前的栈深度，没有异常时返回 nil
+func CatchException(thread *Thread, call func()) (res *JavaException) {
+	depth := thread.Stack.Index
+	defer func() {
+		if err := recover(); err != nil {
+			exception, ok := err.(*JavaException)
+			if !ok {
+				panic(err)
+			}
+			thread.PopTo(depth)
+			res = exception
+		}
+	}()
+	call()
+	return nil
+}
+
+// 解释执行到线程栈回到 depth，方法调用只压入栈帧，返回时弹出栈帧，都在同一个循环中完成不会递归
+// 当前方法处理不了的异常弹出栈帧后继续交给调用方处理，回到 depth 时继续向 go 代码抛出
+func Interpret(thread *Thread, depth int) {
+	for thread.Stack.Index > depth {
+		exception := runFrames(thread, depth)
+		if exception == nil {
+			continue
+		}
+		handleException(thread, depth, exception)
+	}
+}
+
+// 从栈顶开始查找异常处理代码，没有找到的栈帧直接弹出
+func handleException(thread *Thread, depth int, exception *JavaException) {
+	for thread.Stack.Index > depth {
+		frame := thread.Peek()
+		exceptionItem := frame.Code.FindException(thread, frame.Method.Class, uint16(frame.Pc), exception.Object)
+		if exceptionItem != nil { // 压入异常对象，跳转到异常处理代码
+			frame.Clear()
+			frame.Push(NewObject(exception.Object))
+			frame.NextPc = int(exceptionItem.Handler)
+			return
+		}
+		thread.Pop()
+	}
+	panic(exception)
+}
+
+// 执行到线程栈回到 depth 或者抛出 java 异常，抛出异常时栈顶为抛出异常的方法
+func runFrames(thread *Thread, depth int) (res *JavaException) {
+	index := thread.Stack.Index
+	defer func() {
+		if err := recover(); err != nil {
+			exception, ok := err.(*JavaException)
+			if !ok {
+				panic(err)
+			}
+			thread.PopTo(index) // 丢弃被调用方法残留的栈帧
+			res = exception
+		}
+	}()
+	for thread.Stack.Index > depth {
+		index = thread.Stack.Index
+		frame := thread.Peek()
+		frame.Pc = frame.NextPc
+		code := frame.Code
+		opCode := code.Code[frame.Pc]
+		class := frame.Method.Class
+		if TraceInstruction {
+			fmt.Fprintf(os.Stderr, "pc:%d opcode:%x %s %s.%s:%d\n", frame.Pc, opCode, InstructionNames[opCode], class.GetName(),
+				frame.Method.GetName(), GetLine(code.GetLineNumberTable(), uint16(frame.Pc)))
+		}
+		if instruction, ok := Instructions[opCode]; ok { // 调用与返回指令会切换栈顶，返回值只对执行指令的栈帧有效
+			frame.NextPc = instruction(thread, class, code, frame.Pc+1)
 		} else {
 			panic(fmt.Sprintf("opcode %x not found", opCode))
 		}
 	}
+	return nil
 }
 
+// 取起始地址不超过 pc 的最后一项
 func GetLine(table []*LineNumber, pc uint16) uint16 {
-	for _, item := range table {
-		if item.Start >= pc { // 量级比较小可以循环
-			return item.Line
+	var res *LineNumber
+	for _, item := range table { // 量级比较小可以循环，表不一定有序
+		if item.Start <= pc && (res == nil || item.Start > res.Start) {
+			res = item
 		}
 	}
-	return 0
+	if res == nil {
+		return 0
+	}
+	return res.Line
 }
 
 type MethodDesc struct {
@@ -628,7 +1137,7 @@ func (p *MethodDescParser) Parse() *MethodDesc {
 
 func (p *MethodDescParser) ParseType() string {
 	switch p.Desc[p.Index] {
-	case 'I', 'V', 'Z', 'J':
+	case 'B', 'C', 'D', 'F', 'I', 'J', 'S', 'Z', 'V':
 		p.Index++
 		return p.Desc[p.Index-1 : p.Index]
 	case 'L': // 引用类型
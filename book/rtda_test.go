@@ -0,0 +1,331 @@
+/*
+@author: sk
+@date: 2026/10/18
+*/
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// static long arith(int n) { long s = 0; for (int i = 0; i < n; i++) s += i * i ^ i >> 1; return s; }
+// static int alloc(int n) { for (int i = 0; i < n; i++) { new Object(); new int[4]; } return n; }
+// static int dup(int x) { int y = (byte) x; return x + y; } 用 dup 复制后修改，检查槽位之间不会互相影响
+func loopClass() *testClass {
+	class := newTestClass("Loop", "java/lang/Object")
+	class.method(AccessPublic|AccessStatic, "arith", "(I)J", newTestCode(5, 4).
+		op(0x09, 0x40, 0x03, 0x3E). // lconst_0 lstore_1 iconst_0 istore_3
+		label("loop").
+		op(0x1D, 0x1A).jump(0xA2, "end").        // iload_3 iload_0 if_icmpge
+		op(0x1F, 0x1D, 0x1D, 0x68).              // lload_1 iload_3 iload_3 imul
+		op(0x1D, 0x04, 0x7A, 0x82, 0x85, 0x61).  // iload_3 iconst_1 ishr ixor i2l ladd
+		op(0x40, 0x84, 3, 1).jump(0xA7, "loop"). // lstore_1 iinc goto
+		label("end").
+		op(0x1F, 0xAD)) // lload_1 lreturn
+	class.method(AccessPublic|AccessStatic, "alloc", "(I)I", newTestCode(2, 2).
+		op(0x03, 0x3C). // iconst_0 istore_1
+		label("loop").
+		op(0x1B, 0x1A).jump(0xA2, "end").                                                 // iload_1 iload_0 if_icmpge
+		op16(0xBB, class.class("java/lang/Object")).op(0x59).                             // new dup
+		op16(0xB7, class.ref(ConstMethod, "java/lang/Object", "<init>", "()V")).op(0x57). // invokespecial pop
+		op(0x07, 0xBC, ArrayInt, 0x57).                                                   // iconst_4 newarray pop
+		op(0x84, 1, 1).jump(0xA7, "loop").                                                // iinc goto
+		label("end").
+		op(0x1B, 0xAC)) // iload_1 ireturn
+	class.method(AccessPublic|AccessStatic, "dup", "(I)I", newTestCode(3, 1).
+		op(0x1A, 0x59, 0x91, 0x60, 0xAC)) // iload_0 dup i2b iadd ireturn
+	return class
+}
+
+func TestValueNoAlias(t *testing.T) {
+	vm := newTestVM(t, loopClass())
+	res, exception := runStatic(vm, "Loop", "dup", "(I)I", NewInteger(0x1FF))
+	if exception != nil || res.Integer() != 0x1FF-1 {
+		t.Fatalf("dup(0x1FF) = %d, exception %v, want %d", res.Integer(), exception, 0x1FF-1)
+	}
+}
+
+func BenchmarkArith(b *testing.B) {
+	vm := newTestVM(b, loopClass())
+	want := int64(0)
+	for i := int32(0); i < 10000; i++ {
+		want += int64(i*i ^ i>>1)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		res, exception := runStatic(vm, "Loop", "arith", "(I)J", NewInteger(10000))
+		if exception != nil || res.Long() != want {
+			b.Fatalf("arith(10000) = %d, exception %v, want %d", res.Long(), exception, want)
+		}
+	}
+}
+
+func BenchmarkAlloc(b *testing.B) {
+	vm := newTestVM(b, loopClass())
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, exception := runStatic(vm, "Loop", "alloc", "(I)I", NewInteger(10000)); exception != nil {
+			b.Fatal(exception)
+		}
+	}
+}
+
+// static void throwIt(int kind) 0 正常返回 1 IllegalArgumentException 2 ArithmeticException 其他 Error
+// static int inner(int kind) { try { throwIt(kind); return 0; } catch (ArithmeticException e) { return 3; } }
+// static int outer(int kind) { try { return inner(kind); } catch (RuntimeException e) { return 1; } }
+// static int all(int kind) { try { return outer(kind); } catch (Throwable e) { return 2; } } catch all 与 finally 一致
+// static int boundary(int kind) athrow 位于 end_pc，不在 catch all 的范围内
+func catchClass() *testClass {
+	class := newTestClass("Catch", "java/lang/Object")
+	// new dup invokespecial athrow
+	newThrow := func(code *testCode, name string) *testCode {
+		code.op16(0xBB, class.class(name)).op(0x59)
+		return code.op16(0xB7, class.ref(ConstMethod, name, "<init>", "()V")).op(0xBF)
+	}
+	throwIt := newTestCode(2, 1).
+		op(0x1A).jump(0x9A, "kind1").op(0xB1). // iload_0 ifne return
+		label("kind1").
+		op(0x1A, 0x04).jump(0xA0, "kind2") // iload_0 iconst_1 if_icmpne
+	newThrow(throwIt, "java/lang/IllegalArgumentException").label("kind2").
+		op(0x1A, 0x05).jump(0xA0, "error") // iload_0 iconst_2 if_icmpne
+	newThrow(throwIt, "java/lang/ArithmeticException").label("error")
+	class.method(AccessStatic, "throwIt", "(I)V", newThrow(throwIt, "java/lang/Error"))
+	// 返回 callee(kind) 的结果，void 方法返回 0，捕获到异常时返回 res
+	tryCall := func(name string, callee string, desc string, res byte, catchType string) {
+		code := newTestCode(1, 1).label("start").
+			op(0x1A).op16(0xB8, class.ref(ConstMethod, "Catch", callee, desc)) // iload_0 invokestatic
+		if desc == "(I)V" {
+			code.op(0x03) // iconst_0
+		}
+		code.label("end").op(0xAC)                // ireturn
+		code.label("handler").op(0x57, res, 0xAC) // pop iconst_x ireturn
+		code.catch("start", "end", "handler", catchType)
+		class.method(AccessStatic, name, "(I)I", code)
+	}
+	tryCall("inner", "throwIt", "(I)V", 0x06, "java/lang/ArithmeticException")
+	tryCall("outer", "inner", "(I)I", 0x04, "java/lang/RuntimeException")
+	tryCall("all", "outer", "(I)I", 0x05, "")
+	// new dup invokespecial nop athrow，异常表只包含 nop
+	boundary := newTestCode(2, 1).op16(0xBB, class.class("java/lang/Error")).op(0x59).
+		op16(0xB7, class.ref(ConstMethod, "java/lang/Error", "<init>", "()V")).
+		label("start").op(0x00).label("end").op(0xBF).
+		label("handler").op(0x57, 0x04, 0xAC). // pop iconst_1 ireturn
+		catch("start", "end", "handler", "")
+	class.method(AccessStatic, "boundary", "(I)I", boundary)
+	return class
+}
+
+func TestHandleException(t *testing.T) {
+	vm := newTestVM(t, catchClass())
+	tests := []struct {
+		name      string
+		method    string
+		kind      int32
+		res       int32
+		exception string // 没有被捕获，继续抛给 go 代码的异常
+	}{
+		{"no exception", "all", 0, 0, ""},
+		{"caught in same method", "all", 2, 3, ""},
+		{"rethrow to caller subclass", "all", 1, 1, ""},
+		{"rethrow to catch all", "all", 3, 2, ""},
+		{"rethrow to go", "outer", 3, 0, "java/lang/Error"},
+		{"thrown at end_pc", "boundary", 0, 0, "java/lang/Error"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, exception := runStatic(vm, "Catch", test.method, "(I)I", NewInteger(test.kind))
+			if test.exception != "" {
+				if exception == nil || exception.Object.Class.GetName() != test.exception {
+					t.Fatalf("%s(%d) exception = %v, want %s", test.method, test.kind, exception, test.exception)
+				}
+				return
+			}
+			if exception != nil {
+				t.Fatalf("%s(%d) uncaught %s", test.method, test.kind, exception.Object.Class.GetName())
+			}
+			if res.Integer() != test.res {
+				t.Errorf("%s(%d) = %d, want %d", test.method, test.kind, res.Integer(), test.res)
+			}
+		})
+	}
+}
+
+// 与 jdk8 一致，Throwable 的构造方法调用 fillInStackTrace 记录调用栈，StackTraceElement 由本地方法填充字段
+func traceRuntime() []*testClass {
+	const name = "java/lang/Throwable"
+	throwable := newTestClass(name, "java/lang/Object")
+	throwable.field(AccessPrivate, "detailMessage", "Ljava/lang/String;")
+	throwable.field(AccessPrivate, "cause", "Ljava/lang/Throwable;")
+	throwable.field(AccessPrivate|AccessTransient, "backtrace", "Ljava/lang/Object;")
+	cause := throwable.ref(ConstField, name, "cause", "Ljava/lang/Throwable;")
+	// super() this.cause = this [this.detailMessage = msg] fillInStackTrace()
+	for _, desc := range []string{"()V", "(Ljava/lang/String;)V"} {
+		code := newTestCode(2, 2).op(0x2A).op16(0xB7, throwable.ref(ConstMethod, "java/lang/Object", "<init>", "()V")).
+			op(0x2A, 0x2A).op16(0xB5, cause)
+		if desc != "()V" {
+			code.op(0x2A, 0x2B).op16(0xB5, throwable.ref(ConstField, name, "detailMessage", "Ljava/lang/String;"))
+		}
+		code.op(0x2A).op16(0xB6, throwable.ref(ConstMethod, name, "fillInStackTrace", "()Ljava/lang/Throwable;")).op(0x57, 0xB1)
+		throwable.method(AccessPublic, "<init>", desc, code)
+	}
+	throwable.method(AccessPublic|AccessSynchronized, "fillInStackTrace", "()Ljava/lang/Throwable;", newTestCode(2, 1).
+		op(0x2A, 0x03).op16(0xB7, throwable.ref(ConstMethod, name, "fillInStackTrace", "(I)Ljava/lang/Throwable;")).op(0xB0)) // aload_0 iconst_0 invokespecial areturn
+	throwable.method(AccessPrivate|AccessNative, "fillInStackTrace", "(I)Ljava/lang/Throwable;", nil)
+	throwable.method(AccessNative, "getStackTraceDepth", "()I", nil)
+	throwable.method(AccessNative, "getStackTraceElement", "(I)Ljava/lang/StackTraceElement;", nil)
+	throwable.method(AccessPublic, "initCause", "(Ljava/lang/Throwable;)Ljava/lang/Throwable;", newTestCode(2, 2).
+		op(0x2A, 0x2B).op16(0xB5, cause).op(0x2A, 0xB0)) // aload_0 aload_1 putfield aload_0 areturn
+	element := newTestClass("java/lang/StackTraceElement", "java/lang/Object")
+	element.access |= AccessFinal
+	for _, field := range []string{"declaringClass", "methodName", "fileName"} {
+		element.field(AccessPrivate, field, "Ljava/lang/String;")
+	}
+	element.field(AccessPrivate, "lineNumber", "I")
+	element.method(AccessStatic|AccessNative, "initStackTraceElements", "([Ljava/lang/StackTraceElement;Ljava/lang/Throwable;)V", nil)
+	return append(systemClasses("real"), throwable, element)
+}
+
+// static void bar(int n) { if (n == 0) throw new RuntimeException("boom"); bar(n - 1); } 第 10~14 行
+// static void wrap() { try { bar(2); } catch (RuntimeException e) { throw (RuntimeException) new RuntimeException("wrapped").initCause(e); } } 第 20~23 行
+// static Throwable catchBar(int n) { try { bar(n); } catch (RuntimeException e) { return e; } } 第 30~31 行
+// main 调用 wrap，没有行号表
+func traceClass() *testClass {
+	class := newTestClass("Foo", "java/lang/Object")
+	class.source = "Foo.java"
+	const exception = "java/lang/RuntimeException"
+	newException := func(code *testCode, msg string) *testCode { // new dup ldc_w invokespecial
+		return code.op16(0xBB, class.class(exception)).op(0x59).op16(0x13, class.str(msg)).
+			op16(0xB7, class.ref(ConstMethod, exception, "<init>", "(Ljava/lang/String;)V"))
+	}
+	bar := class.ref(ConstMethod, "Foo", "bar", "(I)V")
+	// iload_0 ifne，new dup ldc_w invokespecial athrow，iload_0 iconst_1 isub invokestatic return
+	code := newTestCode(3, 1).line(10).op(0x1A).jump(0x9A, "next").line(11)
+	newException(code, "boom").op(0xBF)
+	code.label("next").line(13).op(0x1A, 0x04, 0x64).op16(0xB8, bar).line(14).op(0xB1)
+	class.method(AccessStatic, "bar", "(I)V", code)
+	// iconst_2 invokestatic return，astore_0 new dup ldc_w invokespecial aload_0 invokevirtual athrow
+	code = newTestCode(3, 1).label("start").line(20).op(0x05).op16(0xB8, bar).label("end").line(21).op(0xB1)
+	code.label("handler").line(22).op(0x4B).line(23)
+	newException(code, "wrapped").op(0x2A).
+		op16(0xB6, class.ref(ConstMethod, "java/lang/Throwable", "initCause", "(Ljava/lang/Throwable;)Ljava/lang/Throwable;")).op(0xBF)
+	class.method(AccessStatic, "wrap", "()V", code.catch("start", "end", "handler", exception))
+	class.method(AccessStatic, "catchBar", "(I)Ljava/lang/Throwable;", newTestCode(1, 1).
+		label("start").line(30).op(0x1A).op16(0xB8, bar).label("end").op(0x01, 0xB0). // iload_0 invokestatic aconst_null areturn
+		label("handler").line(31).op(0xB0).catch("start", "end", "handler", exception))
+	class.method(AccessPublic|AccessStatic, "main", "([Ljava/lang/String;)V", newTestCode(0, 1).
+		op16(0xB8, class.ref(ConstMethod, "Foo", "wrap", "()V")).op(0xB1))
+	class.method(AccessStatic|AccessNative, "nat", "()V", nil)
+	return class
+}
+
+// fillInStackTrace 跳过自己与异常的构造方法，cause 与外层调用栈末尾相同的部分省略为 ... n more，没有行号表时不显示行号
+func TestPrintStackTrace(t *testing.T) {
+	vm := newTestVM(t, append(traceRuntime(), traceClass())...)
+	out, errOut := new(bytes.Buffer), new(bytes.Buffer)
+	vm.Stdout, vm.Stderr = out, errOut
+	if status := vm.Run("Foo", nil); status != 1 {
+		t.Fatalf("exit status %d, want 1", status)
+	}
+	want := `Exception in thread "main" java.lang.RuntimeException: wrapped
+	at Foo.wrap(Foo.java:23)
+	at Foo.main(Foo.java)
+Caused by: java.lang.RuntimeException: boom
+	at Foo.bar(Foo.java:11)
+	at Foo.bar(Foo.java:13)
+	at Foo.bar(Foo.java:13)
+	at Foo.wrap(Foo.java:20)
+	... 1 more
+`
+	if errOut.String() != want {
+		t.Errorf("stack trace:\n%s\nwant:\n%s", errOut, want)
+	}
+}
+
+func TestStackTraceElements(t *testing.T) {
+	vm := newTestVM(t, append(traceRuntime(), traceClass())...)
+	vm.MaxStackDepth = MaxStackTrace * 2
+	res, exception := runStatic(vm, "Foo", "catchBar", "(I)Ljava/lang/Throwable;", NewInteger(2))
+	if exception != nil {
+		t.Fatalf("uncaught %s", exception.Object.Class.GetName())
+	}
+	throwable := res.Object
+	depth, _ := runStatic(vm, "java/lang/Throwable", "getStackTraceDepth", "()I", res)
+	if int(depth.Integer()) != len(getBacktrace(throwable)) {
+		t.Fatalf("getStackTraceDepth = %d, want %d", depth.Integer(), len(getBacktrace(throwable)))
+	}
+	class := vm.Loader.LoadClass("java/lang/StackTraceElement")
+	describe := func(element *Object) string {
+		res := ""
+		for _, name := range []string{"declaringClass", "methodName", "fileName"} {
+			if value := element.Fields[class.GetField(name, "Ljava/lang/String;").SlotID].Object; value != nil {
+				res += GoString(value) + " "
+			}
+		}
+		return fmt.Sprint(res, element.Fields[class.GetField("lineNumber", "I").SlotID].Integer())
+	}
+	want := []string{"Foo bar Foo.java 11", "Foo bar Foo.java 13", "Foo bar Foo.java 13", "Foo catchBar Foo.java 30"}
+	elements := Alloc(vm.Loader.LoadClass("[Ljava/lang/StackTraceElement;"), int(depth.Integer()))
+	for i := range elements.ArrayData.([]*Object) {
+		elements.ArrayData.([]*Object)[i] = Alloc(class, 0)
+	}
+	runStatic(vm, "java/lang/StackTraceElement", "initStackTraceElements",
+		"([Ljava/lang/StackTraceElement;Ljava/lang/Throwable;)V", NewObject(elements), res)
+	for i, item := range want {
+		element, exception := runStatic(vm, "java/lang/Throwable", "getStackTraceElement", "(I)Ljava/lang/StackTraceElement;", res, NewInteger(int32(i)))
+		if exception != nil {
+			t.Fatalf("getStackTraceElement(%d) uncaught %s", i, exception.Object.Class.GetName())
+		}
+		if got := describe(element.Object); got != item {
+			t.Errorf("getStackTraceElement(%d) = %s, want %s", i, got, item)
+		}
+		if got := describe(elements.ArrayData.([]*Object)[i]); got != item {
+			t.Errorf("initStackTraceElements[%d] = %s, want %s", i, got, item)
+		}
+	}
+	_, exception = runStatic(vm, "java/lang/Throwable", "getStackTraceElement", "(I)Ljava/lang/StackTraceElement;", res, depth)
+	if exception == nil || exception.Object.Class.GetName() != "java/lang/IndexOutOfBoundsException" {
+		t.Errorf("getStackTraceElement(%d) exception = %v, want IndexOutOfBoundsException", depth.Integer(), exception)
+	}
+
+	// 本地方法行号为 -2，没有行号表为 -1，没有 SourceFile 时不显示文件名
+	foo := vm.Loader.LoadClass("Foo")
+	native := &StackElement{Method: foo.GetMethod("nat", "()V")}
+	throwable = Alloc(vm.Loader.LoadClass("java/lang/Throwable"), 0)
+	throwable.Extra = []*StackElement{native}
+	element, _ := runStatic(vm, "java/lang/Throwable", "getStackTraceElement", "(I)Ljava/lang/StackTraceElement;", NewObject(throwable), NewInteger(0))
+	if got := describe(element.Object); got != "Foo nat Foo.java -2" {
+		t.Errorf("native element = %s", got)
+	}
+	for _, test := range []struct {
+		element *StackElement
+		line    int32
+		want    string
+	}{
+		{native, -2, "Foo.nat(Native Method)"},
+		{&StackElement{Method: foo.GetMethod("main", "([Ljava/lang/String;)V")}, -1, "Foo.main(Foo.java)"},
+		{&StackElement{Method: foo.GetMethod("bar", "(I)V"), Pc: 1}, 10, "Foo.bar(Foo.java:10)"},
+		{&StackElement{Method: throwable.Class.GetMethod("initCause", "(Ljava/lang/Throwable;)Ljava/lang/Throwable;")}, -1,
+			"java.lang.Throwable.initCause(Unknown Source)"},
+	} {
+		if line, got := test.element.LineNumber(), test.element.String(); line != test.line || got != test.want {
+			t.Errorf("element = %s line %d, want %s line %d", got, line, test.want, test.line)
+		}
+	}
+
+	// 超过 MaxStackTrace 时只保留栈顶的部分
+	res, exception = runStatic(vm, "Foo", "catchBar", "(I)Ljava/lang/Throwable;", NewInteger(MaxStackTrace+10))
+	if exception != nil {
+		t.Fatalf("uncaught %s", exception.Object.Class.GetName())
+	}
+	backtrace := getBacktrace(res.Object)
+	if len(backtrace) != MaxStackTrace {
+		t.Fatalf("backtrace depth = %d, want %d", len(backtrace), MaxStackTrace)
+	}
+	if last := backtrace[len(backtrace)-1]; last.Method.GetName() != "bar" {
+		t.Errorf("last element = %s, want Foo.bar", last)
+	}
+}
@@ -7,6 +7,8 @@ package main
 import (
 	"encoding/binary"
 	"io"
+	"unicode/utf16"
+	"unicode/utf8"
 )
 
 func HandleErr(err error) {
@@ -34,3 +36,93 @@ func ParseU16(bs []byte, index int) uint16 {
 func ParseI16(bs []byte, index int) int16 {
 	return int16(ParseU16(bs, index))
 }
+
+func ParseU32(bs []byte, index int) uint32 {
+	return binary.BigEndian.Uint32(bs[index : index+4])
+}
+
+func ParseI32(bs []byte, index int) int32 {
+	return int32(ParseU32(bs, index))
+}
+
+// 解析 Modified UTF-8 为 utf-16 编码单元 https://docs.oracle.com/javase/specs/jvms/se8/html/jvms-4.html#jvms-4.4.7
+// \u0000 编码为 0xC0 0x80，补充字符先拆分为两个代理项再各自按照 3 byte 编码
+// 不允许出现 0 与 0xF0~0xFF，多 byte 编码不完整或者后续 byte 不是 10xxxxxx 时返回 false
+func DecodeMUTF8(bs []byte) ([]uint16, bool) {
+	res := make([]uint16, 0, len(bs))
+	for i := 0; i < len(bs); {
+		b := bs[i]
+		switch {
+		case b != 0 && b < 0x80:
+			res = append(res, uint16(b))
+			i++
+		case b&0xE0 == 0xC0 && continuation(bs, i+1, 1):
+			res = append(res, uint16(b&0x1F)<<6|uint16(bs[i+1]&0x3F))
+			i += 2
+		case b&0xF0 == 0xE0 && continuation(bs, i+1, 2):
+			res = append(res, uint16(b&0x0F)<<12|uint16(bs[i+1]&0x3F)<<6|uint16(bs[i+2]&0x3F))
+			i += 3
+		default:
+			return res, false
+		}
+	}
+	return res, true
+}
+
+// 从 start 开始有 count 个 10xxxxxx 格式的 byte
+func continuation(bs []byte, start int, count int) bool {
+	if start+count > len(bs) {
+		return false
+	}
+	for _, item := range bs[start : start+count] {
+		if item&0xC0 != 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// utf-16 转换为 go 字符串，成对的代理项合并为一个字符
+// 单独的代理项不是合法的 unicode，按照 3 byte 编码原样保留(WTF-8)，保证可以无损的转换回去
+func UTF16ToString(units []uint16) string {
+	bs := make([]byte, 0, len(units))
+	for i := 0; i < len(units); i++ {
+		r := rune(units[i])
+		if utf16.IsSurrogate(r) && i+1 < len(units) {
+			if pair := utf16.DecodeRune(r, rune(units[i+1])); pair != utf8.RuneError {
+				r = pair
+				i++
+			}
+		}
+		if utf16.IsSurrogate(r) { // utf8.AppendRune 会替换为 U+FFFD
+			bs = append(bs, 0xE0|byte(r>>12), 0x80|byte(r>>6)&0x3F, 0x80|byte(r)&0x3F)
+		} else {
+			bs = utf8.AppendRune(bs, r)
+		}
+	}
+	return string(bs)
+}
+
+// UTF16ToString 的逆过程
+func StringToUTF16(val string) []uint16 {
+	res := make([]uint16, 0, len(val))
+	for i := 0; i < len(val); {
+		r, size := utf8.DecodeRuneInString(val[i:])
+		if r == utf8.RuneError && size == 1 && isSurrogateBytes(val[i:]) {
+			r = rune(val[i]&0x0F)<<12 | rune(val[i+1]&0x3F)<<6 | rune(val[i+2]&0x3F)
+			size = 3
+		}
+		if utf16.IsSurrogate(r) { // utf16.AppendRune 会替换为 U+FFFD
+			res = append(res, uint16(r))
+		} else {
+			res = utf16.AppendRune(res, r)
+		}
+		i += size
+	}
+	return res
+}
+
+// 代理项的 3 byte 编码 0xED 0xA0~0xBF 0x80~0xBF
+func isSurrogateBytes(val string) bool {
+	return len(val) >= 3 && val[0] == 0xED && val[1]&0xE0 == 0xA0 && val[2]&0xC0 == 0x80
+}
@@ -0,0 +1,114 @@
+/*
+@author: sk
+@date: 2026/10/18
+*/
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeMUTF8(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []uint16
+		ok   bool
+	}{
+		{"ascii", "abc", []uint16{'a', 'b', 'c'}, true},
+		{"nul", "\xC0\x80", []uint16{0}, true},
+		{"two bytes", "\xC3\xA9", []uint16{0xE9}, true},
+		{"three bytes", "\xE4\xB8\xAD", []uint16{0x4E2D}, true},
+		// U+1F600 拆分为 0xD83D 0xDE00 两个代理项
+		{"surrogate pair", "\xED\xA0\xBD\xED\xB8\x80", []uint16{0xD83D, 0xDE00}, true},
+		{"lone surrogate", "a\xED\xB8\x80", []uint16{'a', 0xDE00}, true},
+		{"raw nul", "a\x00", nil, false},
+		{"four bytes", "\xF0\x9F\x98\x80", nil, false},
+		{"0xFF", "\xFF", nil, false},
+		{"truncated two bytes", "\xC3", nil, false},
+		{"truncated three bytes", "\xE4\xB8", nil, false},
+		{"bad continuation", "\xC3\x41", nil, false},
+		{"bad third byte", "\xE4\xB8\x41", nil, false},
+		{"leading continuation", "\x80", nil, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, ok := DecodeMUTF8([]byte(test.data))
+			if ok != test.ok {
+				t.Fatalf("ok = %v, want %v", ok, test.ok)
+			}
+			if ok && !reflect.DeepEqual(res, test.want) {
+				t.Errorf("res = %04X, want %04X", res, test.want)
+			}
+		})
+	}
+}
+
+// 成对的代理项合并为一个字符，单独的代理项原样保留，都可以无损的转换回去
+func TestUTF16RoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		units []uint16
+		want  string
+	}{
+		{"ascii", []uint16{'o', 'k'}, "ok"},
+		{"bmp", []uint16{0xE9, 0x4E2D}, "é中"},
+		{"surrogate pair", []uint16{0xD83D, 0xDE00}, "😀"},
+		{"lone high", []uint16{0xD83D, 'a'}, "\xED\xA0\xBDa"},
+		{"lone low", []uint16{0xDE00}, "\xED\xB8\x80"},
+		{"reversed pair", []uint16{0xDE00, 0xD83D}, "\xED\xB8\x80\xED\xA0\xBD"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			str := UTF16ToString(test.units)
+			if str != test.want {
+				t.Errorf("UTF16ToString = %q, want %q", str, test.want)
+			}
+			if res := StringToUTF16(str); !reflect.DeepEqual(res, test.units) {
+				t.Errorf("StringToUTF16 = %04X, want %04X", res, test.units)
+			}
+		})
+	}
+}
+
+// jdk9+ 的 String，value 为 byte[]，coder 标识编码
+func compactStringClass() *testClass {
+	str := newTestClass("java/lang/String", "java/lang/Object")
+	str.access |= AccessFinal
+	str.field(AccessPrivate|AccessFinal, "value", "[B")
+	str.field(AccessPrivate|AccessFinal, "coder", "B")
+	str.field(AccessStatic|AccessFinal, "COMPACT_STRINGS", "Z")
+	return str
+}
+
+func TestStringBytes(t *testing.T) {
+	vm := newTestVM(t, compactStringClass())
+	class := vm.Loader.LoadClass("java/lang/String")
+	tests := []struct {
+		name  string
+		val   string
+		coder int32
+		data  []int8
+	}{
+		{"latin1", "aé", StringLatin1, []int8{'a', -0x17}},
+		{"utf16", "a中", StringUTF16, []int8{'a', 0, 0x2D, 0x4E}},
+		{"surrogate pair", "😀", StringUTF16, []int8{0x3D, -0x28, 0x00, -0x22}},
+		{"lone surrogate", "\xED\xB8\x80", StringUTF16, []int8{0x00, -0x22}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			obj := NewString(vm.Loader, test.val).Object
+			data := obj.Fields[class.GetField("value", "[B").SlotID].Object.ArrayData.([]int8)
+			if coder := obj.Fields[class.GetField("coder", "B").SlotID].Integer(); coder != test.coder {
+				t.Errorf("coder = %d, want %d", coder, test.coder)
+			}
+			if !reflect.DeepEqual(data, test.data) {
+				t.Errorf("value = %v, want %v", data, test.data)
+			}
+			if res := GoString(obj); res != test.val {
+				t.Errorf("GoString = %q, want %q", res, test.val)
+			}
+		})
+	}
+}
@@ -0,0 +1,296 @@
+/*
+@author: sk
+@date: 2026/10/18
+*/
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// 虚拟机实例，运行时状态都挂在这里，同一进程中创建的多个虚拟机互不影响
+type VM struct {
+	Loader    *Loader
+	Strings   *StringTable
+	ClassPath []string // 用户类搜索路径，作为 java.class.path
+	// System.out System.err 最终写到这里，嵌入使用时可以在运行前替换
+	Stdout io.Writer
+	Stderr io.Writer
+	// System 初始化缺少本地方法退回简化流程时，把被忽略的异常打印到 Stderr
+	VerboseInit bool
+	// 身份哈希的生成策略，取值与 hotspot 的 -XX:hashCode 一致
+	HashCode     int
+	HashSequence uint32 // HashSequential 使用的全局计数
+	RandomSeed   uint32
+	// -Xss 设置的最大调用深度(栈帧数)，-Xmx 设置的堆大小(byte)，0 表示不限制
+	MaxStackDepth int
+	MaxHeapSize   int64
+	HeapUsed      int64 // 没有实现垃圾回收，只增不减
+	// 与 hotspot 一样预先创建的异常对象，创建异常时预留的栈帧或者堆空间也用完了就抛出它们
+	OutOfMemoryError   *Object
+	StackOverflowError *Object
+	// 运行时生成的本地方法，例如 lambda 类的接口方法，与生成的类一样只属于当前虚拟机
+	Natives     map[string]NativeFunc
+	LambdaCount int // 生成的 lambda 类编号
+}
+
+const (
+	HashSequential = 3 // 依次递增，便于测试时得到确定的结果
+	HashXorShift   = 5 // 线程私有状态的 Marsaglia xor-shift，hotspot 的默认策略
+)
+
+func NewVM(bootPaths []string, userPaths []string) *VM {
+	loader := NewLoader(bootPaths, userPaths)
+	vm := &VM{Loader: loader, Strings: &StringTable{loader: loader, strings: make(map[string]*Object)}, ClassPath: userPaths,
+		Stdout: os.Stdout, Stderr: os.Stderr, HashCode: HashXorShift, RandomSeed: 1234567, MaxStackDepth: DefaultStackDepth,
+		Natives: make(map[string]NativeFunc)}
+	loader.Strings = vm.Strings
+	vm.Loader.LoadClass("java/lang/Class") // 之后加载的类都可以在链接时创建 Class 对象
+	vm.OutOfMemoryError = vm.preallocError("java/lang/OutOfMemoryError", "Java heap space")
+	vm.StackOverflowError = vm.preallocError("java/lang/StackOverflowError", "")
+	return vm
+}
+
+// 没有线程不能执行构造方法，与 hotspot 一样只分配对象并设置异常信息
+func (vm *VM) preallocError(className string, msg string) *Object {
+	obj := Alloc(vm.Loader.LoadClass(className), 0)
+	if msg != "" {
+		throwable := vm.Loader.LoadClass("java/lang/Throwable")
+		obj.Fields[throwable.GetField("detailMessage", "Ljava/lang/String;").SlotID] = NewString(vm.Loader, msg)
+	}
+	return obj
+}
+
+// 字符串常量池，属于虚拟机，类加载器持有同一个以便在准备阶段创建字符串常量字段
+type StringTable struct {
+	loader  *Loader
+	strings map[string]*Object
+}
+
+// 字面量与 String.intern 共用，常量池中已经有相同内容的字符串就返回它，否则放入并返回自己
+func (t *StringTable) Intern(str *Object) *Object {
+	val := GoString(str)
+	if res, ok := t.strings[val]; ok {
+		return res
+	}
+	t.strings[val] = str
+	return str
+}
+
+// ldc 加载的字符串字面量与字符串常量字段，内容相同的字面量是同一个对象
+func (t *StringTable) InternString(val string) Value {
+	if res, ok := t.strings[val]; ok {
+		return NewObject(res)
+	}
+	res := NewString(t.loader, val)
+	t.strings[val] = res.Object
+	return res
+}
+
+// System.initProperties 设置的系统属性，按顺序设置
+func (vm *VM) Properties() [][2]string {
+	dir, _ := os.Getwd()
+	home, _ := os.UserHomeDir()
+	arch := runtime.GOARCH
+	if arch == "arm64" { // 与 hotspot 的名称保持一致
+		arch = "aarch64"
+	}
+	return [][2]string{
+		{"java.vm.name", "jvm"},
+		{"java.home", os.Getenv("JAVA_HOME")},
+		{"java.class.path", strings.Join(vm.ClassPath, string(os.PathListSeparator))},
+		{"java.library.path", ""},
+		{"java.io.tmpdir", os.TempDir()},
+		{"os.name", osName()},
+		{"os.arch", arch},
+		{"file.separator", string(filepath.Separator)},
+		{"path.separator", string(os.PathListSeparator)},
+		{"line.separator", "\n"},
+		{"file.encoding", "UTF-8"},
+		{"sun.jnu.encoding", "UTF-8"},
+		{"user.dir", dir},
+		{"user.home", home},
+		{"user.name", os.Getenv("USER")},
+	}
+}
+
+func osName() string {
+	switch runtime.GOOS {
+	case "linux":
+		return "Linux"
+	case "darwin":
+		return "Mac OS X"
+	case "windows":
+		return "Windows"
+	default:
+		return runtime.GOOS
+	}
+}
+
+// 先执行 System.initializeSystemClass(jdk8) initPhase1(jdk9+)，完整的初始化过程依赖大量的本地方法
+// 只有缺少本地方法(UnsatisfiedLinkError)时退回到与其中标准流的创建一致的简化流程，其他异常继续抛出
+// 简化流程只设置还是 null 的 props 与 System.out System.err，并且不使用缓冲
+func initSystemClass(thread *Thread) {
+	system := thread.Loader.LoadClass("java/lang/System")
+	InitClass(thread, system)
+	init := systemInitMethod(system)
+	if init != nil {
+		exception := CatchException(thread, func() {
+			RunMethod(thread, init, nil)
+		})
+		if exception == nil {
+			return
+		}
+		if exception.Object.Class.GetName() != "java/lang/UnsatisfiedLinkError" {
+			panic(exception)
+		}
+		if thread.VM.VerboseInit {
+			fmt.Fprintf(thread.VM.Stderr, "warning: %s failed, fall back to simplified initialization\n", methodName(init))
+			PrintStackTrace(thread.Loader, exception, thread.VM.Stderr)
+		}
+	}
+	// jdk8 的 props 由 initializeSystemClass 调用 initProperties 设置，jdk9+ 由 initPhase1 设置，为 null 时 getProperty 会抛出 NullPointerException
+	field := system.GetField("props", "Ljava/util/Properties;")
+	if field != nil && init != nil && system.StaticValues[field.SlotID].Object == nil {
+		props := NewInstance(thread, "java/util/Properties", "()V")
+		runInFrame(thread, init, func() {
+			initProperties(thread, props)
+		})
+		system.StaticValues[field.SlotID] = NewObject(props)
+	}
+	fdClass := thread.Loader.LoadClass("java/io/FileDescriptor")
+	InitClass(thread, fdClass)
+	for _, name := range []string{"out", "err"} {
+		slotID := system.GetField(name, "Ljava/io/PrintStream;").SlotID
+		if system.StaticValues[slotID].Object != nil {
+			continue
+		}
+		fd := fdClass.StaticValues[fdClass.GetField(name, "Ljava/io/FileDescriptor;").SlotID]
+		fos := NewInstance(thread, "java/io/FileOutputStream", "(Ljava/io/FileDescriptor;)V", fd)
+		ps := NewInstance(thread, "java/io/PrintStream", "(Ljava/io/OutputStream;Z)V", NewObject(fos), NewInteger(1))
+		system.StaticValues[slotID] = NewObject(ps)
+	}
+	if field := system.GetField("lineSeparator", "Ljava/lang/String;"); field != nil && system.StaticValues[field.SlotID].Object == nil {
+		system.StaticValues[field.SlotID] = thread.VM.Strings.InternString("\n")
+	}
+}
+
+// jdk8 的 initializeSystemClass 或者 jdk9+ 的 initPhase1
+func systemInitMethod(system *Class) *Field {
+	if method := system.GetMethod("initializeSystemClass", "()V"); method != nil {
+		return method
+	}
+	return system.GetMethod("initPhase1", "()V")
+}
+
+// 线程栈为空时 go 代码调用有返回值的 java 方法需要调用方栈帧接收返回值，这里压入 method 的临时栈帧
+func runInFrame(thread *Thread, method *Field, call func()) {
+	depth := thread.Stack.Index
+	defer thread.PopTo(depth)
+	thread.Stack.Push(&Frame{Method: method, Code: method.GetCodeAttribute(), Stack: NewStack[Value](4)})
+	call()
+}
+
+func (vm *VM) RegisterNativeFunc(class string, name string, desc string, func0 NativeFunc) {
+	vm.Natives[nativeKey(class, name, desc)] = func0
+}
+
+// 优先使用虚拟机自己的本地方法，其次是 InitNativeFunc 注册的
+func (vm *VM) GetNativeFunc(class string, name string, desc string) NativeFunc {
+	if func0, ok := vm.Natives[nativeKey(class, name, desc)]; ok {
+		return func0
+	}
+	return GetNativeFunc(class, name, desc)
+}
+
+// 只支持标准输出与标准错误，没有实现打开文件
+func (vm *VM) fdWriter(fd int32) io.Writer {
+	switch fd {
+	case 1:
+		return vm.Stdout
+	case 2:
+		return vm.Stderr
+	default:
+		return nil
+	}
+}
+
+// java 代码(new newarray clone 等)分配对象，超过 -Xmx 时抛出 OutOfMemoryError，虚拟机内部创建的字符串与 Class 对象不计入
+// 最后 HeapReserve 的空间只在创建 OutOfMemoryError 时使用，预留的空间也不够时抛出预先创建的异常对象
+func HeapAlloc(thread *Thread, class *Class, length int) *Object {
+	vm := thread.VM
+	if vm.MaxHeapSize > 0 {
+		size := objectSize(class, length)
+		limit := vm.MaxHeapSize - HeapReserve
+		if thread.OutOfMemory {
+			limit = vm.MaxHeapSize
+		}
+		if vm.HeapUsed+size > limit {
+			if thread.OutOfMemory {
+				panic(NewJavaException(thread, vm.OutOfMemoryError))
+			}
+			thread.OutOfMemory = true
+			defer func() {
+				thread.OutOfMemory = false
+			}()
+			ThrowNew(thread, "java/lang/OutOfMemoryError", "Java heap space")
+		}
+		vm.HeapUsed += size
+	}
+	return Alloc(class, length)
+}
+
+// 估算对象占用的空间，对象头 16 byte，字段每个槽位 8 byte，数组元素按照类型的大小
+func objectSize(class *Class, length int) int64 {
+	className := class.GetName()
+	if className[0] != '[' {
+		return 16 + int64(class.InstSlotCount)*8
+	}
+	return 16 + int64(length)*arrayElemSize(className)
+}
+
+// 数组元素的大小，引用与 long double 一样按 8 byte 计算，Unsafe.arrayIndexScale 也使用这个值
+func arrayElemSize(className string) int64 {
+	switch className[1] {
+	case 'Z', 'B':
+		return 1
+	case 'C', 'S':
+		return 2
+	case 'I', 'F':
+		return 4
+	default:
+		return 8
+	}
+}
+
+// hotspot os::random 使用的 Park-Miller 随机数，种子固定所以每次运行的结果都相同
+func (vm *VM) random() uint32 {
+	vm.RandomSeed = uint32(uint64(vm.RandomSeed) * 16807 % 2147483647)
+	return vm.RandomSeed
+}
+
+// 生成身份哈希，对象头中只有 31 位，0 表示没有生成所以不能使用
+func (vm *VM) nextHash(thread *Thread) int32 {
+	var val uint32
+	switch vm.HashCode {
+	case HashSequential:
+		vm.HashSequence++
+		val = vm.HashSequence
+	default:
+		state := &thread.HashState
+		temp := state[0] ^ (state[0] << 11)
+		state[0], state[1], state[2] = state[1], state[2], state[3]
+		state[3] = (state[3] ^ (state[3] >> 19)) ^ (temp ^ (temp >> 8))
+		val = state[3]
+	}
+	val &= 0x7FFFFFFF
+	if val == 0 {
+		val = 0xBAD
+	}
+	return int32(val)
+}
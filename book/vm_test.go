@@ -0,0 +1,105 @@
+/*
+@author: sk
+@date: 2026/10/18
+*/
+package main
+
+import "testing"
+
+// 构造方法中继续递归或者分配，把创建异常时预留的栈帧与堆空间用完
+// static Object overflow() { try { return recurse(); } catch (StackOverflowError e) { return e; } }
+// static Object oom() { try { return new int[HeapReserve]; } catch (OutOfMemoryError e) { return e; } }
+func reserveClasses() []*testClass {
+	soe := newTestClass("java/lang/StackOverflowError", "java/lang/VirtualMachineError")
+	soe.method(AccessStatic, "grow", "()V", newTestCode(0, 0).
+		op16(0xB8, soe.ref(ConstMethod, "java/lang/StackOverflowError", "grow", "()V")).op(0xB1)) // invokestatic return
+	soe.method(AccessPublic, "<init>", "()V", newTestCode(0, 1).
+		op16(0xB8, soe.ref(ConstMethod, "java/lang/StackOverflowError", "grow", "()V")).op(0xB1))
+	oom := newTestClass("java/lang/OutOfMemoryError", "java/lang/VirtualMachineError")
+	oom.method(AccessPublic, "<init>", "(Ljava/lang/String;)V", newTestCode(1, 2).
+		op16(0x13, oom.integer(HeapReserve)).op(0xBC, ArrayInt, 0x57, 0xB1)) // ldc_w newarray pop return
+	class := newTestClass("Reserve", "java/lang/Object")
+	class.method(AccessStatic, "recurse", "()Ljava/lang/Object;", newTestCode(1, 0).
+		op16(0xB8, class.ref(ConstMethod, "Reserve", "recurse", "()Ljava/lang/Object;")).op(0xB0)) // invokestatic areturn
+	catchAll := func(name string, errorClass string, code *testCode) {
+		code.label("end").op(0xB0).label("handler").op(0xB0) // areturn
+		class.method(AccessStatic, name, "()Ljava/lang/Object;", code.catch("start", "end", "handler", errorClass))
+	}
+	catchAll("overflow", "java/lang/StackOverflowError", newTestCode(1, 0).label("start").
+		op16(0xB8, class.ref(ConstMethod, "Reserve", "recurse", "()Ljava/lang/Object;")))
+	catchAll("oom", "java/lang/OutOfMemoryError", newTestCode(1, 0).label("start").
+		op16(0x13, class.integer(HeapReserve)).op(0xBC, ArrayInt)) // ldc_w newarray
+	return []*testClass{soe, oom, class}
+}
+
+func TestReserveExhausted(t *testing.T) {
+	vm := newTestVM(t, reserveClasses()...)
+	vm.MaxHeapSize = HeapReserve * 2
+	for method, want := range map[string]*Object{"overflow": vm.StackOverflowError, "oom": vm.OutOfMemoryError} {
+		res, exception := runStatic(vm, "Reserve", method, "()Ljava/lang/Object;")
+		if exception != nil {
+			t.Fatalf("%s uncaught %s", method, exception.Object.Class.GetName())
+		}
+		if res.Object != want {
+			t.Errorf("%s = %v, want preallocated %s", method, res.Object, want.Class.GetName())
+		}
+	}
+}
+
+// String(String original) 共用 value 数组，intern 为本地方法
+// Interns 中 same 比较两个类的同一个字面量，copy 比较 new String("hi") 与字面量，interned 比较 new String("hi").intern() 与字面量
+func internClasses() []*testClass {
+	str := newTestClass("java/lang/String", "java/lang/Object")
+	str.access |= AccessFinal
+	str.field(AccessPrivate|AccessFinal, "value", "[C")
+	value := str.ref(ConstField, "java/lang/String", "value", "[C")
+	str.method(AccessPublic, "<init>", "(Ljava/lang/String;)V", newTestCode(2, 2).
+		op(0x2A).op16(0xB7, str.ref(ConstMethod, "java/lang/Object", "<init>", "()V")). // aload_0 invokespecial
+		op(0x2A, 0x2B).op16(0xB4, value).op16(0xB5, value).op(0xB1))                    // aload_0 aload_1 getfield putfield return
+	str.method(AccessPublic|AccessNative, "intern", "()Ljava/lang/String;", nil)
+	other := newTestClass("Other", "java/lang/Object")
+	other.method(AccessStatic, "hi", "()Ljava/lang/String;", newTestCode(1, 0).op16(0x13, other.str("hi")).op(0xB0))
+	class := newTestClass("Interns", "java/lang/Object")
+	hi := class.str("hi")
+	compare := func(name string, code *testCode) { // if_acmpeq iconst_0 ireturn iconst_1 ireturn
+		class.method(AccessStatic, name, "()I", code.jump(0xA5, "same").op(0x03, 0xAC).label("same").op(0x04, 0xAC))
+	}
+	newString := func(code *testCode) *testCode { // new dup ldc_w invokespecial
+		return code.op16(0xBB, class.class("java/lang/String")).op(0x59).op16(0x13, hi).
+			op16(0xB7, class.ref(ConstMethod, "java/lang/String", "<init>", "(Ljava/lang/String;)V"))
+	}
+	compare("same", newTestCode(2, 0).op16(0x13, hi).op16(0xB8, class.ref(ConstMethod, "Other", "hi", "()Ljava/lang/String;")))
+	compare("copy", newString(newTestCode(4, 0)).op16(0x13, hi))
+	compare("interned", newString(newTestCode(4, 0)).
+		op16(0xB6, class.ref(ConstMethod, "java/lang/String", "intern", "()Ljava/lang/String;")).op16(0x13, hi))
+	class.method(AccessStatic, "fresh", "()Ljava/lang/String;", newTestCode(1, 0).op16(0x13, class.str("fresh")).op(0xB0))
+	return []*testClass{str, other, class}
+}
+
+func TestStringIntern(t *testing.T) {
+	vm := newTestVM(t, internClasses()...)
+	for method, want := range map[string]int32{"same": 1, "copy": 0, "interned": 1} {
+		res, exception := runStatic(vm, "Interns", method, "()I")
+		if exception != nil || res.Integer() != want {
+			t.Errorf("%s() = %d, exception %v, want %d", method, res.Integer(), exception, want)
+		}
+	}
+	// 常量池中没有时 intern 放入自己，之后的字面量就是它
+	fresh := NewString(vm.Loader, "fresh")
+	if res, _ := runStatic(vm, "java/lang/String", "intern", "()Ljava/lang/String;", fresh); res.Object != fresh.Object {
+		t.Errorf("intern() = %v, want the receiver", res.Object)
+	}
+	if res, _ := runStatic(vm, "Interns", "fresh", "()Ljava/lang/String;"); res.Object != fresh.Object {
+		t.Errorf("literal after intern = %v, want the interned string", res.Object)
+	}
+	// 每个虚拟机有自己的常量池
+	other := newTestVM(t, internClasses()...)
+	res1, _ := runStatic(vm, "Other", "hi", "()Ljava/lang/String;")
+	res2, _ := runStatic(other, "Other", "hi", "()Ljava/lang/String;")
+	if res1.Object == nil || res1.Object == res2.Object {
+		t.Errorf("literals of two VMs = %p %p, want different objects", res1.Object, res2.Object)
+	}
+	if res, _ := runStatic(other, "Interns", "same", "()I"); res.Integer() != 1 {
+		t.Errorf("same() in another VM = %d, want 1", res.Integer())
+	}
+}